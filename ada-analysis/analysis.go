@@ -0,0 +1,258 @@
+// Package analysis reviews whole games with the engine. Every position of a
+// game is searched, each move is judged by how much it lost against the
+// engine's best, and the findings can be written back into the game as PGN
+// annotations. The package also runs EPD test suites, to track how well the
+// engine solves tactical positions.
+package analysis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// Options controls how a game is reviewed.
+type Options struct {
+	// Depth bounds the search of each position. With MoveTime set it may
+	// stop sooner.
+	Depth    int
+	MoveTime time.Duration
+	Threads  int
+
+	// How much a move may lose, in centipawns for the side that played
+	// it, before it counts as an inaccuracy, a mistake or a blunder.
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+
+	// Puzzles need a move that wins at least Decisive centipawns, or
+	// mates, where no other move keeps half as much. Their solutions run
+	// to at most PuzzleMoves moves of the solver.
+	Decisive    int
+	PuzzleMoves int
+}
+
+// DefaultOptions returns the options reviews use unless told otherwise.
+func DefaultOptions() Options {
+	return Options{
+		Depth:       12,
+		Threads:     1,
+		Inaccuracy:  50,
+		Mistake:     100,
+		Blunder:     300,
+		Decisive:    300,
+		PuzzleMoves: 4,
+	}
+}
+
+// evalCap bounds evaluations, in centipawns, before losses are measured, so
+// that a won position staying won isn't judged by how won it is. Mates
+// count as the cap.
+const evalCap = 1000
+
+// Class is the verdict on a move.
+type Class int
+
+const (
+	Good Class = iota
+	Inaccuracy
+	Mistake
+	Blunder
+)
+
+func (c Class) String() string {
+	switch c {
+	case Inaccuracy:
+		return "Inaccuracy"
+	case Mistake:
+		return "Mistake"
+	case Blunder:
+		return "Blunder"
+	}
+	return "Good"
+}
+
+// NAG returns the glyph marking a move of the class, 0 for a good move.
+func (c Class) NAG() int {
+	switch c {
+	case Inaccuracy:
+		return pgn.NAGDubious
+	case Mistake:
+		return pgn.NAGMistake
+	case Blunder:
+		return pgn.NAGBlunder
+	}
+	return 0
+}
+
+// Move is the review of one move. Scores are from White's point of view
+// and mates are search scores, see search.MateIn.
+type Move struct {
+	Move   core.Move
+	SAN    string
+	Color  core.Color
+	Number int // the move number, as in 12. or 12...
+
+	// Best is the engine's choice in the position before the move and PV
+	// the line it expected, starting with Best.
+	Best    core.Move
+	BestSAN string
+	PV      []core.Move
+
+	// Before and After are the evaluations of the positions before and
+	// after the move.
+	Before int
+	After  int
+
+	// Loss is how much the move lost for the side that played it, in
+	// centipawns, never less than 0.
+	Loss  int
+	Class Class
+}
+
+// Review is the result of reviewing a game, one entry per move.
+type Review struct {
+	Moves []Move
+}
+
+// Analyze reviews every move of a game with the engine. The engine plays
+// its best for the review, without its book or a skill level, and its
+// options are restored afterwards. progress, if not nil, is called after
+// each position is searched with the count done and the total.
+func Analyze(e *search.Engine, g *pgn.Game, opts Options, progress func(done, total int)) *Review {
+	defer fullStrength(e, 1)()
+
+	moves := g.Moves()
+	positions := append(g.Positions()[:len(moves):len(moves)], finalPosition(g))
+	total := len(positions)
+
+	// each position's score and best line, from White's point of view
+	scores := make([]int, total)
+	lines := make([][]core.Move, total)
+	for i, pos := range positions {
+		e.SetHistory(positions[:i])
+		scores[i], lines[i] = evaluate(e, pos, opts)
+		if progress != nil {
+			progress(i+1, total)
+		}
+	}
+
+	r := &Review{Moves: make([]Move, len(moves))}
+	for i, m := range moves {
+		pos := positions[i]
+		mv := Move{
+			Move:   m,
+			SAN:    pgn.SAN(pos, m),
+			Color:  pos.ActiveColor,
+			Number: pos.Fullmoves,
+			Before: scores[i],
+			After:  scores[i+1],
+			PV:     lines[i],
+		}
+		if len(mv.PV) > 0 {
+			mv.Best = mv.PV[0]
+			mv.BestSAN = pgn.SAN(pos, mv.Best)
+		}
+
+		// the engine's own choice loses nothing, whatever a deeper look
+		// at the next position finds
+		if m != mv.Best {
+			loss := capped(mv.Before) - capped(mv.After)
+			if mv.Color == core.Black {
+				loss = -loss
+			}
+			mv.Loss = max(loss, 0)
+			mv.Class = opts.classify(mv.Loss)
+		}
+		r.Moves[i] = mv
+	}
+	return r
+}
+
+// fullStrength sets the engine up to search its best with multiPV lines
+// and returns a function putting its options and history back.
+func fullStrength(e *search.Engine, multiPV int) (restore func()) {
+	saved := e.Options
+	e.Options.OwnBook = false
+	e.Options.SkillLevel = search.MaxSkillLevel
+	e.Options.LimitStrength = false
+	e.Options.MultiPV = multiPV
+	return func() {
+		e.Options = saved
+		e.SetHistory(nil)
+	}
+}
+
+// finalPosition returns the position after the last move of a game.
+func finalPosition(g *pgn.Game) *position.Position {
+	moves := g.Moves()
+	if len(moves) == 0 {
+		return g.Start()
+	}
+	return position.MakeMove(g.Positions()[len(moves)-1], moves[len(moves)-1])
+}
+
+// evaluate searches pos and returns its score from White's point of view
+// and the engine's line.
+func evaluate(e *search.Engine, pos *position.Position, opts Options) (int, []core.Move) {
+	var score int
+	var pv []core.Move
+
+	moves := movegen.LegalMoves(pos)
+	switch {
+	case moves.Count() == 0 && movegen.InCheck(pos):
+		score = -search.Mate
+	case moves.Count() == 0:
+		score = 0
+	default:
+		depth := opts.Depth
+		if depth <= 0 {
+			depth = DefaultOptions().Depth
+		}
+		res := e.Search(pos, depth, opts.Threads, opts.MoveTime)
+		score, pv = res.Score, res.PV
+	}
+
+	if pos.ActiveColor == core.Black {
+		score = -score
+	}
+	return score, pv
+}
+
+// classify judges a move by how much it lost.
+func (o Options) classify(loss int) Class {
+	switch {
+	case loss >= o.Blunder:
+		return Blunder
+	case loss >= o.Mistake:
+		return Mistake
+	case loss >= o.Inaccuracy:
+		return Inaccuracy
+	}
+	return Good
+}
+
+// capped returns a score in centipawns within ±evalCap.
+func capped(score int) int {
+	if _, ok := search.MateIn(score); ok {
+		if score > 0 {
+			return evalCap
+		}
+		return -evalCap
+	}
+	return min(max(score, -evalCap), evalCap)
+}
+
+// FormatEval writes a score from White's point of view as PGN [%eval]
+// comments do: pawns with two decimals, or #n and #-n for mates.
+func FormatEval(score int) string {
+	if n, ok := search.MateIn(score); ok {
+		return fmt.Sprintf("#%d", n)
+	}
+	return fmt.Sprintf("%.2f", float64(score)/100)
+}
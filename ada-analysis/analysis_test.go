@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// scholarsMate is a game where Black misses the threat to f7.
+func scholarsMate(t *testing.T) *pgn.Game {
+	start, _ := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	g := pgn.NewGame(start)
+	pos := start
+	for _, san := range []string{"e4", "e5", "Qh5", "Nc6", "Bc4", "Nf6", "Qxf7#"} {
+		m, err := pgn.ParseSAN(pos, san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.AddMove(pos, m)
+		pos = position.MakeMove(pos, m)
+	}
+	g.Result = "1-0"
+	return g
+}
+
+func TestAnalyze(t *testing.T) {
+	g := scholarsMate(t)
+	e := search.NewEngine(16)
+	e.Options.Contempt = 7
+	opts := DefaultOptions()
+	opts.Depth = 5
+
+	calls := 0
+	r := Analyze(e, g, opts, func(done, total int) {
+		calls++
+		if total != 8 || done != calls {
+			t.Errorf("progress %d of %d on call %d", done, total, calls)
+		}
+	})
+	if calls != 8 {
+		t.Errorf("progress called %d times", calls)
+	}
+	if e.Options.Contempt != 7 || !e.Options.OwnBook {
+		t.Error("engine options not restored")
+	}
+	if len(r.Moves) != 7 {
+		t.Fatalf("%d moves reviewed", len(r.Moves))
+	}
+
+	nf6 := r.Moves[5]
+	if nf6.SAN != "Nf6" || nf6.Class != Blunder || nf6.Best.String() == "g8f6" {
+		t.Errorf("Nf6: %+v", nf6)
+	}
+	if n, ok := search.MateIn(nf6.After); !ok || n != 1 {
+		t.Errorf("after Nf6: %s, want mate in 1", FormatEval(nf6.After))
+	}
+	mate := r.Moves[6]
+	if mate.Class != Good || mate.Loss != 0 {
+		t.Errorf("Qxf7#: %+v", mate)
+	}
+	for _, mv := range r.Moves[:5] {
+		if mv.Class >= Mistake {
+			t.Errorf("%s judged a %s", mv.SAN, mv.Class)
+		}
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	g := scholarsMate(t)
+	opts := DefaultOptions()
+	opts.Depth = 4
+	Analyze(search.NewEngine(16), g, opts, nil).Annotate(g)
+
+	text := strings.ReplaceAll(g.String(), "\n", " ")
+	for _, want := range []string{
+		`[Annotator "AdaEngine"]`,
+		"Nf6 $4 {[%eval #1] Blunder.",
+		"was best.} (3...",
+		"Qxf7# {[%eval #0]}",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("annotated game lacks %q:\n%s", want, g)
+		}
+	}
+	if strings.Count(text, "[%eval") != 7 {
+		t.Errorf("want an eval for each move:\n%s", g)
+	}
+}
+
+func TestFormatEval(t *testing.T) {
+	for score, want := range map[int]string{
+		35:               "0.35",
+		-120:             "-1.20",
+		0:                "0.00",
+		search.Mate - 3:  "#2",
+		-search.Mate + 2: "#-1",
+	} {
+		if got := FormatEval(score); got != want {
+			t.Errorf("FormatEval(%d) = %s, want %s", score, got, want)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package analysis
+
+import (
+	"fmt"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+)
+
+// Annotate writes the review into the game it was made from, replacing any
+// annotations the game had: every move gets an [%eval] comment with the
+// evaluation after it, and inaccuracies, mistakes and blunders get their
+// glyph, a note of the better move and the engine's line as a variation.
+func (r *Review) Annotate(g *pgn.Game) {
+	g.ClearAnnotations()
+	for i, mv := range r.Moves {
+		a := g.Annotation(i)
+		a.Comment = fmt.Sprintf("[%%eval %s]", FormatEval(mv.After))
+		if mv.Class == Good {
+			continue
+		}
+		a.NAGs = []int{mv.Class.NAG()}
+		if mv.Best != core.NoMove {
+			a.Comment += fmt.Sprintf(" %s. %s was best.", mv.Class, mv.BestSAN)
+			a.Variations = [][]core.Move{mv.PV}
+		}
+	}
+	g.Tags["Annotator"] = "AdaEngine"
+}
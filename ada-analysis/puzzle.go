@@ -0,0 +1,235 @@
+package analysis
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// Puzzle is a position from a game where one move, and only one, punishes
+// the mistake just made.
+type Puzzle struct {
+	// FEN is the position to solve, after the mistake.
+	FEN string
+
+	// Moves is the solution: the solver's moves with the opponent's best
+	// replies between them, starting and ending with the solver's.
+	Moves []core.Move
+	SAN   []string
+
+	// Themes describe what the solution is about, such as "mate",
+	// "mateIn2", "fork" or "promotion".
+	Themes []string
+
+	// Ply is the index of the game's move the puzzle starts from, and
+	// Eval the evaluation of the puzzle for the solver.
+	Ply  int
+	Eval int
+}
+
+// UCI returns the solution in long algebraic notation, separated by spaces.
+func (p Puzzle) UCI() string {
+	s := make([]string, len(p.Moves))
+	for i, m := range p.Moves {
+		s[i] = m.String()
+	}
+	return strings.Join(s, " ")
+}
+
+func (p Puzzle) String() string {
+	return fmt.Sprintf("%s  %s  (%s)", p.FEN, strings.Join(p.SAN, " "), strings.Join(p.Themes, " "))
+}
+
+// FindPuzzles looks for puzzles after the mistakes and blunders of a
+// reviewed game. Each candidate is searched again for the two best moves at
+// every step of its solution, which goes on while exactly one move keeps
+// the solver winning, and is dropped unless at least its first move does.
+func FindPuzzles(e *search.Engine, g *pgn.Game, r *Review, opts Options) []Puzzle {
+	defer fullStrength(e, 2)()
+
+	var puzzles []Puzzle
+	moves := g.Moves()
+	positions := append(g.Positions()[:len(moves):len(moves)], finalPosition(g))
+	for i, mv := range r.Moves {
+		if mv.Class < Mistake {
+			continue
+		}
+		// the solver must have been winning only because of the mistake
+		before, after := capped(mv.Before), capped(mv.After)
+		if mv.Color == core.White {
+			before, after = -before, -after
+		}
+		if after < opts.Decisive || before >= opts.Decisive {
+			continue
+		}
+
+		if p, ok := solve(e, positions[:i+1], positions[i+1], opts); ok {
+			p.Ply = i + 1
+			puzzles = append(puzzles, p)
+		}
+	}
+	return puzzles
+}
+
+// solve finds the solution of the puzzle in start, if it has one. history
+// holds the game's positions before start, which the engine is told about,
+// along with the solution's own, so it sees repetitions of them.
+func solve(e *search.Engine, history []*position.Position, start *position.Position, opts Options) (Puzzle, bool) {
+	p := Puzzle{FEN: fen.Format(start)}
+	solver := start.ActiveColor
+	pos := start
+	history = slices.Clip(history)
+	e.SetHistory(history)
+	play := func(m core.Move) {
+		history = append(history, pos)
+		e.SetHistory(history)
+		pos = position.MakeMove(pos, m)
+	}
+	steps := 0
+	mated := false
+	for steps < opts.PuzzleMoves {
+		legal := movegen.LegalMoves(pos)
+		if steps == 0 && legal.Count() < 2 {
+			return p, false // an only move is no puzzle
+		}
+		lines := bestLines(e, pos, opts)
+		best := lines[0]
+		mateIn, isMate := search.MateIn(best.Score)
+		if steps == 0 {
+			p.Eval = best.Score
+		}
+		// the last move of a mate may be any of several, but not the first
+		lastMate := isMate && mateIn == 1
+		if (steps == 0 || !lastMate) && !onlyWinner(lines, opts) {
+			break
+		}
+
+		p.Moves = append(p.Moves, best.Move)
+		p.SAN = append(p.SAN, pgn.SAN(pos, best.Move))
+		play(best.Move)
+		steps++
+		if lastMate {
+			mated = true
+			break
+		}
+
+		// the opponent's best reply
+		reply := e.Search(pos, searchDepth(opts), opts.Threads, opts.MoveTime)
+		if reply.Move == core.NoMove {
+			break
+		}
+		p.Moves = append(p.Moves, reply.Move)
+		p.SAN = append(p.SAN, pgn.SAN(pos, reply.Move))
+		play(reply.Move)
+	}
+
+	// a solution ends with the solver's move
+	if n := len(p.Moves); n > 0 && n%2 == 0 {
+		p.Moves, p.SAN = p.Moves[:n-1], p.SAN[:n-1]
+	}
+	if len(p.Moves) == 0 {
+		return p, false
+	}
+	p.Themes = themes(start, p.Moves, solver, mated)
+	return p, true
+}
+
+// bestLines returns the two best moves in pos with their scores for the
+// side to move, or the only one.
+func bestLines(e *search.Engine, pos *position.Position, opts Options) []search.Line {
+	res := e.Search(pos, searchDepth(opts), opts.Threads, opts.MoveTime)
+	if len(res.Lines) > 0 {
+		return res.Lines
+	}
+	return []search.Line{{Move: res.Move, Score: res.Score}}
+}
+
+// onlyWinner reports whether the best of lines wins decisively and no
+// other move keeps even half that.
+func onlyWinner(lines []search.Line, opts Options) bool {
+	if capped(lines[0].Score) < opts.Decisive {
+		return false
+	}
+	return len(lines) < 2 || capped(lines[1].Score) < opts.Decisive/2
+}
+
+func searchDepth(opts Options) int {
+	if opts.Depth <= 0 {
+		return DefaultOptions().Depth
+	}
+	return opts.Depth
+}
+
+// pieceWorth ranks pieces for spotting forks.
+var pieceWorth = [7]int{0, 1, 3, 3, 5, 9, 100}
+
+// themes describes a solution played by solver from start.
+func themes(start *position.Position, moves []core.Move, solver core.Color, mated bool) []string {
+	var t []string
+	solverMoves := (len(moves) + 1) / 2
+	if mated {
+		t = append(t, "mate", fmt.Sprintf("mateIn%d", solverMoves))
+	}
+	fork, promotion := false, false
+	pos := start
+	for i, m := range moves {
+		next := position.MakeMove(pos, m)
+		if i%2 == 0 {
+			promotion = promotion || m.MoveType() == core.MovePromotion
+			fork = fork || forks(next, m.To(), solver)
+		}
+		pos = next
+	}
+	if fork {
+		t = append(t, "fork")
+	}
+	if promotion {
+		t = append(t, "promotion")
+	}
+	if len(t) == 0 {
+		t = append(t, "advantage")
+	}
+	if solverMoves == 1 {
+		t = append(t, "oneMove")
+	}
+	return t
+}
+
+// forks reports whether the piece of color c on sq attacks two or more
+// enemy pieces that are worth more than it or undefended, the king
+// included.
+func forks(pos *position.Position, sq core.Square, c core.Color) bool {
+	piece := pos.Board.Check(sq)
+	occupied := pos.Board.Occupied()
+	var attacks core.Bitboard
+	switch piece.Type() {
+	case core.Pawn:
+		attacks = movegen.PawnAttacks(sq, c)
+	case core.Knight:
+		attacks = movegen.KnightMoves(sq)
+	case core.Bishop:
+		attacks = movegen.BishopMoves(sq, occupied)
+	case core.Rook:
+		attacks = movegen.RookMoves(sq, occupied)
+	case core.Queen:
+		attacks = movegen.QueenMoves(sq, occupied)
+	case core.King:
+		attacks = movegen.KingMoves(sq)
+	}
+
+	targets := 0
+	for t := range attacks.Intersection(pos.Board.ColorPieces(c.Flip())).Squares() {
+		victim := pos.Board.Check(t).Type()
+		if pieceWorth[victim] > pieceWorth[piece.Type()] || !movegen.IsAttacked(pos, t, c.Flip()) {
+			targets++
+		}
+	}
+	return targets >= 2
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// gameFrom plays a game of SAN moves from a position.
+func gameFrom(t *testing.T, f string, sans ...string) *pgn.Game {
+	start, err := fen.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := pgn.NewGame(start)
+	pos := start
+	for _, san := range sans {
+		m, err := pgn.ParseSAN(pos, san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.AddMove(pos, m)
+		pos = position.MakeMove(pos, m)
+	}
+	return g
+}
+
+func findPuzzles(t *testing.T, g *pgn.Game) []Puzzle {
+	e := search.NewEngine(16)
+	opts := DefaultOptions()
+	opts.Depth = 6
+	return FindPuzzles(e, g, Analyze(e, g, opts, nil), opts)
+}
+
+func TestFindPuzzlesFork(t *testing.T) {
+	// the king walks into a knight fork
+	g := gameFrom(t, "r4k2/8/8/1N6/8/8/PP6/4K3 b - - 0 1", "Ke8", "Nc7+", "Kd7", "Nxa8")
+	puzzles := findPuzzles(t, g)
+	if len(puzzles) != 1 {
+		t.Fatalf("puzzles %v", puzzles)
+	}
+	p := puzzles[0]
+	t.Log(p)
+	if p.Ply != 1 || p.FEN != "r3k3/8/8/1N6/8/8/PP6/4K3 w - - 1 2" {
+		t.Errorf("puzzle at ply %d, %s", p.Ply, p.FEN)
+	}
+	if !strings.HasPrefix(p.UCI(), "b5c7") || p.SAN[0] != "Nc7+" || len(p.Moves)%2 != 1 {
+		t.Errorf("solution %s (%v)", p.UCI(), p.SAN)
+	}
+	if !slices.Contains(p.Themes, "fork") || slices.Contains(p.Themes, "mate") {
+		t.Errorf("themes %v", p.Themes)
+	}
+}
+
+func TestFindPuzzlesMate(t *testing.T) {
+	// Black lets the rook through to the back rank
+	g := gameFrom(t, "r5k1/5ppp/8/8/8/8/P4PPP/3R2K1 b - - 0 1", "Rxa2??", "Rd8#")
+	puzzles := findPuzzles(t, g)
+	if len(puzzles) != 1 {
+		t.Fatalf("puzzles %v", puzzles)
+	}
+	p := puzzles[0]
+	if p.UCI() != "d1d8" || !slices.Equal(p.Themes, []string{"mate", "mateIn1", "oneMove"}) {
+		t.Errorf("puzzle %v", p)
+	}
+}
+
+func TestNoPuzzleInQuietGame(t *testing.T) {
+	g := gameFrom(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "e4", "e5", "Nf3", "Nc6")
+	if puzzles := findPuzzles(t, g); len(puzzles) != 0 {
+		t.Errorf("puzzles %v", puzzles)
+	}
+}
+
+func TestNoPuzzleWithTwoMates(t *testing.T) {
+	// after the blunder either rook mates on the back rank
+	g := gameFrom(t, "r5k1/5ppp/7q/8/8/8/5PPP/1R1R2K1 b - - 0 1", "Ra3??", "Rd8#")
+	if puzzles := findPuzzles(t, g); len(puzzles) != 0 {
+		t.Errorf("puzzles %v", puzzles)
+	}
+}
@@ -0,0 +1,156 @@
+package analysis
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// Report sums up a review for each side, with the moments where the game
+// turned.
+type Report struct {
+	White    SideReport `json:"white"`
+	Black    SideReport `json:"black"`
+	Critical []Moment   `json:"critical"`
+}
+
+// SideReport sums up the moves of one side.
+type SideReport struct {
+	Moves int `json:"moves"`
+
+	// ACPL is the average centipawn loss per move.
+	ACPL float64 `json:"acpl"`
+
+	// Accuracy is the average of each move's accuracy, from 100 for a
+	// move that kept the winning chances to 0 for one that threw them
+	// away, see MoveAccuracy.
+	Accuracy float64 `json:"accuracy"`
+
+	Inaccuracies int `json:"inaccuracies"`
+	Mistakes     int `json:"mistakes"`
+	Blunders     int `json:"blunders"`
+}
+
+// Moment is a move that changed the winning chances a lot.
+type Moment struct {
+	Ply    int    `json:"ply"` // 0 for the first move of the game
+	Number int    `json:"number"`
+	Color  string `json:"color"`
+	SAN    string `json:"san"`
+	Best   string `json:"best,omitempty"`
+	Class  string `json:"class"`
+
+	// Before and After are the evaluations around the move, as in
+	// [%eval] comments, and Swing how many percentage points of winning
+	// chances the move cost or gained White.
+	Before string  `json:"before"`
+	After  string  `json:"after"`
+	Swing  float64 `json:"swing"`
+}
+
+// WinChance turns an evaluation in centipawns into the chance, in percent,
+// that the side it favours goes on to win, with the logistic model Lichess
+// fitted to its games. Mates are certain.
+func WinChance(score int) float64 {
+	if _, ok := search.MateIn(score); ok {
+		if score > 0 {
+			return 100
+		}
+		return 0
+	}
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(score)))-1)
+}
+
+// MoveAccuracy rates a move that took the mover's winning chances from
+// before to after, in percent, on the scale Lichess uses: 100 when nothing
+// was lost, falling steeply with the first points lost.
+func MoveAccuracy(before, after float64) float64 {
+	if after >= before {
+		return 100
+	}
+	a := 103.1668*math.Exp(-0.04354*(before-after)) - 3.1669
+	return min(max(a, 0), 100)
+}
+
+// Report sums up the review, listing up to critical moments where the
+// winning chances swung most, in the order they were played. A critical of
+// 0 or less lists none.
+func (r *Review) Report(critical int) Report {
+	var rep Report
+	var loss, accuracy [2]float64
+	var moments []Moment
+	for i, mv := range r.Moves {
+		side := &rep.White
+		c := 0
+		if mv.Color == core.Black {
+			side = &rep.Black
+			c = 1
+		}
+		side.Moves++
+		switch mv.Class {
+		case Inaccuracy:
+			side.Inaccuracies++
+		case Mistake:
+			side.Mistakes++
+		case Blunder:
+			side.Blunders++
+		}
+		loss[c] += float64(mv.Loss)
+
+		// winning chances for White, then for the mover
+		before, after := WinChance(mv.Before), WinChance(mv.After)
+		swing := after - before
+		if c == 1 {
+			before, after = 100-before, 100-after
+		}
+		if mv.Move == mv.Best {
+			after = max(after, before)
+		}
+		accuracy[c] += MoveAccuracy(before, after)
+
+		m := Moment{
+			Ply:    i,
+			Number: mv.Number,
+			Color:  colorName(mv.Color),
+			SAN:    mv.SAN,
+			Class:  mv.Class.String(),
+			Before: FormatEval(mv.Before),
+			After:  FormatEval(mv.After),
+			Swing:  math.Round(swing*10) / 10,
+		}
+		if mv.Move != mv.Best {
+			m.Best = mv.BestSAN
+		}
+		if m.Swing != 0 {
+			moments = append(moments, m)
+		}
+	}
+
+	for c, side := range []*SideReport{&rep.White, &rep.Black} {
+		if side.Moves > 0 {
+			side.ACPL = math.Round(loss[c]/float64(side.Moves)*10) / 10
+			side.Accuracy = math.Round(accuracy[c]/float64(side.Moves)*10) / 10
+		}
+	}
+
+	// the biggest swings, then back in game order
+	slices.SortStableFunc(moments, func(x, y Moment) int {
+		return cmp.Compare(math.Abs(y.Swing), math.Abs(x.Swing))
+	})
+	moments = moments[:min(max(critical, 0), len(moments))]
+	slices.SortFunc(moments, func(x, y Moment) int {
+		return cmp.Compare(x.Ply, y.Ply)
+	})
+	rep.Critical = moments
+	return rep
+}
+
+func colorName(c core.Color) string {
+	if c == core.Black {
+		return "black"
+	}
+	return "white"
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func TestWinChance(t *testing.T) {
+	if WinChance(0) != 50 {
+		t.Errorf("WinChance(0) = %f", WinChance(0))
+	}
+	if w := WinChance(300); w < 74 || w > 76 {
+		t.Errorf("WinChance(300) = %f, want about 75", w)
+	}
+	if WinChance(200)+WinChance(-200) != 100 {
+		t.Error("WinChance is not symmetric")
+	}
+	if WinChance(search.Mate) != 100 || WinChance(-search.Mate+3) != 0 {
+		t.Error("mates are not certain")
+	}
+}
+
+func TestMoveAccuracy(t *testing.T) {
+	if MoveAccuracy(60, 60) != 100 || MoveAccuracy(40, 55) != 100 {
+		t.Error("a move losing nothing is not perfect")
+	}
+	if a := MoveAccuracy(90, 10); a > 0.01 {
+		t.Errorf("throwing the game away scores %f", a)
+	}
+	small, large := MoveAccuracy(50, 45), MoveAccuracy(50, 30)
+	if !(small > large && small < 100 && large > 0) {
+		t.Errorf("accuracies %f and %f", small, large)
+	}
+}
+
+func TestReport(t *testing.T) {
+	g := scholarsMate(t)
+	opts := DefaultOptions()
+	opts.Depth = 4
+	review := Analyze(search.NewEngine(16), g, opts, nil)
+	rep := review.Report(2)
+
+	if rep.White.Moves != 4 || rep.Black.Moves != 3 {
+		t.Errorf("moves %d and %d", rep.White.Moves, rep.Black.Moves)
+	}
+	if rep.Black.Blunders != 1 || rep.White.Blunders != 0 {
+		t.Errorf("blunders %d and %d", rep.White.Blunders, rep.Black.Blunders)
+	}
+	if rep.Black.ACPL <= rep.White.ACPL || rep.Black.Accuracy >= rep.White.Accuracy {
+		t.Errorf("White %+v, Black %+v", rep.White, rep.Black)
+	}
+	if len(rep.Critical) != 2 || rep.Critical[0].Ply >= rep.Critical[1].Ply {
+		t.Fatalf("critical moments %+v", rep.Critical)
+	}
+	// the blunder swung the game most; the mate that followed changed
+	// nothing
+	nf6 := rep.Critical[1]
+	if nf6.SAN != "Nf6" || nf6.Best == "" || nf6.Swing < 40 {
+		t.Errorf("critical moments %+v", rep.Critical)
+	}
+
+	if rep := review.Report(-1); len(rep.Critical) != 0 {
+		t.Errorf("%d critical moments asked for none", len(rep.Critical))
+	}
+}
@@ -0,0 +1,287 @@
+package analysis
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// SuiteOptions is the budget each position of a test suite is searched
+// with. The search stops at whichever limit it reaches first; limits left
+// at 0 don't apply, but at least one must be set. With no Depth a position
+// is searched no deeper than its acd operation, if it has one.
+type SuiteOptions struct {
+	Depth    int
+	MoveTime time.Duration
+	Nodes    uint64
+	Threads  int
+}
+
+// maxSuiteDepth bounds searches with no depth limit.
+const maxSuiteDepth = 64
+
+// SuiteResult is how the engine did on one position of a test suite.
+type SuiteResult struct {
+	ID  string
+	FEN string
+
+	// Expected describes what solves the position, such as "bm Qg6" or
+	// "dm 3".
+	Expected string
+
+	// The engine's answer and its search.
+	Move  core.Move
+	SAN   string
+	Score int
+	Depth int
+	Nodes uint64
+	Time  time.Duration
+
+	// Solved reports whether the answer meets every expectation. For a
+	// solved position SolvedDepth, SolvedNodes and SolvedTime are where
+	// the search found the solution and kept it to the end.
+	Solved      bool
+	SolvedDepth int
+	SolvedNodes uint64
+	SolvedTime  time.Duration
+}
+
+func (r SuiteResult) String() string {
+	verdict := "failed"
+	if r.Solved {
+		verdict = fmt.Sprintf("solved at depth %d in %v", r.SolvedDepth, r.SolvedTime.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%s: %s, played %s (%s, depth %d): %s", r.ID, r.Expected, r.SAN, FormatEval(r.Score), r.Depth, verdict)
+}
+
+// SuiteReport is the result of running a test suite.
+type SuiteReport struct {
+	Results []SuiteResult
+}
+
+// Solved returns how many positions were solved.
+func (r *SuiteReport) Solved() int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Solved {
+			n++
+		}
+	}
+	return n
+}
+
+// Failures returns the results of the positions that weren't solved.
+func (r *SuiteReport) Failures() []SuiteResult {
+	var failed []SuiteResult
+	for _, res := range r.Results {
+		if !res.Solved {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Nodes returns the nodes searched over the whole suite.
+func (r *SuiteReport) Nodes() uint64 {
+	var n uint64
+	for _, res := range r.Results {
+		n += res.Nodes
+	}
+	return n
+}
+
+// Time returns the time searched over the whole suite.
+func (r *SuiteReport) Time() time.Duration {
+	var t time.Duration
+	for _, res := range r.Results {
+		t += res.Time
+	}
+	return t
+}
+
+// SolvedTime returns the average time to solution of the solved positions.
+func (r *SuiteReport) SolvedTime() time.Duration {
+	var t time.Duration
+	n := 0
+	for _, res := range r.Results {
+		if res.Solved {
+			t += res.SolvedTime
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return t / time.Duration(n)
+}
+
+// expectation is what solves a test position: one of the best moves, none
+// of the moves to avoid, and a mate in at most mate moves, each when given.
+type expectation struct {
+	best, avoid []core.Move
+	mate        int
+}
+
+// newExpectation reads the bm, am and dm operations of a test position.
+func newExpectation(e *fen.EPD) (expectation, error) {
+	var x expectation
+	var err error
+	if x.best, err = parseMoves(e, e.BestMoves()); err != nil {
+		return x, fmt.Errorf("bm: %w", err)
+	}
+	if x.avoid, err = parseMoves(e, e.AvoidMoves()); err != nil {
+		return x, fmt.Errorf("am: %w", err)
+	}
+	if _, ok := e.Ops["dm"]; ok {
+		if x.mate, ok = e.DirectMate(); !ok || x.mate <= 0 {
+			return x, fmt.Errorf("dm: bad mate distance %q", e.Op("dm"))
+		}
+	}
+	if x.best == nil && x.avoid == nil && x.mate == 0 {
+		return x, errors.New("no bm, am or dm operation")
+	}
+	return x, nil
+}
+
+func parseMoves(e *fen.EPD, sans []string) ([]core.Move, error) {
+	var moves []core.Move
+	for _, san := range sans {
+		m, err := pgn.ParseSAN(e.Position, san)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, m)
+	}
+	return moves, nil
+}
+
+// met reports whether a move and its score meet the expectation.
+func (x expectation) met(m core.Move, score int) bool {
+	if x.best != nil && !contains(x.best, m) {
+		return false
+	}
+	if contains(x.avoid, m) {
+		return false
+	}
+	if x.mate > 0 {
+		n, ok := search.MateIn(score)
+		return ok && n > 0 && n <= x.mate
+	}
+	return true
+}
+
+func contains(moves []core.Move, m core.Move) bool {
+	for _, mv := range moves {
+		if mv == m {
+			return true
+		}
+	}
+	return false
+}
+
+// describe writes the expectation the way the EPD did.
+func describe(e *fen.EPD) string {
+	var parts []string
+	for _, op := range []string{"bm", "am", "dm"} {
+		if v, ok := e.Ops[op]; ok {
+			parts = append(parts, op+" "+strings.Join(v, " "))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// RunSuite searches each position of a test suite within the budget and
+// checks the engine's answer against the position's bm, am and dm
+// operations. Every position starts from a cleared hash table. A suite
+// with a position lacking expectations, or with ones that don't parse, is
+// rejected before anything is searched. progress, if set, is called after
+// each position.
+func RunSuite(e *search.Engine, suite []*fen.EPD, opts SuiteOptions, progress func(SuiteResult)) (*SuiteReport, error) {
+	if opts.Depth <= 0 && opts.MoveTime <= 0 && opts.Nodes == 0 {
+		return nil, errors.New("a suite needs a depth, time or node limit")
+	}
+	expected := make([]expectation, len(suite))
+	for i, epd := range suite {
+		x, err := newExpectation(epd)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name(epd, i), err)
+		}
+		expected[i] = x
+	}
+
+	defer fullStrength(e, 1)()
+	savedLimit := e.NodeLimit
+	defer func() { e.NodeLimit = savedLimit }()
+	threads := max(opts.Threads, 1)
+	e.NodeLimit = 0
+	if opts.Nodes > 0 {
+		e.NodeLimit = max(opts.Nodes/uint64(threads), 1)
+	}
+
+	report := &SuiteReport{}
+	for i, epd := range suite {
+		res := runPosition(e, epd, expected[i], opts, threads)
+		res.ID = name(epd, i)
+		report.Results = append(report.Results, res)
+		if progress != nil {
+			progress(res)
+		}
+	}
+	return report, nil
+}
+
+// name returns the id of the i-th position of a suite, or its number.
+func name(e *fen.EPD, i int) string {
+	if id := e.ID(); id != "" {
+		return id
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+// runPosition searches one test position.
+func runPosition(e *search.Engine, epd *fen.EPD, x expectation, opts SuiteOptions, threads int) SuiteResult {
+	pos := epd.Position
+	depth := opts.Depth
+	if depth <= 0 {
+		depth = maxSuiteDepth
+		if acd, ok := epd.Depth(); ok && acd > 0 {
+			depth = acd
+		}
+	}
+
+	e.Clear()
+	e.SetHistory(nil)
+	res := SuiteResult{FEN: fen.Format(pos), Expected: describe(epd)}
+	start := time.Now()
+	solved := false
+	r := e.Search(pos, depth, threads, opts.MoveTime, func(r search.Result) {
+		switch ok := x.met(r.Move, r.Score); {
+		case ok && !solved:
+			solved = true
+			res.SolvedDepth, res.SolvedNodes, res.SolvedTime = r.Depth, r.Nodes, time.Since(start)
+		case !ok:
+			solved = false
+		}
+	})
+	res.Time = time.Since(start)
+
+	res.Move, res.Score, res.Depth, res.Nodes = r.Move, r.Score, r.Depth, r.Nodes
+	if r.Move != core.NoMove {
+		res.SAN = pgn.SAN(pos, r.Move)
+	}
+	res.Solved = r.Move != core.NoMove && x.met(r.Move, r.Score)
+	if res.Solved && !solved {
+		// the final answer differs from the last completed depth's
+		res.SolvedDepth, res.SolvedNodes, res.SolvedTime = r.Depth, r.Nodes, res.Time
+	}
+	if !res.Solved {
+		res.SolvedDepth, res.SolvedNodes, res.SolvedTime = 0, 0, 0
+	}
+	return res
+}
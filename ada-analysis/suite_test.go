@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+const testSuite = `
+# a fork, a back rank mate, and a rook that must not be taken
+r3k3/8/8/1N6/8/8/8/4K3 w - - bm Nc7+; id "fork";
+6k1/5ppp/8/8/8/8/8/R5K1 w - - bm Ra8+; dm 1; id "backrank";
+6k1/5ppp/8/4p3/3r4/8/5PPP/3Q2K1 w - - am Qxd4; id "poisoned";
+`
+
+func TestRunSuite(t *testing.T) {
+	suite, err := fen.ReadEPD(strings.NewReader(testSuite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := search.NewEngine(1)
+	var seen []string
+	report, err := RunSuite(e, suite, SuiteOptions{Depth: 4}, func(r SuiteResult) {
+		seen = append(seen, r.ID)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(seen, ",") != "fork,backrank,poisoned" {
+		t.Errorf("progress saw %v", seen)
+	}
+	if report.Solved() != 3 {
+		for _, r := range report.Failures() {
+			t.Errorf("failed %v", r)
+		}
+	}
+	r := report.Results[1]
+	if r.SAN != "Ra8#" || r.Expected != "bm Ra8+, dm 1" || r.SolvedDepth < 1 || r.SolvedDepth > r.Depth {
+		t.Errorf("back rank result %+v", r)
+	}
+	if report.Nodes() == 0 || report.SolvedTime() > report.Time() {
+		t.Errorf("nodes %d, time %v, solved time %v", report.Nodes(), report.Time(), report.SolvedTime())
+	}
+
+	// a wrong answer fails
+	suite[0].Ops["bm"] = []string{"Nd4"}
+	report, err = RunSuite(e, suite[:1], SuiteOptions{Nodes: 20000}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := report.Failures(); len(f) != 1 || f[0].SolvedDepth != 0 {
+		t.Errorf("failures %v", f)
+	}
+	if e.NodeLimit != 0 {
+		t.Errorf("node limit %d left behind", e.NodeLimit)
+	}
+}
+
+func TestRunSuiteErrors(t *testing.T) {
+	e := search.NewEngine(1)
+	for _, line := range []string{
+		`6k1/8/8/8/8/8/8/R5K1 w - - id "no expectation";`,
+		`6k1/8/8/8/8/8/8/R5K1 w - - bm Qa8;`,
+		`6k1/8/8/8/8/8/8/R5K1 w - - dm x;`,
+	} {
+		epd, err := fen.ParseEPD(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := RunSuite(e, []*fen.EPD{epd}, SuiteOptions{Depth: 1}, nil); err == nil {
+			t.Errorf("%s ran", line)
+		}
+	}
+	epd, _ := fen.ParseEPD(`6k1/8/8/8/8/8/8/R5K1 w - - bm Ra8+;`)
+	if _, err := RunSuite(e, []*fen.EPD{epd}, SuiteOptions{}, nil); err == nil {
+		t.Error("a suite ran without limits")
+	}
+}
@@ -0,0 +1,130 @@
+// Command ada-book builds a Polyglot opening book from PGN games, for the
+// engine to play from with the UCI option BookFile or the TUI's book
+// command.
+//
+//	ada-book [flags] games.pgn ...
+//
+// Each move of the games' openings is counted by how the game went for the
+// side that played it, and weighted two points for a win and one for a
+// draw. A readable listing of the same statistics can be written alongside.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/book"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+)
+
+// filter decides which games go into the book.
+type filter struct {
+	minElo  int
+	results map[string]bool
+}
+
+func (f filter) accept(g *pgn.Game) bool {
+	if !f.results[g.Result] {
+		return false
+	}
+	if f.minElo > 0 {
+		for _, tag := range []string{"WhiteElo", "BlackElo"} {
+			elo, err := strconv.Atoi(g.Tags[tag])
+			if err != nil || elo < f.minElo {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func main() {
+	out      := flag.String("out", "book.bin", "where to write the book")
+	dump     := flag.String("dump", "", "also write a readable listing here (- for standard output)")
+	minElo   := flag.Int("min-elo", 0, "skip games where either player is rated below this or unrated")
+	results  := flag.String("results", "1-0,0-1,1/2-1/2", "comma separated results of the games to use")
+	maxPly   := flag.Int("max-ply", 24, "how many plies of each game to use (0 for all)")
+	minGames := flag.Int("min-games", 1, "leave out moves played in fewer games than this")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-book [flags] games.pgn ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f := filter{minElo: *minElo, results: map[string]bool{}}
+	for _, r := range strings.Split(*results, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			f.results[r] = true
+		}
+	}
+
+	if err := run(flag.Args(), f, *out, *dump, *maxPly, *minGames); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-book:", err)
+		os.Exit(1)
+	}
+}
+
+func run(files []string, f filter, out, dump string, maxPly, minGames int) error {
+	b := book.NewBuilder()
+	read, bad, used := 0, 0, 0
+	for _, name := range files {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		r := pgn.NewReader(file)
+		for {
+			g, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if g == nil {
+				file.Close()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			read++
+			if err != nil {
+				// a bad game spoils only itself
+				bad++
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				continue
+			}
+			if f.accept(g) && b.AddGame(g, maxPly) {
+				used++
+			}
+		}
+		file.Close()
+	}
+
+	bk := b.Book(minGames)
+	if err := bk.Save(out); err != nil {
+		return err
+	}
+	fmt.Printf("%d games read, %d unreadable, %d used\n", read, bad, used)
+	fmt.Printf("%d positions, %d book entries written to %s\n", b.Positions(), bk.Len(), out)
+
+	switch dump {
+	case "":
+	case "-":
+		return b.Dump(os.Stdout, minGames)
+	default:
+		w, err := os.Create(dump)
+		if err != nil {
+			return err
+		}
+		if err := b.Dump(w, minGames); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+	return nil
+}
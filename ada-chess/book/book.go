@@ -0,0 +1,177 @@
+// Package book reads opening books in the Polyglot format.
+//
+// A Polyglot book is a file of 16-byte entries sorted by position key, all
+// numbers big-endian:
+//
+//	key    uint64  Polyglot hash of the position, see Key
+//	move   uint16  bits 0-5 to square, 6-11 from square, 12-14 promotion
+//	weight uint16  how often the move should be played, relative to the
+//	               other moves of the position
+//	learn  uint32  unused here
+//
+// Squares are numbered a1=0 to h8=63 like core.Square. Promotions count
+// knight=1 to queen=4, and castling is written as the king taking its own
+// rook, e1h1 for white short castling.
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+const entrySize = 16
+
+// entry is one record of the book file.
+type entry struct {
+	key    uint64
+	move   uint16
+	weight uint16
+	learn  uint32
+}
+
+// Book is an opening book held in memory, sorted by key.
+type Book struct {
+	entries []entry
+}
+
+// Move is a book move for a position with its weight.
+type Move struct {
+	Move   core.Move
+	Weight int
+}
+
+// Load reads a book file.
+func Load(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b, err := Read(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Read reads a book from r. Entries out of order are sorted, so a book
+// written by hand or by another tool still works.
+func Read(r io.Reader) (*Book, error) {
+	b := &Book{}
+	var buf [entrySize]byte
+	for {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, errors.New("book: truncated entry")
+			}
+			return nil, err
+		}
+		b.entries = append(b.entries, entry{
+			key:    binary.BigEndian.Uint64(buf[0:8]),
+			move:   binary.BigEndian.Uint16(buf[8:10]),
+			weight: binary.BigEndian.Uint16(buf[10:12]),
+			learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+	if !slices.IsSortedFunc(b.entries, compareEntries) {
+		slices.SortStableFunc(b.entries, compareEntries)
+	}
+	return b, nil
+}
+
+func compareEntries(x, y entry) int {
+	switch {
+	case x.key < y.key:
+		return -1
+	case x.key > y.key:
+		return 1
+	}
+	return 0
+}
+
+// Len returns the number of entries in the book.
+func (b *Book) Len() int {
+	return len(b.entries)
+}
+
+// Moves returns the book moves for a position, highest weight first. Moves
+// that aren't legal in the position, which a key collision can produce, are
+// left out, as are moves of weight 0.
+func (b *Book) Moves(pos *position.Position) []Move {
+	key := Key(pos)
+	first := sort.Search(len(b.entries), func(i int) bool { return b.entries[i].key >= key })
+
+	var moves []Move
+	legal := movegen.LegalMoves(pos)
+	for i := first; i < len(b.entries) && b.entries[i].key == key; i++ {
+		e := b.entries[i]
+		if e.weight == 0 {
+			continue
+		}
+		for j := 0; j < legal.Count(); j++ {
+			if m := legal.Get(j); encodeMove(m) == e.move {
+				moves = append(moves, Move{Move: m, Weight: int(e.weight)})
+				break
+			}
+		}
+	}
+	slices.SortStableFunc(moves, func(x, y Move) int { return y.Weight - x.Weight })
+	return moves
+}
+
+// Pick chooses a book move for a position. With random set it picks among
+// the book moves in proportion to their weights, otherwise it always takes
+// the heaviest. It reports false when the position isn't in the book.
+func (b *Book) Pick(pos *position.Position, random bool) (core.Move, bool) {
+	moves := b.Moves(pos)
+	if len(moves) == 0 {
+		return core.NoMove, false
+	}
+	if !random {
+		return moves[0].Move, true
+	}
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	n := rand.IntN(total)
+	for _, m := range moves {
+		if n < m.Weight {
+			return m.Move, true
+		}
+		n -= m.Weight
+	}
+	return moves[0].Move, true
+}
+
+// encodeMove writes a move the way Polyglot books store it.
+func encodeMove(m core.Move) uint16 {
+	from, to := m.From(), m.To()
+	if m.MoveType() == core.MoveCastling {
+		// the king moves onto its rook
+		if to.File() > from.File() {
+			to = core.NewSquare(to.Rank(), 7)
+		} else {
+			to = core.NewSquare(to.Rank(), 0)
+		}
+	}
+	v := uint16(to) | uint16(from)<<6
+	if m.MoveType() == core.MovePromotion {
+		v |= uint16(m.PromoPiece()-core.Knight+1) << 12
+	}
+	return v
+}
@@ -0,0 +1,135 @@
+package book
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// bookFile encodes entries the way a Polyglot book stores them.
+func bookFile(entries ...entry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		binary.Write(&buf, binary.BigEndian, e)
+	}
+	return buf.Bytes()
+}
+
+// polyglotMove encodes a move given as from and to square names.
+func polyglotMove(from, to string, promo uint16) uint16 {
+	sq := func(s string) uint16 { return uint16(s[1]-'1')*8 + uint16(s[0]-'a') }
+	return sq(to) | sq(from)<<6 | promo<<12
+}
+
+func mustParse(t *testing.T, f string) *position.Position {
+	t.Helper()
+	pos, err := fen.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pos
+}
+
+func TestKey(t *testing.T) {
+	// the examples from the Polyglot format description
+	tests := []struct {
+		fen string
+		key uint64
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 0x463b96181691fc9c},
+		{"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", 0x823c9b50fd114196},
+		{"rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", 0x0756b94461c50fb0},
+		{"rnbqkbnr/ppp1pppp/8/3pP3/8/8/PPPP1PPP/RNBQKBNR b KQkq - 0 2", 0x662fafb965db29d4},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3", 0x22a48b5a8e47ff78},
+		{"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR b kq - 0 3", 0x652a607ca3f242c1},
+		{"rnbq1bnr/ppp1pkpp/8/3pPp2/8/8/PPPPKPPP/RNBQ1BNR w - - 0 4", 0x00fdd303c946bdd9},
+		{"rnbqkbnr/p1pppppp/8/8/PpP4P/8/1P1PPPP1/RNBQKBNR b KQkq c3 0 3", 0x3c8123ea7b067637},
+		{"rnbqkbnr/p1pppppp/8/8/P6P/R1p5/1P1PPPP1/1NBQKBNR b Kkq - 0 4", 0x5c3f9b829b279560},
+	}
+	for _, tc := range tests {
+		if got := Key(mustParse(t, tc.fen)); got != tc.key {
+			t.Errorf("%s: key %016x, want %016x", tc.fen, got, tc.key)
+		}
+	}
+}
+
+func TestMoves(t *testing.T) {
+	pos := mustParse(t, startFEN)
+	key := Key(pos)
+	data := bookFile(
+		entry{key: key + 1, move: polyglotMove("a2", "a3", 0), weight: 50},
+		entry{key: key, move: polyglotMove("d2", "d4", 0), weight: 10},
+		entry{key: key, move: polyglotMove("e2", "e4", 0), weight: 30},
+		entry{key: key, move: polyglotMove("e2", "e5", 0), weight: 90}, // not legal
+		entry{key: key, move: polyglotMove("g1", "f3", 0), weight: 0},
+		entry{key: key - 1, move: polyglotMove("h2", "h3", 0), weight: 50},
+	)
+	b, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 6 {
+		t.Fatalf("Len = %d, want 6", b.Len())
+	}
+
+	moves := b.Moves(pos)
+	if len(moves) != 2 || moves[0].Move.String() != "e2e4" || moves[0].Weight != 30 || moves[1].Move.String() != "d2d4" {
+		t.Fatalf("Moves = %v", moves)
+	}
+	if m, ok := b.Pick(pos, false); !ok || m.String() != "e2e4" {
+		t.Errorf("Pick = %v %v, want e2e4", m, ok)
+	}
+
+	seen := map[string]int{}
+	for i := 0; i < 400; i++ {
+		m, _ := b.Pick(pos, true)
+		seen[m.String()]++
+	}
+	if len(seen) != 2 || seen["d2d4"] == 0 || seen["e2e4"] < seen["d2d4"] {
+		t.Errorf("random picks %v, want both moves and e2e4 more often", seen)
+	}
+
+	if _, ok := b.Pick(position.MakeMove(pos, moves[0].Move), false); ok {
+		t.Error("found a move for a position not in the book")
+	}
+}
+
+func TestSpecialMoves(t *testing.T) {
+	castle := mustParse(t, "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	promote := mustParse(t, "8/1P5k/8/8/8/8/8/K7 w - - 0 1")
+	b, err := Read(bytes.NewReader(bookFile(
+		entry{key: Key(castle), move: polyglotMove("e1", "h1", 0), weight: 1},
+		entry{key: Key(castle), move: polyglotMove("e1", "a1", 0), weight: 1},
+		entry{key: Key(promote), move: polyglotMove("b7", "b8", 1), weight: 1},
+	)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moves := b.Moves(castle)
+	if len(moves) != 2 || moves[0].Move.MoveType() != core.MoveCastling || moves[1].Move.MoveType() != core.MoveCastling {
+		t.Fatalf("castling moves = %v", moves)
+	}
+	if m, ok := b.Pick(promote, false); !ok || m.MoveType() != core.MovePromotion || m.PromoPiece() != core.Knight {
+		t.Errorf("promotion = %v %v, want b7b8n", m, ok)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	data := bookFile(entry{key: 1, move: 2, weight: 3})
+	if _, err := Read(bytes.NewReader(data[:10])); err == nil {
+		t.Error("expected an error for a truncated book")
+	}
+	if b, err := Read(bytes.NewReader(nil)); err != nil || b.Len() != 0 {
+		t.Errorf("empty book: %v %v", b, err)
+	}
+	if _, err := Load("does-not-exist.bin"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,232 @@
+package book
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Outcome is how a game went for one side.
+type Outcome int
+
+const (
+	Loss Outcome = iota
+	Draw
+	Win
+)
+
+// Stats counts the games a move was played in by how they went for the
+// side that played it.
+type Stats struct {
+	Wins, Draws, Losses int
+}
+
+// Games returns the number of games counted.
+func (s Stats) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Weight is the book weight the move gets, two points for each win and one
+// for each draw, as Polyglot weighs its books.
+func (s Stats) Weight() int {
+	return 2*s.Wins + s.Draws
+}
+
+// Builder collects move statistics from games to make a book.
+type Builder struct {
+	positions map[uint64]*positionStats
+}
+
+type positionStats struct {
+	pos   *position.Position // the first game's, for the dump
+	moves map[uint16]*moveStats
+}
+
+type moveStats struct {
+	move core.Move
+	Stats
+}
+
+// NewBuilder returns an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{positions: map[uint64]*positionStats{}}
+}
+
+// Positions returns how many positions have moves recorded.
+func (b *Builder) Positions() int {
+	return len(b.positions)
+}
+
+// Add records that m was played in pos in a game that went as given for
+// the side that played it.
+func (b *Builder) Add(pos *position.Position, m core.Move, result Outcome) {
+	key := Key(pos)
+	ps, ok := b.positions[key]
+	if !ok {
+		ps = &positionStats{pos: pos, moves: map[uint16]*moveStats{}}
+		b.positions[key] = ps
+	}
+	ms, ok := ps.moves[encodeMove(m)]
+	if !ok {
+		ms = &moveStats{move: m}
+		ps.moves[encodeMove(m)] = ms
+	}
+	switch result {
+	case Win:
+		ms.Wins++
+	case Draw:
+		ms.Draws++
+	default:
+		ms.Losses++
+	}
+}
+
+// AddGame records the first maxPly moves of a game, or all of them when
+// maxPly is 0. Games without a decided result are left out, and AddGame
+// reports whether the game was used.
+func (b *Builder) AddGame(g *pgn.Game, maxPly int) bool {
+	var white Outcome
+	switch g.Result {
+	case "1-0":
+		white = Win
+	case "0-1":
+		white = Loss
+	case "1/2-1/2":
+		white = Draw
+	default:
+		return false
+	}
+	positions := g.Positions()
+	for i, m := range g.Moves() {
+		if maxPly > 0 && i >= maxPly {
+			break
+		}
+		result := white
+		if positions[i].ActiveColor == core.Black {
+			result = Win - white
+		}
+		b.Add(positions[i], m, result)
+	}
+	return true
+}
+
+// Book makes a book of the moves played in at least minGames games. Moves
+// that never scored, and so weigh nothing, are left out. Weights are scaled
+// down when the heaviest wouldn't fit the file format.
+func (b *Builder) Book(minGames int) *Book {
+	heaviest := 0
+	for _, ps := range b.positions {
+		for _, ms := range ps.moves {
+			if ms.Games() >= minGames {
+				heaviest = max(heaviest, ms.Weight())
+			}
+		}
+	}
+
+	book := &Book{}
+	for key, ps := range b.positions {
+		for code, ms := range ps.moves {
+			w := ms.Weight()
+			if ms.Games() < minGames || w == 0 {
+				continue
+			}
+			if heaviest > 0xFFFF {
+				w = max(1, w*0xFFFF/heaviest)
+			}
+			book.entries = append(book.entries, entry{key: key, move: code, weight: uint16(w)})
+		}
+	}
+	slices.SortFunc(book.entries, func(x, y entry) int {
+		if c := compareEntries(x, y); c != 0 {
+			return c
+		}
+		return cmp.Compare(y.weight, x.weight)
+	})
+	return book
+}
+
+// Dump writes the moves played in at least minGames games in a readable
+// form: each position as FEN with its key, then its moves, most played
+// first, with their weights and results.
+func (b *Builder) Dump(w io.Writer, minGames int) error {
+	keys := make([]uint64, 0, len(b.positions))
+	for key := range b.positions {
+		keys = append(keys, key)
+	}
+	// earlier positions first
+	slices.SortFunc(keys, func(x, y uint64) int {
+		px, py := b.positions[x].pos, b.positions[y].pos
+		if c := cmp.Compare(px.Fullmoves, py.Fullmoves); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(px.ActiveColor, py.ActiveColor); c != 0 {
+			return c
+		}
+		return cmp.Compare(x, y)
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, key := range keys {
+		ps := b.positions[key]
+		var moves []*moveStats
+		for _, ms := range ps.moves {
+			if ms.Games() >= minGames {
+				moves = append(moves, ms)
+			}
+		}
+		if len(moves) == 0 {
+			continue
+		}
+		slices.SortFunc(moves, func(x, y *moveStats) int {
+			if c := cmp.Compare(y.Games(), x.Games()); c != 0 {
+				return c
+			}
+			return cmp.Compare(x.move, y.move)
+		})
+
+		fmt.Fprintf(bw, "%s  key %016x\n", fen.Format(ps.pos), key)
+		for _, ms := range moves {
+			fmt.Fprintf(bw, "  %-7s %-5s weight %6d  games %5d  +%d =%d -%d\n",
+				pgn.SAN(ps.pos, ms.move), ms.move, ms.Weight(), ms.Games(), ms.Wins, ms.Draws, ms.Losses)
+		}
+	}
+	return bw.Flush()
+}
+
+// Write writes the book in the Polyglot format.
+func (b *Book) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var buf [entrySize]byte
+	for _, e := range b.entries {
+		binary.BigEndian.PutUint64(buf[0:8], e.key)
+		binary.BigEndian.PutUint16(buf[8:10], e.move)
+		binary.BigEndian.PutUint16(buf[10:12], e.weight)
+		binary.BigEndian.PutUint32(buf[12:16], e.learn)
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Save writes the book to a file.
+func (b *Book) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+package book
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+)
+
+const buildGames = `
+[Result "1-0"]
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Result "1/2-1/2"]
+1. e4 c5 2. Nf3 1/2-1/2
+
+[Result "0-1"]
+1. d4 d5 0-1
+
+[Result "*"]
+1. c4 *
+`
+
+func buildBook(t *testing.T) *Builder {
+	t.Helper()
+	games, bad, err := pgn.ReadAll(strings.NewReader(buildGames))
+	if err != nil || len(bad) > 0 {
+		t.Fatal(err, bad)
+	}
+	b := NewBuilder()
+	used := 0
+	for _, g := range games {
+		if b.AddGame(g, 3) {
+			used++
+		}
+	}
+	if used != 3 {
+		t.Fatalf("used %d games, want 3", used)
+	}
+	return b
+}
+
+func weights(moves []Move) map[string]int {
+	w := map[string]int{}
+	for _, m := range moves {
+		w[m.Move.String()] = m.Weight
+	}
+	return w
+}
+
+func TestBuilder(t *testing.T) {
+	b := buildBook(t)
+	var buf bytes.Buffer
+	if err := b.Book(1).Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	bk, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// e4 won once and drew once; d4 lost and weighs nothing
+	start := mustParse(t, startFEN)
+	if w := weights(bk.Moves(start)); len(w) != 1 || w["e2e4"] != 3 {
+		t.Errorf("start: %v", w)
+	}
+
+	// after 1. e4 black lost with e5 and drew with c5
+	afterE4 := mustParse(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	if w := weights(bk.Moves(afterE4)); len(w) != 1 || w["c7c5"] != 1 {
+		t.Errorf("after e4: %v", w)
+	}
+
+	// the fourth ply is past maxPly
+	afterNf3 := mustParse(t, "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+	if moves := bk.Moves(afterNf3); len(moves) != 0 {
+		t.Errorf("moves beyond maxPly: %v", moves)
+	}
+
+	// only e4 was played twice
+	if got := b.Book(2); got.Len() != 1 {
+		t.Errorf("%d entries played twice, want 1", got.Len())
+	}
+}
+
+func TestBuilderScalesWeights(t *testing.T) {
+	b := NewBuilder()
+	pos := mustParse(t, startFEN)
+	legal := movegen.LegalMoves(pos)
+	first, second := legal.Get(0), legal.Get(1)
+	for i := 0; i < 40000; i++ {
+		b.Add(pos, first, Win)
+	}
+	b.Add(pos, second, Draw)
+	w := weights(b.Book(1).Moves(pos))
+	if w[first.String()] != 0xFFFF || w[second.String()] != 1 {
+		t.Errorf("weights %v", w)
+	}
+}
+
+func TestDump(t *testing.T) {
+	var buf bytes.Buffer
+	if err := buildBook(t).Dump(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		startFEN + "  key 463b96181691fc9c",
+		"  e4      e2e4  weight      3  games     2  +1 =1 -0",
+		"  d4      d2d4  weight      0  games     1  +0 =0 -1",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump lacks %q:\n%s", want, out)
+		}
+	}
+	if !strings.HasPrefix(out, startFEN) {
+		t.Errorf("dump should start with the starting position:\n%s", out)
+	}
+}
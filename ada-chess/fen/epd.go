@@ -0,0 +1,226 @@
+package fen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// EPD is a position from an Extended Position Description line with the
+// operations that follow it, such as the best move of a test position.
+type EPD struct {
+	Position *position.Position
+
+	// Ops holds each operation's operands by opcode, with the quotes of
+	// string operands removed.
+	Ops map[string][]string
+
+	// order is the opcodes as they appeared, for Format
+	order []string
+}
+
+// ParseEPD parses an EPD line: the first four fields of a FEN followed by
+// operations, each an opcode and its operands ended by a semicolon. The
+// halfmove clock and move number come from the hmvc and fmvn operations,
+// or from two plain numbers after the fields as some files write them.
+func ParseEPD(line string) (*EPD, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 4 {
+		return nil, errors.New("invalid epd format: too few fields")
+	}
+
+	// skip past the four position fields in the original text, as string
+	// operands keep their spacing
+	rest := strings.TrimSpace(line)
+	for i := 0; i < 4; i++ {
+		rest = strings.TrimSpace(rest[len(fields[i]):])
+	}
+
+	clocks := "0 1"
+	if len(fields) >= 6 && isClock(fields[4]) && isClock(fields[5]) {
+		clocks = fields[4] + " " + fields[5]
+		rest = strings.TrimSpace(rest[len(fields[4]):])
+		rest = strings.TrimSpace(rest[len(fields[5]):])
+	}
+
+	e := &EPD{Ops: map[string][]string{}}
+	ops, err := parseOperations(rest)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if _, ok := e.Ops[op[0]]; ok {
+			return nil, fmt.Errorf("invalid epd: repeated opcode %s", op[0])
+		}
+		e.Ops[op[0]] = op[1:]
+		e.order = append(e.order, op[0])
+	}
+
+	if v, ok := e.Int("hmvc"); ok {
+		clocks = strconv.Itoa(v) + clocks[strings.IndexByte(clocks, ' '):]
+	}
+	if v, ok := e.Int("fmvn"); ok {
+		clocks = clocks[:strings.IndexByte(clocks, ' ')+1] + strconv.Itoa(v)
+	}
+
+	pos, err := Parse(strings.Join(fields[:4], " ") + " " + clocks)
+	if err != nil {
+		return nil, err
+	}
+	e.Position = pos
+	return e, nil
+}
+
+// isStringOp reports whether an opcode's operand is always a string: the
+// id and the comments c0 to c9.
+func isStringOp(opcode string) bool {
+	return opcode == "id" || len(opcode) == 2 && opcode[0] == 'c' && opcode[1] >= '0' && opcode[1] <= '9'
+}
+
+func isClock(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// parseOperations splits the operations of an EPD line into opcodes and
+// their operands.
+func parseOperations(s string) ([][]string, error) {
+	var ops [][]string
+	var op []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == ';':
+			if len(op) == 0 {
+				return nil, errors.New("invalid epd: empty operation")
+			}
+			ops = append(ops, op)
+			op = nil
+			i++
+		case c == '"':
+			if len(op) == 0 {
+				return nil, errors.New("invalid epd: operation without an opcode")
+			}
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, errors.New("invalid epd: unterminated string")
+			}
+			op = append(op, s[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexAny(s[i:], " \t;")
+			if end < 0 {
+				end = len(s) - i
+			}
+			op = append(op, s[i:i+end])
+			i += end
+		}
+	}
+	// tolerate a last operation without its semicolon
+	if len(op) > 0 {
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// ReadEPD reads every EPD line of r. Blank lines and lines starting with #
+// are skipped.
+func ReadEPD(r io.Reader) ([]*EPD, error) {
+	var epds []*EPD
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		epds = append(epds, e)
+	}
+	return epds, sc.Err()
+}
+
+// Op returns the first operand of an operation, or "".
+func (e *EPD) Op(opcode string) string {
+	if v := e.Ops[opcode]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Int returns the value of an operation with a number operand.
+func (e *EPD) Int(opcode string) (int, bool) {
+	v, err := strconv.Atoi(e.Op(opcode))
+	return v, err == nil
+}
+
+// ID returns the id operation, the position's name in its suite.
+func (e *EPD) ID() string {
+	return e.Op("id")
+}
+
+// Comment returns the c0 operation.
+func (e *EPD) Comment() string {
+	return e.Op("c0")
+}
+
+// BestMoves returns the moves of the bm operation, in SAN.
+func (e *EPD) BestMoves() []string {
+	return e.Ops["bm"]
+}
+
+// AvoidMoves returns the moves of the am operation, in SAN.
+func (e *EPD) AvoidMoves() []string {
+	return e.Ops["am"]
+}
+
+// DirectMate returns the number of moves of the dm operation, the side to
+// move mating in that many.
+func (e *EPD) DirectMate() (int, bool) {
+	return e.Int("dm")
+}
+
+// Depth returns the acd operation, the depth the position was analysed to.
+func (e *EPD) Depth() (int, bool) {
+	return e.Int("acd")
+}
+
+// Format writes the position and operations back as an EPD line.
+func (e *EPD) Format() string {
+	fields := strings.Fields(Format(e.Position))
+	var sb strings.Builder
+	sb.WriteString(strings.Join(fields[:4], " "))
+	// operations in the order read, then any added since
+	opcodes := slices.DeleteFunc(slices.Clone(e.order), func(op string) bool {
+		_, ok := e.Ops[op]
+		return !ok
+	})
+	var added []string
+	for op := range e.Ops {
+		if !slices.Contains(opcodes, op) {
+			added = append(added, op)
+		}
+	}
+	slices.Sort(added)
+
+	for _, opcode := range append(opcodes, added...) {
+		sb.WriteString(" " + opcode)
+		for _, v := range e.Ops[opcode] {
+			if v == "" || strings.ContainsAny(v, " \t;\"") || isStringOp(opcode) {
+				v = `"` + v + `"`
+			}
+			sb.WriteString(" " + v)
+		}
+		sb.WriteByte(';')
+	}
+	return sb.String()
+}
@@ -0,0 +1,81 @@
+package fen
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEPD(t *testing.T) {
+	line := `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "a; b"; acd 12;`
+	e, err := ParseEPD(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Format(e.Position); got != "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1" {
+		t.Errorf("position %s", got)
+	}
+	if e.ID() != "WAC.001" || e.Comment() != "a; b" {
+		t.Errorf("id %q, comment %q", e.ID(), e.Comment())
+	}
+	if !reflect.DeepEqual(e.BestMoves(), []string{"Qg6"}) || e.AvoidMoves() != nil {
+		t.Errorf("bm %v, am %v", e.BestMoves(), e.AvoidMoves())
+	}
+	if d, ok := e.Depth(); !ok || d != 12 {
+		t.Errorf("acd %d %v", d, ok)
+	}
+	if _, ok := e.DirectMate(); ok {
+		t.Error("dm without the operation")
+	}
+	if got := e.Format(); got != `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; c0 "a; b"; acd 12;` {
+		t.Errorf("formatted as %s", got)
+	}
+}
+
+func TestEPDClocks(t *testing.T) {
+	for line, want := range map[string]string{
+		"8/8/4k3/8/8/3K4/8/8 b - - 7 40 dm 1":         "8/8/4k3/8/8/3K4/8/8 b - - 7 40",
+		"8/8/4k3/8/8/3K4/8/8 b - - hmvc 3; fmvn 22; ": "8/8/4k3/8/8/3K4/8/8 b - - 3 22",
+		"8/8/4k3/8/8/3K4/8/8 w - - am Kc2 Kd2; dm 4;": "8/8/4k3/8/8/3K4/8/8 w - - 0 1",
+	} {
+		e, err := ParseEPD(line)
+		if err != nil {
+			t.Errorf("%s: %v", line, err)
+			continue
+		}
+		if got := Format(e.Position); got != want {
+			t.Errorf("%s: %s, want %s", line, got, want)
+		}
+	}
+	e, _ := ParseEPD("8/8/4k3/8/8/3K4/8/8 w - - am Kc2 Kd2; dm 4;")
+	if n, ok := e.DirectMate(); !ok || n != 4 || len(e.AvoidMoves()) != 2 {
+		t.Errorf("dm %d, am %v", n, e.AvoidMoves())
+	}
+}
+
+func TestParseEPDErrors(t *testing.T) {
+	for _, line := range []string{
+		"8/8/4k3/8/8/3K4/8 w - -",
+		"8/8/4k3/8/8/3K4/8/8 w -",
+		`8/8/4k3/8/8/3K4/8/8 w - - id "unterminated;`,
+		"8/8/4k3/8/8/3K4/8/8 w - - bm Kc2; bm Kd2;",
+		"8/8/4k3/8/8/3K4/8/8 w - - ;",
+	} {
+		if _, err := ParseEPD(line); err == nil {
+			t.Errorf("%s parsed", line)
+		}
+	}
+}
+
+func TestReadEPD(t *testing.T) {
+	text := "# suite\n\n" +
+		"8/8/4k3/8/8/3K4/8/8 w - - id \"one\";\n" +
+		"8/8/4k3/8/8/3K4/8/8 b - - id \"two\";\n"
+	epds, err := ReadEPD(strings.NewReader(text))
+	if err != nil || len(epds) != 2 || epds[1].ID() != "two" {
+		t.Fatalf("read %d: %v", len(epds), err)
+	}
+	if _, err := ReadEPD(strings.NewReader("8/8 w - -\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("error %v", err)
+	}
+}
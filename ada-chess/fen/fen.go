@@ -184,11 +184,60 @@ func Parse(fen string) (*position.Position, error) {
 	}
 	pos.Fullmoves = fullmove
 
+	// hashes
+	pos.Zobrist = pos.ComputeZobrist()
+	pos.PawnKey = pos.ComputePawnKey()
+
 	// ok
 	return pos, nil
 }
 
 // output a Position into a fen string
 func Format(pos *position.Position) string {
-	return "NOT IMPLEMENTED"
+	var sb strings.Builder
+
+	// pieces, from the eighth rank down
+	for rank := 7; rank >= 0; rank-- {
+		empty := 0
+		for file := 0; file < 8; file++ {
+			piece := pos.Board.Check(core.NewSquare(rank, file))
+			if piece == core.None {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteString(piece.String())
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if rank > 0 {
+			sb.WriteByte('/')
+		}
+	}
+
+	// active color
+	if pos.ActiveColor == core.Black {
+		sb.WriteString(" b ")
+	} else {
+		sb.WriteString(" w ")
+	}
+
+	// castling and en passant
+	sb.WriteString(pos.Castling.String())
+	sb.WriteByte(' ')
+	if pos.EnPassant.Valid() {
+		sb.WriteString(pos.EnPassant.String())
+	} else {
+		sb.WriteByte('-')
+	}
+
+	// clocks
+	fullmoves := max(pos.Fullmoves, 1)
+	sb.WriteString(" " + strconv.Itoa(pos.Halfmoves) + " " + strconv.Itoa(fullmoves))
+
+	return sb.String()
 }
@@ -187,3 +187,30 @@ func TestEnPassant(t *testing.T) {
 		t.Errorf("en passant: got %s, expected %s", pos.EnPassant.String(), expect.String())
 	}
 }
+
+func TestHashes(t *testing.T) {
+	a, _ := Parse(starting)
+	b, _ := Parse(italian)
+
+	if a.Zobrist != a.ComputeZobrist() || a.PawnKey != a.ComputePawnKey() {
+		t.Error("parsed position hashes not computed")
+	}
+	if a.Zobrist == b.Zobrist {
+		t.Error("different positions should have different hashes")
+	}
+	if a.PawnKey == b.PawnKey {
+		t.Error("different pawn structures should have different pawn keys")
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	for _, f := range []string{starting, italian, ep, bcastle, wcastle, nocastle, "8/8/8/8/8/4k3/5R2/K7 w - - 57 103"} {
+		pos, err := Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Format(pos); got != f {
+			t.Errorf("Format(Parse(%q)) = %q", f, got)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package movegen
+
+import (
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// LegalQuietChecks generates the legal moves that give check without
+// capturing or promoting. Together with LegalCaptures this covers every
+// forcing move.
+func LegalQuietChecks(pos *position.Position) core.MoveList {
+	var ml core.MoveList
+
+	color := pos.ActiveColor
+	enemy := color.Flip()
+	occupied := pos.Board.Occupied()
+	theirKing := kingSquare(pos, enemy)
+
+	// squares each piece type gives a direct check from
+	var checkSquares [7]core.Bitboard
+	checkSquares[core.Pawn] = PawnAttacks(theirKing, enemy)
+	checkSquares[core.Knight] = KnightMoves(theirKing)
+	checkSquares[core.Bishop] = BishopMoves(theirKing, occupied)
+	checkSquares[core.Rook] = RookMoves(theirKing, occupied)
+	checkSquares[core.Queen] = checkSquares[core.Bishop].Union(checkSquares[core.Rook])
+
+	discoveries := discoveryBlockers(pos, theirKing, color)
+
+	legal := LegalMoves(pos)
+	for i := 0; i < legal.Count(); i++ {
+		m := legal.Get(i)
+		if occupied.Check(m.To()) || m.MoveType() == core.MoveEnPassant || m.MoveType() == core.MovePromotion {
+			continue
+		}
+
+		// the castling rook may give check, simplest to just play it
+		if m.MoveType() == core.MoveCastling {
+			if InCheck(position.MakeMove(pos, m)) {
+				ml.Add(m)
+			}
+			continue
+		}
+
+		piece := pos.Board.Check(m.From()).Type()
+		if checkSquares[piece].Check(m.To()) {
+			ml.Add(m)
+			continue
+		}
+
+		// a blocker moving off its line uncovers the slider behind it
+		if discoveries.Pinned.Check(m.From()) && !discoveries.Rays[m.From()].Check(m.To()) {
+			ml.Add(m)
+		}
+	}
+
+	return ml
+}
+
+// discoveryBlockers finds pieces of color us that are the only piece between
+// one of our sliders and the enemy king on kingSq. The result uses PinState's
+// layout: Rays holds the line each blocker must leave to give check.
+func discoveryBlockers(pos *position.Position, kingSq core.Square, us core.Color) PinState {
+	var state PinState
+
+	occupied := pos.Board.Occupied()
+	ours := pos.Board.ColorPieces(us)
+
+	rq := pos.Board.Pieces(core.NewPiece(core.Rook, us)).Union(
+		pos.Board.Pieces(core.NewPiece(core.Queen, us)))
+	bq := pos.Board.Pieces(core.NewPiece(core.Bishop, us)).Union(
+		pos.Board.Pieces(core.NewPiece(core.Queen, us)))
+
+	// look through our own pieces from the enemy king to find sliders that
+	// are only held back by them
+	transparent := occupied.Subtract(ours)
+
+	for s := range RookMoves(kingSq, transparent).Intersection(rq).Squares() {
+		between := rookBetween(kingSq, s)
+		blockers := between.Intersection(occupied)
+		if blockers.Count() == 1 && !blockers.Intersection(ours).Empty() {
+			for b := range blockers.Squares() {
+				state.Pinned = state.Pinned.Set(b)
+				state.Rays[b] = between.Set(s)
+			}
+		}
+	}
+
+	for s := range BishopMoves(kingSq, transparent).Intersection(bq).Squares() {
+		between := bishopBetween(kingSq, s)
+		blockers := between.Intersection(occupied)
+		if blockers.Count() == 1 && !blockers.Intersection(ours).Empty() {
+			for b := range blockers.Squares() {
+				state.Pinned = state.Pinned.Set(b)
+				state.Rays[b] = between.Set(s)
+			}
+		}
+	}
+
+	return state
+}
@@ -0,0 +1,84 @@
+package movegen_test
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// bruteQuietChecks plays every legal quiet move and keeps those that check.
+func bruteQuietChecks(pos *position.Position) map[core.Move]bool {
+	want := make(map[core.Move]bool)
+	legal := movegen.LegalMoves(pos)
+	for i := 0; i < legal.Count(); i++ {
+		m := legal.Get(i)
+		if pos.Board.HasPiece(m.To()) || m.MoveType() == core.MoveEnPassant || m.MoveType() == core.MovePromotion {
+			continue
+		}
+		if movegen.InCheck(position.MakeMove(pos, m)) {
+			want[m] = true
+		}
+	}
+	return want
+}
+
+func TestLegalQuietChecks_MatchesBruteForce(t *testing.T) {
+	positions := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r1bqkbnr/pppp1ppp/2n5/4p3/2B1P3/5Q2/PPPP1PPP/RNB1K1NR w KQkq - 2 3",
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1",
+		// discovered checks: rook behind a knight, bishop behind a pawn
+		"4k3/8/8/8/4N3/8/8/4R1K1 w - - 0 1",
+		"7k/8/8/8/3P4/8/1B6/6K1 w - - 0 1",
+		// castling into check with the rook
+		"5k2/8/8/8/8/8/8/4K2R w K - 0 1",
+	}
+
+	for _, f := range positions {
+		pos, err := fen.Parse(f)
+		if err != nil {
+			t.Fatalf("bad FEN %q: %v", f, err)
+		}
+
+		want := bruteQuietChecks(pos)
+		got := movegen.LegalQuietChecks(pos)
+
+		seen := make(map[core.Move]bool)
+		for i := 0; i < got.Count(); i++ {
+			m := got.Get(i)
+			if !want[m] {
+				t.Errorf("FEN %q: %s is not a quiet check", f, m)
+			}
+			if seen[m] {
+				t.Errorf("FEN %q: duplicate %s", f, m)
+			}
+			seen[m] = true
+		}
+		for m := range want {
+			if !seen[m] {
+				t.Errorf("FEN %q: missing quiet check %s", f, m)
+			}
+		}
+	}
+}
+
+func TestLegalQuietChecks_Discovered(t *testing.T) {
+	// every knight move uncovers the rook on the e-file
+	pos, _ := fen.Parse("4k3/8/8/8/4N3/8/8/4R1K1 w - - 0 1")
+	ml := movegen.LegalQuietChecks(pos)
+
+	knightMoves := 0
+	for i := 0; i < ml.Count(); i++ {
+		if ml.Get(i).From() == core.NewSquare(3, 4) {
+			knightMoves++
+		}
+	}
+	if knightMoves != 8 {
+		t.Errorf("expected 8 discovered checks from the knight, got %d", knightMoves)
+	}
+}
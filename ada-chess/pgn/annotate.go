@@ -0,0 +1,111 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Numeric annotation glyphs for judging a move, written as $1 to $6.
+const (
+	NAGGood        = 1 // !
+	NAGMistake     = 2 // ?
+	NAGBrilliant   = 3 // !!
+	NAGBlunder     = 4 // ??
+	NAGInteresting = 5 // !?
+	NAGDubious     = 6 // ?!
+)
+
+// Annotation is what a game says about one of its moves besides the move:
+// glyphs judging it, a comment after it, and variations that could have
+// been played instead, each starting from the position before the move.
+type Annotation struct {
+	NAGs       []int
+	Comment    string
+	Variations [][]core.Move
+}
+
+// Annotation returns the annotation of move i, which can be filled in. It
+// panics if there is no move i.
+func (g *Game) Annotation(i int) *Annotation {
+	if i < 0 || i >= len(g.moves) {
+		panic(fmt.Sprintf("pgn: no move %d to annotate", i))
+	}
+	if g.annotations == nil {
+		g.annotations = map[int]*Annotation{}
+	}
+	a, ok := g.annotations[i]
+	if !ok {
+		a = &Annotation{}
+		g.annotations[i] = a
+	}
+	return a
+}
+
+// ClearAnnotations removes every annotation from the game.
+func (g *Game) ClearAnnotations() {
+	g.annotations = nil
+}
+
+// moveTextWriter writes move text a token at a time, wrapping lines before
+// they pass 80 characters.
+type moveTextWriter struct {
+	sb   *strings.Builder
+	line int
+}
+
+func (w *moveTextWriter) token(tok string) {
+	if w.line > 0 && w.line+1+len(tok) > 80 {
+		w.sb.WriteString("\n")
+		w.line = 0
+	} else if w.line > 0 {
+		w.sb.WriteString(" ")
+		w.line++
+	}
+	w.sb.WriteString(tok)
+	w.line += len(tok)
+}
+
+// moveToken returns a move as written in move text: its number when it is
+// White's or numbered is set, then its SAN.
+func moveToken(pos *position.Position, m core.Move, numbered bool) string {
+	san := SAN(pos, m)
+	switch {
+	case pos.ActiveColor == core.White:
+		return fmt.Sprintf("%d. %s", pos.Fullmoves, san)
+	case numbered:
+		return fmt.Sprintf("%d... %s", pos.Fullmoves, san)
+	}
+	return san
+}
+
+// annotationTokens returns the tokens of an annotation written after the
+// move played from pos.
+func annotationTokens(pos *position.Position, a *Annotation) []string {
+	var toks []string
+	for _, nag := range a.NAGs {
+		toks = append(toks, fmt.Sprintf("$%d", nag))
+	}
+	if words := strings.Fields(strings.ReplaceAll(a.Comment, "}", ")")); len(words) > 0 {
+		words[0] = "{" + words[0]
+		words[len(words)-1] += "}"
+		toks = append(toks, words...)
+	}
+	for _, v := range a.Variations {
+		var vt []string
+		p := pos
+		for j, m := range v {
+			vt = append(vt, moveToken(p, m, j == 0))
+			p = position.MakeMove(p, m)
+		}
+		if len(vt) == 0 {
+			continue
+		}
+		vt[0] = "(" + vt[0]
+		vt[len(vt)-1] += ")"
+		toks = append(toks, vt...)
+	}
+	return toks
+}
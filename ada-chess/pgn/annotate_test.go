@@ -0,0 +1,71 @@
+package pgn
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+func TestAnnotatedMoveText(t *testing.T) {
+	start, _ := fen.Parse(startFEN)
+	g := NewGame(start)
+	pos := start
+	var before []*position.Position
+	for _, s := range []string{"e2e4", "e7e5", "g1f3", "f7f6"} {
+		m := findMoveStr(pos, s)
+		before = append(before, pos)
+		g.AddMove(pos, m)
+		pos = position.MakeMove(pos, m)
+	}
+	g.Result = "1-0"
+
+	a := g.Annotation(3)
+	a.NAGs = []int{NAGMistake}
+	a.Comment = "[%eval 1.20] weakens the king"
+	nc6 := findMoveStr(before[3], "b8c6")
+	bb5 := findMoveStr(position.MakeMove(before[3], nc6), "f1b5")
+	a.Variations = [][]core.Move{{nc6, bb5}}
+	g.Annotation(1).Comment = "fine"
+
+	text := g.String()
+	want := "1. e4 e5 {fine} 2. Nf3 f6 $2 {[%eval 1.20] weakens the king} (2... Nc6 3. Bb5) 1-0"
+	if !strings.Contains(strings.ReplaceAll(text, "\n", " "), want) {
+		t.Errorf("move text:\n%s\nwant %s", text, want)
+	}
+
+	// readers that skip the annotations still get the game
+	back, err := NewReader(strings.NewReader(text)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.MoveCount() != 4 || back.Result != "1-0" {
+		t.Errorf("read back %d moves, result %s", back.MoveCount(), back.Result)
+	}
+
+	g.ClearAnnotations()
+	if strings.Contains(g.String(), "{") {
+		t.Error("annotations left after clearing")
+	}
+}
+
+func TestAnnotatedLineWrapping(t *testing.T) {
+	start, _ := fen.Parse(startFEN)
+	g := NewGame(start)
+	pos := start
+	for _, s := range []string{"g1f3", "g8f6", "f3g1", "f6g8", "g1f3", "g8f6", "f3g1", "f6g8"} {
+		m := findMoveStr(pos, s)
+		g.AddMove(pos, m)
+		pos = position.MakeMove(pos, m)
+	}
+	for i := 0; i < g.MoveCount(); i++ {
+		g.Annotation(i).Comment = "a comment long enough to need wrapping soon"
+	}
+	for _, line := range strings.Split(g.String(), "\n") {
+		if len(line) > 80 {
+			t.Errorf("line of %d characters: %s", len(line), line)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pgn
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// ParseSAN finds the legal move a SAN string names in pos. Besides strict
+// SAN it accepts what PGN files found in the wild contain: missing or extra
+// check marks, annotations like "!?", castling written with zeros,
+// promotions without the '=', and more disambiguation than needed.
+func ParseSAN(pos *position.Position, san string) (core.Move, error) {
+	s := strings.TrimRight(san, "+#!?")
+	legal := movegen.LegalMoves(pos)
+
+	// castling
+	switch s {
+	case "O-O", "0-0", "O-O-O", "0-0-0":
+		kingside := len(s) == 3
+		for i := 0; i < legal.Count(); i++ {
+			m := legal.Get(i)
+			if m.MoveType() == core.MoveCastling && (m.To().File() > m.From().File()) == kingside {
+				return m, nil
+			}
+		}
+		return core.NoMove, fmt.Errorf("illegal move %s", san)
+	}
+
+	// promotion piece
+	promo := core.PieceType(0)
+	if i := strings.IndexByte(s, '='); i >= 0 && i == len(s)-2 {
+		promo = sanPiece(s[i+1])
+		s = s[:i]
+	} else if n := len(s); n >= 3 && s[n-2] >= '1' && s[n-2] <= '8' {
+		if pt := sanPiece(s[n-1] &^ 0x20); pt != 0 {
+			promo = pt
+			s = s[:n-1]
+		}
+	}
+
+	// moving piece
+	pt := core.Pawn
+	if len(s) > 0 {
+		if p := sanPiece(s[0]); p != 0 {
+			pt = p
+			s = s[1:]
+		}
+	}
+
+	// what is left is an optional origin file and rank, an optional capture
+	// and the destination square
+	s = strings.NewReplacer("x", "", ":", "", "-", "").Replace(s)
+	if len(s) < 2 || (promo != 0 && pt != core.Pawn) || promo == core.Pawn || promo == core.King {
+		return core.NoMove, fmt.Errorf("bad move %s", san)
+	}
+	to := core.NewSquare(int(s[len(s)-1])-'1', int(s[len(s)-2])-'a')
+	if !to.Valid() {
+		return core.NoMove, fmt.Errorf("bad move %s", san)
+	}
+	fromFile, fromRank := -1, -1
+	for _, ch := range s[:len(s)-2] {
+		switch {
+		case ch >= 'a' && ch <= 'h':
+			fromFile = int(ch - 'a')
+		case ch >= '1' && ch <= '8':
+			fromRank = int(ch - '1')
+		default:
+			return core.NoMove, fmt.Errorf("bad move %s", san)
+		}
+	}
+
+	found := core.NoMove
+	for i := 0; i < legal.Count(); i++ {
+		m := legal.Get(i)
+		if m.To() != to || m.MoveType() == core.MoveCastling || pos.Board.Check(m.From()).Type() != pt {
+			continue
+		}
+		if (fromFile >= 0 && m.From().File() != fromFile) || (fromRank >= 0 && m.From().Rank() != fromRank) {
+			continue
+		}
+		if m.MoveType() == core.MovePromotion {
+			if promo == 0 {
+				return core.NoMove, fmt.Errorf("move %s is missing its promotion piece", san)
+			}
+			if m.PromoPiece() != promo {
+				continue
+			}
+		}
+		if found != core.NoMove {
+			return core.NoMove, fmt.Errorf("ambiguous move %s", san)
+		}
+		found = m
+	}
+	if found == core.NoMove {
+		return core.NoMove, fmt.Errorf("illegal move %s", san)
+	}
+	return found, nil
+}
+
+// sanPiece returns the piece type for a SAN piece letter, 0 for anything
+// else.
+func sanPiece(ch byte) core.PieceType {
+	for pt, c := range pieceChar {
+		if c != 0 && c == ch {
+			return core.PieceType(pt)
+		}
+	}
+	return 0
+}
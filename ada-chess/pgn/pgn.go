@@ -2,10 +2,12 @@ package pgn
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
 	"github.com/WilliamDann/AdaEngine/ada-chess/position"
 )
 
@@ -18,11 +20,17 @@ type Game struct {
 	Black  string
 	Result string
 
+	// Tags holds every tag read from PGN text, the ones above included.
+	// Tags other than those are written out after them.
+	Tags map[string]string
+
 	// startPos is the position before any moves were made.
 	startPos *position.Position
 	// moves recorded during play, paired with the position before each move.
 	moves    []core.Move
 	positions []*position.Position
+	// annotations by move index
+	annotations map[int]*Annotation
 }
 
 // NewGame creates a game starting from the given position.
@@ -34,6 +42,7 @@ func NewGame(start *position.Position) *Game {
 		White:    "?",
 		Black:    "?",
 		Result:   "*",
+		Tags:     map[string]string{},
 		startPos: start,
 	}
 }
@@ -49,6 +58,21 @@ func (g *Game) MoveCount() int {
 	return len(g.moves)
 }
 
+// Start returns the position the game started from.
+func (g *Game) Start() *position.Position {
+	return g.startPos
+}
+
+// Moves returns the moves of the game in order.
+func (g *Game) Moves() []core.Move {
+	return g.moves
+}
+
+// Positions returns the position before each move.
+func (g *Game) Positions() []*position.Position {
+	return g.positions
+}
+
 // String returns the complete PGN text for the game.
 func (g *Game) String() string {
 	var sb strings.Builder
@@ -57,59 +81,61 @@ func (g *Game) String() string {
 	writeTag(&sb, "Event", g.Event)
 	writeTag(&sb, "Site", g.Site)
 	writeTag(&sb, "Date", g.Date)
-	writeTag(&sb, "Round", "?")
+	round := "?"
+	if r, ok := g.Tags["Round"]; ok {
+		round = r
+	}
+	writeTag(&sb, "Round", round)
 	writeTag(&sb, "White", g.White)
 	writeTag(&sb, "Black", g.Black)
 	writeTag(&sb, "Result", g.Result)
+
+	// a game from a set up position says where it started
+	if g.startPos != nil {
+		if f := fen.Format(g.startPos); f != startFEN {
+			writeTag(&sb, "SetUp", "1")
+			writeTag(&sb, "FEN", f)
+		}
+	}
+
+	var extra []string
+	for name := range g.Tags {
+		switch name {
+		case "Event", "Site", "Date", "Round", "White", "Black", "Result", "SetUp", "FEN":
+		default:
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		writeTag(&sb, name, g.Tags[name])
+	}
 	sb.WriteString("\n")
 
-	// Move text
-	line := 0
+	// Move text: a black move is numbered when it starts the game or
+	// follows a comment or variation
+	w := &moveTextWriter{sb: &sb}
+	numbered := true
 	for i, m := range g.moves {
 		pos := g.positions[i]
-		san := SAN(pos, m)
-
-		if pos.ActiveColor == core.White {
-			token := fmt.Sprintf("%d. %s", pos.Fullmoves, san)
-			if line > 0 && line+1+len(token) > 80 {
-				sb.WriteString("\n")
-				line = 0
-			} else if line > 0 {
-				sb.WriteString(" ")
-				line++
-			}
-			sb.WriteString(token)
-			line += len(token)
-		} else {
-			// Black move — if this is the first recorded move, add move number with "..."
-			if i == 0 {
-				token := fmt.Sprintf("%d... %s", pos.Fullmoves, san)
-				sb.WriteString(token)
-				line += len(token)
-			} else {
-				if line > 0 && line+1+len(san) > 80 {
-					sb.WriteString("\n")
-					line = 0
-				} else if line > 0 {
-					sb.WriteString(" ")
-					line++
-				}
-				sb.WriteString(san)
-				line += len(san)
+		w.token(moveToken(pos, m, numbered))
+		numbered = false
+		if a := g.annotations[i]; a != nil {
+			for _, tok := range annotationTokens(pos, a) {
+				w.token(tok)
 			}
+			numbered = a.Comment != "" || len(a.Variations) > 0
 		}
 	}
 
 	// Result
-	if line > 0 {
-		sb.WriteString(" ")
-	}
-	sb.WriteString(g.Result)
+	w.token(g.Result)
 	sb.WriteString("\n")
 
 	return sb.String()
 }
 
 func writeTag(sb *strings.Builder, name, value string) {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
 	sb.WriteString(fmt.Sprintf("[%s \"%s\"]\n", name, value))
 }
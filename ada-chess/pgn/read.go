@@ -0,0 +1,341 @@
+package pgn
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Reader reads games from PGN text one at a time. Comments, NAGs and
+// variations are skipped; only the tags and the main line are kept.
+type Reader struct {
+	r         *bufio.Reader
+	lineStart bool
+
+	// a tag read past the end of the previous game, which starts the next
+	pending *tag
+}
+
+type tag struct {
+	name, value string
+}
+
+// NewReader returns a Reader reading PGN text from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r), lineStart: true}
+}
+
+// Next reads the next game. It returns io.EOF when no games are left. A
+// game whose moves can't be replayed is returned with an error naming the
+// move, and Next can be called again to carry on with the game after it.
+func (r *Reader) Next() (*Game, error) {
+	var g *Game
+	var pos *position.Position
+	var bad error
+	started := false // reached the moves
+	depth := 0       // nesting of variations
+
+	begin := func() {
+		if g == nil {
+			g = &Game{Event: "?", Site: "?", Date: "????.??.??", White: "?", Black: "?", Result: "*", Tags: map[string]string{}}
+		}
+	}
+
+	for {
+		if r.pending != nil {
+			begin()
+			g.setTag(r.pending.name, r.pending.value)
+			r.pending = nil
+		}
+
+		tok, err := r.token()
+		if err == io.EOF {
+			if g == nil {
+				return nil, io.EOF
+			}
+			// a game cut off before its moves still gets a start
+			if err := g.start(); err != nil && bad == nil {
+				bad = err
+			}
+			return g, bad
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case tok[0] == '[':
+			name, value, ok := parseTag(tok)
+			if !ok {
+				continue
+			}
+			if started {
+				// no result ended the last game
+				r.pending = &tag{name, value}
+				return g, bad
+			}
+			begin()
+			g.setTag(name, value)
+
+		case tok == "(":
+			depth++
+		case tok == ")":
+			depth = max(depth-1, 0)
+
+		case depth > 0:
+
+		case tok == "1-0" || tok == "0-1" || tok == "1/2-1/2" || tok == "*":
+			begin()
+			if _, ok := g.Tags["Result"]; !ok {
+				g.Result = tok
+			}
+			if err := g.start(); err != nil && bad == nil {
+				bad = err
+			}
+			return g, bad
+
+		default:
+			begin()
+			if !started {
+				started = true
+				if err := g.start(); err != nil {
+					bad = err
+				}
+				pos = g.startPos
+			}
+			san := moveText(tok)
+			if san == "" || bad != nil {
+				continue
+			}
+			m, err := ParseSAN(pos, san)
+			if err != nil {
+				bad = fmt.Errorf("%s vs %s, move %d: %w", g.White, g.Black, pos.Fullmoves, err)
+				continue
+			}
+			g.AddMove(pos, m)
+			pos = position.MakeMove(pos, m)
+		}
+	}
+}
+
+// start sets up the starting position from the tags, unless the game
+// already has one.
+func (g *Game) start() error {
+	if g.startPos != nil {
+		return nil
+	}
+	f := startFEN
+	if v, ok := g.Tags["FEN"]; ok {
+		f = v
+	}
+	pos, err := fen.Parse(f)
+	if err != nil {
+		pos, _ = fen.Parse(startFEN)
+		g.startPos = pos
+		return fmt.Errorf("bad FEN tag %q: %w", f, err)
+	}
+	g.startPos = pos
+	return nil
+}
+
+// setTag records a tag, filling in the field it belongs to if any.
+func (g *Game) setTag(name, value string) {
+	g.Tags[name] = value
+	switch name {
+	case "Event":
+		g.Event = value
+	case "Site":
+		g.Site = value
+	case "Date":
+		g.Date = value
+	case "White":
+		g.White = value
+	case "Black":
+		g.Black = value
+	case "Result":
+		g.Result = value
+	}
+}
+
+// moveText strips a move number from a token, as in "12." or "12...e5",
+// and returns what is left.
+func moveText(tok string) string {
+	i := 0
+	for i < len(tok) && tok[i] >= '0' && tok[i] <= '9' {
+		i++
+	}
+	if i > 0 && i < len(tok) && tok[i] == '.' {
+		return strings.TrimLeft(tok[i:], ".")
+	}
+	if i == len(tok) {
+		return "" // a bare number
+	}
+	return tok
+}
+
+// parseTag splits a tag pair token such as [White "Carlsen"].
+func parseTag(tok string) (name, value string, ok bool) {
+	body := strings.TrimSpace(tok[1 : len(tok)-1])
+	i := strings.IndexFunc(body, unicode.IsSpace)
+	if i < 0 {
+		return "", "", false
+	}
+	name = body[:i]
+	rest := strings.TrimSpace(body[i:])
+	if len(rest) < 2 || rest[0] != '"' || rest[len(rest)-1] != '"' {
+		return "", "", false
+	}
+	value = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(rest[1 : len(rest)-1])
+	return name, value, true
+}
+
+// token returns the next token of the move text: a whole tag pair, a
+// parenthesis, or a symbol such as a move, move number or result.
+// Comments, NAGs and escaped lines are skipped.
+func (r *Reader) token() (string, error) {
+	for {
+		lineStart := r.lineStart
+		ch, err := r.read()
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case unicode.IsSpace(ch):
+
+		case ch == '%' && lineStart:
+			if err := r.skipLine(); err != nil {
+				return "", err
+			}
+
+		case ch == ';':
+			if err := r.skipLine(); err != nil {
+				return "", err
+			}
+
+		case ch == '{':
+			if err := r.skipTo('}'); err != nil {
+				return "", err
+			}
+
+		case ch == '$':
+			r.symbol()
+
+		case ch == '[':
+			return r.tagPair()
+
+		case ch == '(' || ch == ')':
+			return string(ch), nil
+
+		default:
+			r.unread()
+			return r.symbol(), nil
+		}
+	}
+}
+
+// read returns the next character, keeping track of line starts.
+func (r *Reader) read() (rune, error) {
+	ch, _, err := r.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	r.lineStart = ch == '\n'
+	return ch, nil
+}
+
+func (r *Reader) unread() {
+	r.r.UnreadRune()
+}
+
+func (r *Reader) skipLine() error {
+	return r.skipTo('\n')
+}
+
+func (r *Reader) skipTo(end rune) error {
+	for {
+		ch, err := r.read()
+		if err != nil {
+			return err
+		}
+		if ch == end {
+			return nil
+		}
+	}
+}
+
+// symbol reads up to the next space or delimiter.
+func (r *Reader) symbol() string {
+	var sb strings.Builder
+	for {
+		ch, err := r.read()
+		if err != nil {
+			break
+		}
+		if unicode.IsSpace(ch) || strings.ContainsRune("{}()[];$", ch) {
+			r.unread()
+			r.lineStart = false
+			break
+		}
+		sb.WriteRune(ch)
+	}
+	return sb.String()
+}
+
+// tagPair reads a tag pair after its opening bracket, allowing a ']' in
+// the quoted value.
+func (r *Reader) tagPair() (string, error) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	quoted, escaped := false, false
+	for {
+		ch, err := r.read()
+		if err == io.EOF {
+			return "", errors.New("unterminated tag")
+		}
+		if err != nil {
+			return "", err
+		}
+		sb.WriteRune(ch)
+		switch {
+		case escaped:
+			escaped = false
+		case ch == '\\' && quoted:
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+		case ch == ']' && !quoted:
+			return sb.String(), nil
+		}
+	}
+}
+
+// ReadAll reads every game from r. Games whose moves can't be replayed are
+// left out and their errors returned together with the games that were
+// read.
+func ReadAll(r io.Reader) ([]*Game, []error, error) {
+	pr := NewReader(r)
+	var games []*Game
+	var bad []error
+	for {
+		g, err := pr.Next()
+		if err == io.EOF {
+			return games, bad, nil
+		}
+		if g == nil {
+			return games, bad, err
+		}
+		if err != nil {
+			bad = append(bad, err)
+			continue
+		}
+		games = append(games, g)
+	}
+}
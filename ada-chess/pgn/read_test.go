@@ -0,0 +1,232 @@
+package pgn
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		fen  string
+		san  string
+		want string
+	}{
+		{startFEN, "e4", "e2e4"},
+		{startFEN, "Nf3", "g1f3"},
+		{startFEN, "Ngf3", "g1f3"},   // needless disambiguation
+		{startFEN, "Ng1-f3", "g1f3"}, // long algebraic
+		{startFEN, "Nf3!?", "g1f3"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "O-O", "e1g1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0+", "e1c1"},
+		{"r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1", "O-O-O", "e8c8"},
+		{"8/1P5k/8/8/8/8/8/K7 w - - 0 1", "b8=Q", "b7b8q"},
+		{"8/1P5k/8/8/8/8/8/K7 w - - 0 1", "b8N", "b7b8n"},
+		{"8/1P5k/8/8/8/8/8/K7 w - - 0 1", "b8r", "b7b8r"},
+		{"2r4k/1P6/8/8/8/8/8/K7 w - - 0 1", "bxc8=Q+", "b7c8q"},
+		{"rnbqkbnr/1pp1pppp/p7/3pP3/8/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 3", "exd6", "e5d6"},
+		{"4k3/8/8/8/8/8/8/R3K2R w - - 0 1", "Rad1", "a1d1"},
+		{"4k3/8/8/8/R7/8/8/R3K3 w - - 0 1", "R1a2", "a1a2"},
+	}
+	for _, tc := range tests {
+		pos, err := fen.Parse(tc.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := ParseSAN(pos, tc.san)
+		if err != nil {
+			t.Errorf("%s in %s: %v", tc.san, tc.fen, err)
+			continue
+		}
+		if m.String() != tc.want {
+			t.Errorf("%s in %s: got %s, want %s", tc.san, tc.fen, m, tc.want)
+		}
+	}
+
+	bad := []struct{ fen, san string }{
+		{startFEN, "e5"},  // illegal
+		{startFEN, "Ke2"}, // illegal
+		{startFEN, "xyz"}, // nonsense
+		{"4k3/8/8/8/8/8/4K3/R6R w - - 0 1", "Rd1"}, // ambiguous
+		{"8/1P5k/8/8/8/8/8/K7 w - - 0 1", "b8"},    // no promotion piece
+		{"8/1P5k/8/8/8/8/8/K7 w - - 0 1", "b8=K"},
+	}
+	for _, tc := range bad {
+		pos, _ := fen.Parse(tc.fen)
+		if m, err := ParseSAN(pos, tc.san); err == nil {
+			t.Errorf("%s in %s: got %s, want an error", tc.san, tc.fen, m)
+		}
+	}
+}
+
+func TestSANRoundTrip(t *testing.T) {
+	// every legal move's SAN parses back to the move
+	for _, f := range []string{
+		startFEN,
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8",
+	} {
+		pos, _ := fen.Parse(f)
+		legal := legalMoves(pos)
+		for _, m := range legal {
+			san := SAN(pos, m)
+			got, err := ParseSAN(pos, san)
+			if err != nil || got != m {
+				t.Errorf("%s: %s parsed as %s, %v", f, san, got, err)
+			}
+		}
+	}
+}
+
+func legalMoves(pos *position.Position) []core.Move {
+	var moves []core.Move
+	for from := core.Square(0); from < 64; from++ {
+		for to := core.Square(0); to < 64; to++ {
+			if m := findMove(pos, from.String(), to.String()); m != core.NoMove {
+				for _, promo := range "qrbn" {
+					if p := findMoveStr(pos, from.String()+to.String()+string(promo)); p != core.NoMove {
+						moves = append(moves, p)
+					}
+				}
+				if m.MoveType() != core.MovePromotion {
+					moves = append(moves, m)
+				}
+			}
+		}
+	}
+	return moves
+}
+
+const testGames = `% a comment line
+[Event "Casual"]
+[Site "Home"]
+[Date "2026.01.02"]
+[Round "3"]
+[White "Alice"]
+[Black "Bob \"the Rook\""]
+[Result "1-0"]
+[WhiteElo "2100"]
+
+1. e4 {best by test} e5 2. Nf3 (2. f4 exf4 3. Nf3) 2... Nc6 $1 3. Bb5 ; the Spanish
+a6 4. Ba4 Nf6 1-0
+
+[White "Carol"]
+[Black "Dave"]
+[Result "0-1"]
+
+1. f3 e5 2. g4?? Qh4# 0-1
+
+[White "Eve"]
+[Result "*"]
+
+1. e4 Ke7 Nf3 *
+
+[White "Frank"]
+[FEN "4k3/8/8/8/8/8/8/4K2R w K - 0 1"]
+[SetUp "1"]
+
+1. O-O Kd7
+
+[White "Grace"]
+1.d4 d5 2.c4
+`
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(testGames))
+
+	g, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.White != "Alice" || g.Black != `Bob "the Rook"` || g.Result != "1-0" || g.Tags["WhiteElo"] != "2100" || g.Tags["Round"] != "3" {
+		t.Errorf("tags: %+v", g)
+	}
+	if moves := uciMoves(g); moves != "e2e4 e7e5 g1f3 b8c6 f1b5 a7a6 b5a4 g8f6" {
+		t.Errorf("moves: %s", moves)
+	}
+
+	g, err = r.Next()
+	if err != nil || g.Result != "0-1" || g.MoveCount() != 4 {
+		t.Errorf("second game: %v %v", g, err)
+	}
+
+	// an illegal move spoils the game but not the ones after it
+	g, err = r.Next()
+	if err == nil || g.White != "Eve" {
+		t.Errorf("third game: %v, want an error", err)
+	}
+
+	// a set up position, and no result
+	g, err = r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.White != "Frank" || g.Start().Board.Pieces(core.NewPiece(core.Rook, core.White)).Count() != 1 || uciMoves(g) != "e1g1 e8d7" {
+		t.Errorf("fourth game: %s from %v", uciMoves(g), g.Start())
+	}
+
+	g, err = r.Next()
+	if err != nil || g.White != "Grace" || uciMoves(g) != "d2d4 d7d5 c2c4" {
+		t.Errorf("fifth game: %v %v", g, err)
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestReaderTruncatedGame(t *testing.T) {
+	// tags with no moves at the end of the text still make a game
+	r := NewReader(strings.NewReader("1. e4 e5 *\n\n[Event \"x\"]\n[White \"a\"]\n"))
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := r.Next()
+	if err != nil || g.White != "a" || g.MoveCount() != 0 {
+		t.Fatalf("truncated game: %v %v", g, err)
+	}
+	if g.Start() == nil || fen.Format(g.Start()) != startFEN {
+		t.Errorf("truncated game starts from %v", g.Start())
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v, want io.EOF", err)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	games, bad, err := ReadAll(strings.NewReader(testGames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 4 || len(bad) != 1 {
+		t.Errorf("%d games, %d bad, want 4 and 1", len(games), len(bad))
+	}
+}
+
+func TestWriteAndReadBack(t *testing.T) {
+	games, _, _ := ReadAll(strings.NewReader(testGames))
+	for _, g := range games {
+		back, err := NewReader(strings.NewReader(g.String())).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uciMoves(back) != uciMoves(g) || back.White != g.White || back.Black != g.Black || back.Tags["WhiteElo"] != g.Tags["WhiteElo"] {
+			t.Errorf("round trip changed the game:\n%s", g)
+		}
+		if fen.Format(back.Start()) != fen.Format(g.Start()) {
+			t.Errorf("round trip changed the start: %s", fen.Format(back.Start()))
+		}
+	}
+}
+
+func uciMoves(g *Game) string {
+	var s []string
+	for _, m := range g.Moves() {
+		s = append(s, m.String())
+	}
+	return strings.Join(s, " ")
+}
@@ -13,6 +13,7 @@ func MakeMove(pos *Position, m core.Move) *Position {
 		Halfmoves:   pos.Halfmoves + 1,
 		Fullmoves:   pos.Fullmoves,
 		Zobrist:     pos.Zobrist,
+		PawnKey:     pos.PawnKey,
 	}
 	if pos.ActiveColor == core.Black {
 		next.Fullmoves++
@@ -38,6 +39,10 @@ func MakeMove(pos *Position, m core.Move) *Position {
 		if captured != core.None {
 			next.Zobrist ^= pieceSquareKeys[captured][to]
 		}
+		if piece.Type() == core.Pawn {
+			next.PawnKey ^= pieceSquareKeys[piece][from]
+			next.PawnKey ^= pieceSquareKeys[piece][to]
+		}
 
 		// Double pawn push sets en passant square
 		if piece.Type() == core.Pawn {
@@ -58,6 +63,7 @@ func MakeMove(pos *Position, m core.Move) *Position {
 		if captured != core.None {
 			next.Zobrist ^= pieceSquareKeys[captured][to]
 		}
+		next.PawnKey ^= pieceSquareKeys[piece][from]
 
 	case core.MoveEnPassant:
 		next.Board.Clear(from)
@@ -74,6 +80,10 @@ func MakeMove(pos *Position, m core.Move) *Position {
 		next.Zobrist ^= pieceSquareKeys[piece][to]
 		next.Zobrist ^= pieceSquareKeys[core.NewPiece(core.Pawn, pos.ActiveColor.Flip())][core.Square(int(to) - dir)]
 
+		next.PawnKey ^= pieceSquareKeys[piece][from]
+		next.PawnKey ^= pieceSquareKeys[piece][to]
+		next.PawnKey ^= pieceSquareKeys[core.NewPiece(core.Pawn, pos.ActiveColor.Flip())][core.Square(int(to) - dir)]
+
 	case core.MoveCastling:
 		next.Board.Clear(from)
 		next.Board.Set(to, piece)
@@ -108,6 +118,11 @@ func MakeMove(pos *Position, m core.Move) *Position {
 		next.Zobrist ^= pieceSquareKeys[piece][to]
 	}
 
+	// a captured pawn leaves the pawn structure
+	if captured.Type() == core.Pawn {
+		next.PawnKey ^= pieceSquareKeys[captured][to]
+	}
+
 	// Update castling rights
 	updateCastling(next, from, to)
 
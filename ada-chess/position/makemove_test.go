@@ -20,6 +20,7 @@ func setupPosition(pieces map[core.Square]core.Piece, active core.Color, castlin
 		pos.Board.Set(sq, p)
 	}
 	pos.Zobrist = pos.ComputeZobrist()
+	pos.PawnKey = pos.ComputePawnKey()
 	return pos
 }
 
@@ -29,6 +30,9 @@ func assertZobrist(t *testing.T, pos *Position) {
 	if got, want := pos.Zobrist, pos.ComputeZobrist(); got != want {
 		t.Errorf("zobrist mismatch: incremental=%x, computed=%x", got, want)
 	}
+	if got, want := pos.PawnKey, pos.ComputePawnKey(); got != want {
+		t.Errorf("pawn key mismatch: incremental=%x, computed=%x", got, want)
+	}
 }
 
 func TestNormalMove(t *testing.T) {
@@ -636,3 +640,37 @@ func TestZobristTransposition(t *testing.T) {
 		t.Errorf("same position via different move orders should have same hash: %x != %x", p1.Zobrist, p2.Zobrist)
 	}
 }
+
+func TestPawnKeyIgnoresPieces(t *testing.T) {
+	b1 := core.NewSquare(0, 1)
+	c3 := core.NewSquare(2, 2)
+	e2 := core.NewSquare(1, 4)
+	d5 := core.NewSquare(4, 3)
+
+	pos := setupPosition(map[core.Square]core.Piece{
+		b1: core.NewPiece(core.Knight, core.White),
+		e2: core.NewPiece(core.Pawn, core.White),
+		d5: core.NewPiece(core.Pawn, core.Black),
+	}, core.White, NoCastling, core.InvalidSquare)
+
+	next := MakeMove(pos, core.NewMove(b1, c3))
+	assertZobrist(t, next)
+	if next.PawnKey != pos.PawnKey {
+		t.Error("knight move should not change the pawn key")
+	}
+
+	// knight takes a pawn: the pawn structure changes
+	next = MakeMove(next, core.NewMove(d5, core.NewSquare(3, 3)))
+	next = MakeMove(next, core.NewMove(c3, core.NewSquare(3, 3)))
+	assertZobrist(t, next)
+	if next.PawnKey == pos.PawnKey {
+		t.Error("capturing a pawn should change the pawn key")
+	}
+}
+
+func TestNullMoveKeepsPawnKey(t *testing.T) {
+	e2 := core.NewSquare(1, 4)
+	pos := setupPosition(map[core.Square]core.Piece{e2: core.NewPiece(core.Pawn, core.White)}, core.White, NoCastling, core.InvalidSquare)
+	next := MakeNullMove(pos)
+	assertZobrist(t, next)
+}
@@ -16,6 +16,7 @@ type Position struct {
 	Halfmoves   int
 	Fullmoves   int
 	Zobrist     uint64
+	PawnKey     uint64 // zobrist key of the pawns alone
 }
 
 func NewPosition() *Position {
@@ -31,6 +32,7 @@ func MakeNullMove(pos *Position) *Position {
 		Halfmoves:   pos.Halfmoves,
 		Fullmoves:   pos.Fullmoves,
 		Zobrist:     pos.Zobrist ^ sideToMoveKey,
+		PawnKey:     pos.PawnKey,
 	}
 	// clear old en passant from hash
 	if pos.EnPassant.Valid() {
@@ -61,6 +61,20 @@ func piecesKey(p *Position) uint64 {
 	return hsh
 }
 
+// helper for pawn structure
+func pawnsKey(p *Position) uint64 {
+	var hsh uint64
+
+	for _, color := range []core.Color{core.White, core.Black} {
+		pawn := core.NewPiece(core.Pawn, color)
+		for sq := range p.Board.Pieces(pawn).Squares() {
+			hsh ^= pieceSquareKeys[pawn][sq]
+		}
+	}
+
+	return hsh
+}
+
 // determine keys at program start
 func init() {
 	// pieces
@@ -96,3 +110,8 @@ func (pos *Position) ComputeZobrist() uint64 {
 	return hsh
 }
 
+
+// compute the pawn-only zobrist hash for a position
+func (pos *Position) ComputePawnKey() uint64 {
+	return pawnsKey(pos)
+}
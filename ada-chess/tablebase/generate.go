@@ -0,0 +1,357 @@
+package tablebase
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Generation works backwards from the mates. First every position is
+// settled as far as it can be on its own: checkmate and stalemate, and moves
+// that leave the table, captures and promotions, which are probed in the
+// smaller tables generated before it. A capture into a lost position makes
+// a tentative win, which a quicker mate found later replaces.
+//
+// Then it goes ply by ply. At ply n every position lost in n plies makes
+// each of its predecessors, found by taking back a move, a win in n+1. Every
+// position won in n plies makes its predecessors candidates for a loss: one
+// is lost once all its moves lead to settled wins for the opponent, in one
+// more ply than the longest of them. Whatever is never settled is a draw.
+
+// Generate builds the table for a code, first building any smaller tables
+// its captures and promotions lead to that the tablebase doesn't have yet.
+// It returns the new tables, smallest first. A table already present is not
+// built again.
+func (tb *Tablebase) Generate(code string) ([]*Table, error) {
+	white, black, err := ParseCode(code)
+	if err != nil {
+		return nil, err
+	}
+	code = makeCode(white, black)
+	if code == "KK" {
+		return nil, nil
+	}
+	if t, _ := tb.lookup(code); t != nil {
+		return nil, nil
+	}
+	if len(white)+len(black)+2 > 8 {
+		return nil, fmt.Errorf("%s: too many pieces", code)
+	}
+
+	var built []*Table
+	for _, sub := range subCodes(white, black) {
+		tables, err := tb.Generate(sub)
+		if err != nil {
+			return nil, err
+		}
+		built = append(built, tables...)
+	}
+
+	t, err := newTable(code)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.generate(tb); err != nil {
+		return nil, err
+	}
+	tb.Add(t)
+	return append(built, t), nil
+}
+
+// subCodes lists the materials one capture or promotion away.
+func subCodes(white, black []core.PieceType) []string {
+	var codes []string
+	for side, pieces := range [2][]core.PieceType{white, black} {
+		for i, pt := range pieces {
+			rest := append(append([]core.PieceType{}, pieces[:i]...), pieces[i+1:]...)
+			changed := [][]core.PieceType{rest}
+			if pt == core.Pawn {
+				for _, promo := range pieceOrder[:4] {
+					with := append([]core.PieceType{promo}, rest...)
+					sortPieces(with)
+					changed = append(changed, with)
+				}
+			}
+			for _, c := range changed {
+				if side == 0 {
+					codes = append(codes, makeCode(c, black))
+				} else {
+					codes = append(codes, makeCode(white, c))
+				}
+			}
+		}
+	}
+	return codes
+}
+
+func (t *Table) generate(tb *Tablebase) error {
+	// settle what can be settled alone, in parallel since each position
+	// only writes its own entry
+	workers := runtime.NumCPU()
+	chunk := (len(t.dtm) + workers - 1) / workers
+	horizons := make([]int, workers)
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w * chunk; i < min((w+1)*chunk, len(t.dtm)); i++ {
+				v, err := t.initial(tb, i)
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				t.dtm[i] = v
+				if v != valueInvalid {
+					horizons[w] = max(horizons[w], decodeValue(v).Plies)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	horizon := 0
+	for w := range workers {
+		if errs[w] != nil {
+			return errs[w]
+		}
+		horizon = max(horizon, horizons[w])
+	}
+
+	for ply := 0; ply <= horizon; ply++ {
+		value := lossValue(ply)
+		if ply%2 == 1 {
+			value = winValue(ply)
+		}
+		for i, v := range t.dtm {
+			if v != value {
+				continue
+			}
+			h, err := t.retro(tb, i, ply)
+			if err != nil {
+				return err
+			}
+			horizon = max(horizon, h)
+		}
+	}
+
+	for i, v := range t.dtm {
+		if v == valueInvalid {
+			t.dtm[i] = valueDraw
+		}
+	}
+	return nil
+}
+
+// internal reports whether a move stays in the same table.
+func internal(pos *position.Position, m core.Move) bool {
+	return m.MoveType() != core.MovePromotion && m.MoveType() != core.MoveEnPassant && !pos.Board.HasPiece(m.To())
+}
+
+// initial settles entry idx as far as it can be without the rest of the
+// table.
+func (t *Table) initial(tb *Tablebase, idx int) (uint8, error) {
+	pos, ok := t.position(idx)
+	if !ok {
+		return valueInvalid, nil
+	}
+	moves := movegen.LegalMoves(pos)
+	if moves.Count() == 0 {
+		if movegen.InCheck(pos) {
+			return lossValue(0), nil
+		}
+		return valueDraw, nil
+	}
+
+	win, longest := 0, 0
+	lost := true
+	for i := 0; i < moves.Count(); i++ {
+		m := moves.Get(i)
+		if internal(pos, m) {
+			lost = false
+			continue
+		}
+		r, err := tb.probeChild(pos, m)
+		if err != nil {
+			return 0, err
+		}
+		switch r.Outcome {
+		case Loss:
+			if win == 0 || r.Plies+1 < win {
+				win = r.Plies + 1
+			}
+		case Win:
+			longest = max(longest, r.Plies+1)
+		default:
+			lost = false
+		}
+	}
+	switch {
+	case win > 0:
+		return winValue(win), nil
+	case lost:
+		return lossValue(longest), nil
+	}
+	return valueDraw, nil
+}
+
+// probeChild looks up the position after a move that leaves the table.
+func (tb *Tablebase) probeChild(pos *position.Position, m core.Move) (Result, error) {
+	child := position.MakeMove(pos, m)
+	r, ok := tb.probe(child)
+	if !ok {
+		return r, fmt.Errorf("no table for %s", materialCode(child.Board))
+	}
+	return r, nil
+}
+
+// retro settles the predecessors of entry idx, which was settled at ply,
+// and returns the longest mate it settled any of them with.
+func (t *Table) retro(tb *Tablebase, idx, ply int) (int, error) {
+	pos, _ := t.position(idx)
+	lost := ply%2 == 0
+	longest := 0
+
+	var err error
+	t.unmoves(pos, func(prev *position.Position) bool {
+		j := t.index(prev.Board, prev.ActiveColor)
+		v := t.dtm[j]
+		if lost {
+			// a move into this position wins
+			if v == valueDraw || (v < valueLoss && int(v) > int(winValue(ply+1))) {
+				if ply+1 > maxPlies {
+					err = fmt.Errorf("%s: mates longer than %d plies", t.code, maxPlies)
+					return false
+				}
+				t.dtm[j] = winValue(ply + 1)
+				longest = max(longest, ply+1)
+			}
+			return true
+		}
+
+		if v != valueDraw {
+			return true
+		}
+		var loss int
+		if loss, err = t.lostIn(tb, prev, ply); err != nil {
+			return false
+		}
+		if loss > 0 {
+			if loss > maxPlies {
+				err = fmt.Errorf("%s: mates longer than %d plies", t.code, maxPlies)
+				return false
+			}
+			t.dtm[j] = lossValue(loss)
+			longest = max(longest, loss)
+		}
+		return true
+	})
+	return longest, err
+}
+
+// lostIn returns how many plies the side to move takes to get mated if every
+// move leads to a win for the opponent settled within ply, or 0 if not.
+func (t *Table) lostIn(tb *Tablebase, pos *position.Position, ply int) (int, error) {
+	moves := movegen.LegalMoves(pos)
+	if moves.Count() == 0 {
+		return 0, nil
+	}
+	longest := 0
+	for i := 0; i < moves.Count(); i++ {
+		m := moves.Get(i)
+		var r Result
+		if internal(pos, m) {
+			child := position.MakeMove(pos, m)
+			r = decodeValue(t.dtm[t.index(child.Board, child.ActiveColor)])
+			if r.Outcome != Win || r.Plies > ply {
+				return 0, nil
+			}
+		} else {
+			var err error
+			if r, err = tb.probeChild(pos, m); err != nil {
+				return 0, err
+			}
+			if r.Outcome != Win {
+				return 0, nil
+			}
+		}
+		longest = max(longest, r.Plies)
+	}
+	return longest + 1, nil
+}
+
+// unmoves calls f with every legal position the side not to move could
+// have reached pos from by a move that stays in the table, until f returns
+// false.
+func (t *Table) unmoves(pos *position.Position, f func(*position.Position) bool) {
+	us := pos.ActiveColor.Flip()
+	occupied := pos.Board.Occupied()
+	empty := occupied.Invert()
+
+	for pt := core.Pawn; pt <= core.King; pt++ {
+		pc := core.NewPiece(pt, us)
+		for to := range pos.Board.Pieces(pc).Squares() {
+			var from core.Bitboard
+			switch pt {
+			case core.Pawn:
+				from = pawnUnpushes(to, us, empty)
+			case core.Knight:
+				from = movegen.KnightMoves(to)
+			case core.Bishop:
+				from = movegen.BishopMoves(to, occupied)
+			case core.Rook:
+				from = movegen.RookMoves(to, occupied)
+			case core.Queen:
+				from = movegen.QueenMoves(to, occupied)
+			case core.King:
+				from = movegen.KingMoves(to)
+			}
+
+			for sq := range from.Intersection(empty).Squares() {
+				board := pos.Board.Clone()
+				board.Clear(to)
+				board.Set(sq, pc)
+				prev := newPosition(board, us)
+
+				// the side that moved next can't have been left in check
+				if kingAttacked(prev, us.Flip()) {
+					continue
+				}
+				if !f(prev) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// pawnUnpushes returns the squares a pawn of color c on sq could have been
+// pushed from.
+func pawnUnpushes(sq core.Square, c core.Color, empty core.Bitboard) core.Bitboard {
+	var from core.Bitboard
+	step, start := -8, 1
+	if c == core.Black {
+		step, start = 8, 6
+	}
+	one := int(sq) + step
+	if one/8 < 1 || one/8 > 6 {
+		return 0
+	}
+	from = from.Set(core.Square(one))
+	if two := one + step; two/8 == start && empty.Check(core.Square(one)) {
+		from = from.Set(core.Square(two))
+	}
+	return from
+}
+
+// kingAttacked reports whether c's king is attacked.
+func kingAttacked(pos *position.Position, c core.Color) bool {
+	for sq := range pos.Board.Pieces(core.NewPiece(core.King, c)).Squares() {
+		return movegen.IsAttacked(pos, sq, c.Flip())
+	}
+	return false
+}
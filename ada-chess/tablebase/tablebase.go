@@ -0,0 +1,662 @@
+package tablebase
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Distance-to-mate endgame tablebases, generated locally by retrograde
+// analysis (see generate.go).
+//
+// A table covers one material combination, written like "KQKR": white's
+// king and pieces, then black's. Pieces are listed queen, rook, bishop,
+// knight, pawn. Positions with the colors the other way round are looked up
+// in the same table with the board flipped. Every position stores its result
+// for the side to move in one byte:
+//
+//	0         draw
+//	1-127     win, mate in that many moves (2m-1 plies)
+//	128-254   loss, mated in value-128 moves (2m plies)
+//
+// Positions are numbered by the side to move, the white king's square and
+// then every other piece's square. Boards are mirrored so the white king is
+// on files a-d, and without pawns also on ranks 1-4 below the long
+// diagonal, so that symmetric positions share an entry.
+//
+// Tables know nothing about castling, and a pawn that just advanced two
+// squares can't be taken en passant in them; Probe declines positions where
+// either matters.
+//
+// File format, one table per file named after its code with the extension
+// ".atb", integers little endian:
+//
+//	magic    8 bytes  "ADATBDTM"
+//	code     uint8 length, then the code's letters
+//	entries  uint32   number of positions, which must match the code
+//	results  the results above, one byte per position, deflate compressed
+
+// Outcome is a result for the side to move.
+type Outcome int8
+
+const (
+	Loss Outcome = -1
+	Draw Outcome = 0
+	Win  Outcome = 1
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Win:
+		return "win"
+	case Loss:
+		return "loss"
+	}
+	return "draw"
+}
+
+// Result is a probed position's value for the side to move.
+type Result struct {
+	Outcome Outcome
+
+	// Plies is the distance to mate with best play by both sides, for a
+	// win or a loss. A checkmated side to move has lost in 0 plies.
+	Plies int
+}
+
+// results as stored in a table
+const (
+	valueDraw    = 0
+	valueLoss    = 128
+	valueInvalid = 255 // only while a table is generated
+
+	// the longest mate a byte can hold
+	maxPlies = 2*(valueInvalid-valueLoss) - 1
+)
+
+func winValue(plies int) uint8  { return uint8((plies + 1) / 2) }
+func lossValue(plies int) uint8 { return uint8(valueLoss + plies/2) }
+
+func decodeValue(v uint8) Result {
+	switch {
+	case v == valueDraw || v == valueInvalid:
+		return Result{Outcome: Draw}
+	case v < valueLoss:
+		return Result{Outcome: Win, Plies: 2*int(v) - 1}
+	}
+	return Result{Outcome: Loss, Plies: 2 * int(v-valueLoss)}
+}
+
+// pieceOrder is the order pieces are listed in a code.
+var pieceOrder = [5]core.PieceType{core.Queen, core.Rook, core.Bishop, core.Knight, core.Pawn}
+
+const pieceLetters = " PNBRQK"
+
+// ParseCode splits a code like "KRPKR" into white's pieces and black's,
+// kings left out, and returns it with each side's pieces in order.
+func ParseCode(code string) (white, black []core.PieceType, err error) {
+	code = strings.ToUpper(code)
+	if len(code) < 2 || code[0] != 'K' || strings.Count(code, "K") != 2 {
+		return nil, nil, fmt.Errorf("bad tablebase code %q", code)
+	}
+	side := &white
+	for i := 1; i < len(code); i++ {
+		if code[i] == 'K' {
+			side = &black
+			continue
+		}
+		pt := strings.IndexByte(pieceLetters[:6], code[i])
+		if pt < 1 {
+			return nil, nil, fmt.Errorf("bad tablebase code %q", code)
+		}
+		*side = append(*side, core.PieceType(pt))
+	}
+	sortPieces(white)
+	sortPieces(black)
+	return white, black, nil
+}
+
+func sortPieces(pieces []core.PieceType) {
+	slices.SortFunc(pieces, func(a, b core.PieceType) int {
+		return slices.Index(pieceOrder[:], a) - slices.Index(pieceOrder[:], b)
+	})
+}
+
+func makeCode(white, black []core.PieceType) string {
+	var sb strings.Builder
+	sb.WriteByte('K')
+	for _, pt := range white {
+		sb.WriteByte(pieceLetters[pt])
+	}
+	sb.WriteByte('K')
+	for _, pt := range black {
+		sb.WriteByte(pieceLetters[pt])
+	}
+	return sb.String()
+}
+
+// materialCode returns the code of a position's material, white first.
+func materialCode(board *core.Chessboard) string {
+	var buf [16]byte
+	n := 0
+	for _, c := range [2]core.Color{core.White, core.Black} {
+		buf[n] = 'K'
+		n++
+		for _, pt := range pieceOrder {
+			for range board.Pieces(core.NewPiece(pt, c)).Count() {
+				if n == len(buf) {
+					return string(buf[:n])
+				}
+				buf[n] = pieceLetters[pt]
+				n++
+			}
+		}
+	}
+	return string(buf[:n])
+}
+
+// flipCode swaps the sides of a code.
+func flipCode(code string) string {
+	k := strings.LastIndexByte(code, 'K')
+	return code[k:] + code[:k]
+}
+
+// Table holds the results of one material combination.
+type Table struct {
+	code   string
+	pieces []core.Piece // white king, black king, white's pieces, black's
+	pawns  bool
+	kings  int // squares the white king is numbered on: 10 or 32
+	dtm    []uint8
+}
+
+// newTable returns an empty table for a code.
+func newTable(code string) (*Table, error) {
+	white, black, err := ParseCode(code)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{code: makeCode(white, black), kings: 10}
+	t.pieces = []core.Piece{core.NewPiece(core.King, core.White), core.NewPiece(core.King, core.Black)}
+	for _, pt := range white {
+		t.pieces = append(t.pieces, core.NewPiece(pt, core.White))
+	}
+	for _, pt := range black {
+		t.pieces = append(t.pieces, core.NewPiece(pt, core.Black))
+	}
+	if slices.Contains(white, core.Pawn) || slices.Contains(black, core.Pawn) {
+		t.pawns = true
+		t.kings = 32
+	}
+
+	size := 2 * t.kings
+	for range len(t.pieces) - 1 {
+		size *= 64
+	}
+	t.dtm = make([]uint8, size)
+	return t, nil
+}
+
+// Code returns the table's material code.
+func (t *Table) Code() string {
+	return t.code
+}
+
+// Pieces returns the number of pieces in the table, kings included.
+func (t *Table) Pieces() int {
+	return len(t.pieces)
+}
+
+// Size returns the number of positions in the table.
+func (t *Table) Size() int {
+	return len(t.dtm)
+}
+
+// Stats counts a table's results. Entries that are no legal position count
+// as draws.
+type Stats struct {
+	Wins, Draws, Losses int
+
+	// Longest is the longest mate in plies.
+	Longest int
+}
+
+// Stats counts the table's results.
+func (t *Table) Stats() Stats {
+	var s Stats
+	for _, v := range t.dtm {
+		r := decodeValue(v)
+		switch r.Outcome {
+		case Win:
+			s.Wins++
+		case Loss:
+			s.Losses++
+		default:
+			s.Draws++
+		}
+		s.Longest = max(s.Longest, r.Plies)
+	}
+	return s
+}
+
+// kingSquares lists the squares the white king is numbered on, and
+// kingIndex the reverse.
+var (
+	kingSquares [2][]int // [pawns]
+	kingIndex   [2][64]int
+)
+
+func init() {
+	for sq := 0; sq < 64; sq++ {
+		file, rank := sq%8, sq/8
+		kingIndex[0][sq], kingIndex[1][sq] = -1, -1
+		if file < 4 && rank < 4 && rank <= file {
+			kingIndex[0][sq] = len(kingSquares[0])
+			kingSquares[0] = append(kingSquares[0], sq)
+		}
+		if file < 4 {
+			kingIndex[1][sq] = len(kingSquares[1])
+			kingSquares[1] = append(kingSquares[1], sq)
+		}
+	}
+}
+
+// symmetries applied to squares by a transform's bits
+const (
+	mirrorFile = 1
+	mirrorRank = 2
+	mirrorDiag = 4
+)
+
+func transform(sq, tr int) int {
+	if tr&mirrorFile != 0 {
+		sq ^= 7
+	}
+	if tr&mirrorRank != 0 {
+		sq ^= 56
+	}
+	if tr&mirrorDiag != 0 {
+		sq = sq%8*8 + sq/8
+	}
+	return sq
+}
+
+// symmetry returns the transform that brings the white king onto a
+// numbered square.
+func (t *Table) symmetry(wk int) int {
+	tr := 0
+	if wk%8 > 3 {
+		tr |= mirrorFile
+	}
+	if t.pawns {
+		return tr
+	}
+	if wk/8 > 3 {
+		tr |= mirrorRank
+	}
+	if sq := transform(wk, tr); sq/8 > sq%8 {
+		tr |= mirrorDiag
+	}
+	return tr
+}
+
+func (t *Table) pawnIndex() int {
+	if t.pawns {
+		return 1
+	}
+	return 0
+}
+
+// index numbers a board with the table's material. Pieces of the same kind
+// are numbered in ascending order of their squares. A board whose white
+// king ends up on the long diagonal is also numbered mirrored across it,
+// and gets the lower number, so that the two share an entry.
+func (t *Table) index(board *core.Chessboard, stm core.Color) int {
+	wk := 0
+	for sq := range board.Pieces(t.pieces[0]).Squares() {
+		wk = int(sq)
+	}
+	tr := t.symmetry(wk)
+	idx := t.indexWith(board, stm, wk, tr)
+	if sq := transform(wk, tr); !t.pawns && sq/8 == sq%8 {
+		idx = min(idx, t.indexWith(board, stm, wk, tr|mirrorDiag))
+	}
+	return idx
+}
+
+func (t *Table) indexWith(board *core.Chessboard, stm core.Color, wk, tr int) int {
+	idx := 0
+	var taken core.Piece
+	var left core.Bitboard
+	for i := len(t.pieces) - 1; i >= 1; i-- {
+		pc := t.pieces[i]
+		if pc != taken {
+			taken, left = pc, 0
+			for sq := range board.Pieces(pc).Squares() {
+				left = left.Set(core.Square(transform(int(sq), tr)))
+			}
+		}
+		// the highest square goes to the last piece of a kind
+		sq := 63 - bits.LeadingZeros64(uint64(left))
+		left = left.Clear(core.Square(sq))
+		idx = idx*64 + sq
+	}
+
+	idx = idx*t.kings + kingIndex[t.pawnIndex()][transform(wk, tr)]
+	return 2*idx + colorIndex(stm)
+}
+
+func colorIndex(c core.Color) int {
+	if c == core.White {
+		return 0
+	}
+	return 1
+}
+
+// position builds the position numbered idx, and reports whether it is a
+// legal position numbered that way. Legal means no two pieces share a
+// square, no pawn is on the first or last rank and the side not to move is
+// not in check; numbered that way means idx is the number index gives it.
+func (t *Table) position(idx int) (*position.Position, bool) {
+	stm := core.White
+	if idx%2 == 1 {
+		stm = core.Black
+	}
+	idx /= 2
+	number := idx
+	squares := make([]int, len(t.pieces))
+	squares[0] = kingSquares[t.pawnIndex()][idx%t.kings]
+	idx /= t.kings
+	for i := 1; i < len(t.pieces); i++ {
+		squares[i] = idx % 64
+		idx /= 64
+	}
+
+	board := core.NewChessboard()
+	for i, pc := range t.pieces {
+		sq := core.Square(squares[i])
+		if board.HasPiece(sq) {
+			return nil, false
+		}
+		if pc.Type() == core.Pawn && (sq < 8 || sq >= 56) {
+			return nil, false
+		}
+		board.Set(sq, pc)
+	}
+
+	// the same board may have another number, which is the one used
+	if t.index(board, stm) != 2*number+colorIndex(stm) {
+		return nil, false
+	}
+
+	pos := newPosition(board, stm)
+	if kingAttacked(pos, stm.Flip()) {
+		return nil, false
+	}
+	return pos, true
+}
+
+func newPosition(board *core.Chessboard, stm core.Color) *position.Position {
+	pos := position.NewPosition()
+	pos.Board = board
+	pos.ActiveColor = stm
+	pos.EnPassant = core.InvalidSquare
+	pos.Fullmoves = 1
+	return pos
+}
+
+// flipPosition swaps the colors of a position and mirrors it vertically.
+func flipPosition(pos *position.Position) *position.Position {
+	board := core.NewChessboard()
+	for sq := range pos.Board.Occupied().Squares() {
+		pc := pos.Board.Check(sq)
+		board.Set(sq^56, core.NewPiece(pc.Type(), pc.Color().Flip()))
+	}
+	return newPosition(board, pos.ActiveColor.Flip())
+}
+
+// Tablebase is a set of tables, found by material. Probing is safe from any
+// number of goroutines, but not while tables are added or generated.
+type Tablebase struct {
+	tables    map[string]*Table
+	maxPieces int
+}
+
+// New returns an empty tablebase.
+func New() *Tablebase {
+	return &Tablebase{tables: make(map[string]*Table)}
+}
+
+// Load reads every table file in a directory.
+func Load(dir string) (*Tablebase, error) {
+	tb := New()
+	files, err := filepath.Glob(filepath.Join(dir, "*.atb"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		t, err := LoadTable(path)
+		if err != nil {
+			return nil, err
+		}
+		tb.Add(t)
+	}
+	return tb, nil
+}
+
+// Add adds a table, replacing any with the same material.
+func (tb *Tablebase) Add(t *Table) {
+	delete(tb.tables, flipCode(t.code))
+	tb.tables[t.code] = t
+	tb.maxPieces = max(tb.maxPieces, len(t.pieces))
+}
+
+// Tables lists the codes of the tables, sorted.
+func (tb *Tablebase) Tables() []string {
+	codes := make([]string, 0, len(tb.tables))
+	for code := range tb.tables {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// Table returns the table for a code, with either side first.
+func (tb *Tablebase) Table(code string) *Table {
+	white, black, err := ParseCode(code)
+	if err != nil {
+		return nil
+	}
+	t, _ := tb.lookup(makeCode(white, black))
+	return t
+}
+
+// MaxPieces returns the number of pieces, kings included, in the largest
+// table.
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// lookup finds the table for a code and whether it holds the code's
+// colors the other way round.
+func (tb *Tablebase) lookup(code string) (*Table, bool) {
+	if t, ok := tb.tables[code]; ok {
+		return t, false
+	}
+	if t, ok := tb.tables[flipCode(code)]; ok {
+		return t, true
+	}
+	return nil, false
+}
+
+// Probe looks a position up. It reports false when there's no table for
+// its material, or when castling or en passant is possible.
+func (tb *Tablebase) Probe(pos *position.Position) (Result, bool) {
+	if pos.Castling != position.NoCastling || pos.EnPassant.Valid() {
+		return Result{}, false
+	}
+	if pos.Board.Occupied().Count() > tb.maxPieces {
+		return Result{}, false
+	}
+	for _, c := range [2]core.Color{core.White, core.Black} {
+		if pos.Board.Pieces(core.NewPiece(core.King, c)).Count() != 1 {
+			return Result{}, false
+		}
+	}
+	return tb.probe(pos)
+}
+
+// probe looks up any position with castling and en passant ignored. Bare
+// kings need no table.
+func (tb *Tablebase) probe(pos *position.Position) (Result, bool) {
+	code := materialCode(pos.Board)
+	if code == "KK" {
+		return Result{Outcome: Draw}, true
+	}
+	t, flipped := tb.lookup(code)
+	if t == nil {
+		return Result{}, false
+	}
+	if flipped {
+		pos = flipPosition(pos)
+	}
+	return decodeValue(t.dtm[t.index(pos.Board, pos.ActiveColor)]), true
+}
+
+var magic = [8]byte{'A', 'D', 'A', 'T', 'B', 'D', 'T', 'M'}
+
+// LoadTable reads a table file.
+func LoadTable(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := ReadTable(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ReadTable reads a table in the format described above.
+func ReadTable(r io.Reader) (*Table, error) {
+	var header [9]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if [8]byte(header[:8]) != magic {
+		return nil, errors.New("not an AdaEngine tablebase file")
+	}
+	code := make([]byte, header[8])
+	if _, err := io.ReadFull(r, code); err != nil {
+		return nil, err
+	}
+	t, err := newTable(string(code))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries uint32
+	if err := binary.Read(r, binary.LittleEndian, &entries); err != nil {
+		return nil, err
+	}
+	if int(entries) != len(t.dtm) {
+		return nil, fmt.Errorf("%s has %d entries, want %d", t.code, entries, len(t.dtm))
+	}
+	if _, err := io.ReadFull(flate.NewReader(r), t.dtm); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("truncated table: %w", err)
+	}
+	return t, nil
+}
+
+// Write writes the table in the format ReadTable reads.
+func (t *Table) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(magic[:])
+	bw.WriteByte(byte(len(t.code)))
+	bw.WriteString(t.code)
+	binary.Write(bw, binary.LittleEndian, uint32(len(t.dtm)))
+
+	zw, err := flate.NewWriter(bw, flate.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(t.dtm); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Save writes the table into dir as <code>.atb.
+func (t *Table) Save(dir string) error {
+	f, err := os.Create(filepath.Join(dir, t.code+".atb"))
+	if err != nil {
+		return err
+	}
+	if err := t.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// BestMove returns the move that keeps the best result for the side to
+// move, the quickest mate when winning and the longest defence when losing,
+// along with the result. It reports false when pos can't be probed.
+func (tb *Tablebase) BestMove(pos *position.Position) (core.Move, Result, bool) {
+	if _, ok := tb.Probe(pos); !ok {
+		return core.NoMove, Result{}, false
+	}
+
+	best, bestResult := core.NoMove, Result{}
+	moves := movegen.LegalMoves(pos)
+	for i := 0; i < moves.Count(); i++ {
+		m := moves.Get(i)
+		r, ok := tb.probe(position.MakeMove(pos, m))
+		if !ok {
+			return core.NoMove, Result{}, false
+		}
+		// the child's result is the opponent's
+		r = Result{Outcome: -r.Outcome, Plies: r.Plies + 1}
+		if r.Outcome == Draw {
+			r.Plies = 0
+		}
+		if best == core.NoMove || better(r, bestResult) {
+			best, bestResult = m, r
+		}
+	}
+	return best, bestResult, best != core.NoMove
+}
+
+// better reports whether a is a better result than b for the same side.
+func better(a, b Result) bool {
+	switch {
+	case a.Outcome != b.Outcome:
+		return a.Outcome > b.Outcome
+	case a.Outcome == Win:
+		return a.Plies < b.Plies
+	case a.Outcome == Loss:
+		return a.Plies > b.Plies
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package tablebase
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// the small tables take about a second to build, so the tests share them
+var (
+	testOnce sync.Once
+	testTB   *Tablebase
+)
+
+func testTablebase(t *testing.T) *Tablebase {
+	t.Helper()
+	testOnce.Do(func() {
+		testTB = New()
+		for _, code := range []string{"KQK", "KRK", "KPK"} {
+			if _, err := testTB.Generate(code); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	if testTB == nil {
+		t.Fatal("tablebase not generated")
+	}
+	return testTB
+}
+
+func TestParseCode(t *testing.T) {
+	white, black, err := ParseCode("kpqkr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := makeCode(white, black); got != "KQPKR" {
+		t.Errorf("code = %s, want KQPKR", got)
+	}
+	if got := flipCode("KQPKR"); got != "KRKQP" {
+		t.Errorf("flipped = %s, want KRKQP", got)
+	}
+	for _, bad := range []string{"", "K", "KQ", "QKK", "KXK", "KQKK"} {
+		if _, _, err := ParseCode(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestGenerateBuildsSubtables(t *testing.T) {
+	tb := testTablebase(t)
+	want := []string{"KBK", "KNK", "KPK", "KQK", "KRK"}
+	if got := tb.Tables(); !slices.Equal(got, want) {
+		t.Errorf("tables = %v, want %v", got, want)
+	}
+	if tb.MaxPieces() != 3 {
+		t.Errorf("MaxPieces = %d, want 3", tb.MaxPieces())
+	}
+}
+
+// longestWin returns the longest mate in a table with white to move.
+func longestWin(table *Table) int {
+	longest := 0
+	for i := 0; i < len(table.dtm); i += 2 {
+		if r := decodeValue(table.dtm[i]); r.Outcome == Win {
+			longest = max(longest, r.Plies)
+		}
+	}
+	return longest
+}
+
+func TestLongestMates(t *testing.T) {
+	tb := testTablebase(t)
+	for _, tc := range []struct {
+		code  string
+		moves int
+	}{
+		{"KQK", 10},
+		{"KRK", 16},
+		{"KPK", 28}, // through the promotion
+	} {
+		if got := longestWin(tb.Table(tc.code)); got != 2*tc.moves-1 {
+			t.Errorf("%s: longest mate %d plies, want %d", tc.code, got, 2*tc.moves-1)
+		}
+	}
+}
+
+func TestKnownPositions(t *testing.T) {
+	tb := testTablebase(t)
+	tests := []struct {
+		fen  string
+		want Result
+	}{
+		{"k7/8/1K6/8/8/8/8/6Q1 w - - 0 1", Result{Win, 1}},
+		{"k7/1Q6/1K6/8/8/8/8/8 b - - 0 1", Result{Loss, 0}},
+		{"k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", Result{Draw, 0}}, // stalemate
+		{"6q1/8/8/8/8/1k6/8/K7 b - - 0 1", Result{Win, 1}},  // colors flipped
+		{"8/8/8/8/8/3k4/3P4/7K w - - 0 1", Result{Draw, 0}}, // the pawn falls
+		{"7k/3pK3/8/8/8/8/8/8 b - - 0 1", Result{Win, 25}},  // d5 outruns the king
+	}
+	for _, tc := range tests {
+		pos, err := fen.Parse(tc.fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := tb.Probe(pos)
+		if !ok {
+			t.Errorf("%s: not found", tc.fen)
+			continue
+		}
+		if got.Outcome != tc.want.Outcome || (tc.want.Plies > 0 && got.Plies != tc.want.Plies) {
+			t.Errorf("%s: %v in %d, want %v in %d", tc.fen, got.Outcome, got.Plies, tc.want.Outcome, tc.want.Plies)
+		}
+	}
+
+	// castling or a missing table can't be probed
+	for _, f := range []string{
+		"4k3/8/8/8/8/8/8/R3K3 w Q - 0 1",
+		"4k3/8/8/8/8/8/8/RR2K3 w - - 0 1",
+	} {
+		pos, _ := fen.Parse(f)
+		if _, ok := tb.Probe(pos); ok {
+			t.Errorf("%s: should not be probed", f)
+		}
+	}
+}
+
+func TestResultsAgreeWithMoves(t *testing.T) {
+	// every position's result must follow from its children's
+	tb := testTablebase(t)
+	table := tb.Table("KRK")
+	rng := rand.New(rand.NewSource(1))
+	for checked := 0; checked < 2000; {
+		i := rng.Intn(len(table.dtm))
+		pos, ok := table.position(i)
+		if !ok {
+			continue
+		}
+		checked++
+		r := decodeValue(table.dtm[i])
+		_, best, ok := tb.BestMove(pos)
+		if !ok {
+			// mate or stalemate
+			if movegen.InCheck(pos) != (r.Outcome == Loss) || r.Plies != 0 {
+				t.Fatalf("%v: terminal position stored as %v", pos, r)
+			}
+			continue
+		}
+		if best != r {
+			t.Fatalf("%v: stored %v, best move gives %v", pos, r, best)
+		}
+	}
+}
+
+func TestIndexSymmetry(t *testing.T) {
+	// all eight mirror images of a position without pawns share an entry
+	table, _ := newTable("KRKN")
+	rng := rand.New(rand.NewSource(2))
+	for n := 0; n < 1000; n++ {
+		perm := rng.Perm(64)
+		board := core.NewChessboard()
+		for i, pc := range table.pieces {
+			board.Set(core.Square(perm[i]), pc)
+		}
+		want := table.index(board, core.White)
+		for tr := 1; tr < 8; tr++ {
+			mirrored := core.NewChessboard()
+			for sq := range board.Occupied().Squares() {
+				mirrored.Set(core.Square(transform(int(sq), tr)), board.Check(sq))
+			}
+			if got := table.index(mirrored, core.White); got != want {
+				t.Fatalf("transform %d: index %d, want %d", tr, got, want)
+			}
+		}
+	}
+}
+
+func TestBestMoveMates(t *testing.T) {
+	tb := testTablebase(t)
+	pos, _ := fen.Parse("k7/8/1K6/8/8/8/8/6Q1 w - - 0 1")
+	m, r, ok := tb.BestMove(pos)
+	if !ok || r != (Result{Win, 1}) {
+		t.Fatalf("BestMove = %v %v %v", m, r, ok)
+	}
+	child := position.MakeMove(pos, m)
+	if moves := movegen.LegalMoves(child); moves.Count() != 0 || !movegen.InCheck(child) {
+		t.Errorf("%v is not mate", m)
+	}
+}
+
+func TestTableRoundTrip(t *testing.T) {
+	table := testTablebase(t).Table("KRK")
+	var buf bytes.Buffer
+	if err := table.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() >= table.Size()/2 {
+		t.Errorf("%d bytes for %d positions, expected compression", buf.Len(), table.Size())
+	}
+
+	got, err := ReadTable(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Code() != "KRK" || !bytes.Equal(got.dtm, table.dtm) {
+		t.Error("table changed in the round trip")
+	}
+
+	if _, err := ReadTable(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+	bad := slices.Clone(buf.Bytes())
+	bad[0] = 'X'
+	if _, err := ReadTable(bytes.NewReader(bad)); err == nil {
+		t.Error("expected an error for a bad magic number")
+	}
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := testTablebase(t).Table("KQK").Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	tb, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tb.Tables(); !slices.Equal(got, []string{"KQK"}) {
+		t.Errorf("loaded %v", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Command ada-match plays two configurations of the engine against each
+// other to test whether a change is an improvement.
+//
+//	ada-match [flags] -a name:spec -b name:spec
+//
+// A player's spec is a comma separated list of parameter settings such as
+// LMR=0 and parameter files (.json or .toml), applied in order over the
+// defaults; a player given by name alone plays with the defaults. Each
+// opening is played twice with colours reversed, and the match reports the
+// first player's score and Elo difference as it goes. With -sprt the match
+// stops as soon as the test decides between the two Elo hypotheses.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-search"
+	"github.com/WilliamDann/AdaEngine/ada-selfplay"
+)
+
+func main() {
+	defaults := selfplay.DefaultConfig()
+	a           := flag.String("a", "new", "the first player, name[:spec]")
+	b           := flag.String("b", "base", "the second player, name[:spec]")
+	openings    := flag.String("openings", "", "opening suite, .pgn or .epd/.fen (default a built-in suite)")
+	plies       := flag.Int("plies", 8, "how many plies of each PGN game to use as its opening")
+	games       := flag.Int("games", defaults.Games, "most games to play")
+	concurrency := flag.Int("concurrency", defaults.Concurrency, "games played at once")
+	depth       := flag.Int("depth", defaults.Depth, "search depth per move")
+	movetime    := flag.Duration("movetime", 0, "search time per move, instead of a depth")
+	hash        := flag.Int("hash", defaults.HashMB, "transposition table megabytes per engine")
+	resign      := flag.Int("resign", defaults.ResignScore, "adjudicate a win at this score in centipawns (0 never)")
+	draw        := flag.Int("draw", defaults.DrawScore, "adjudicate a draw within this score of even (0 never)")
+	maxPlies    := flag.Int("max-plies", defaults.MaxPlies, "draw games reaching this many plies (0 never)")
+	sprt        := flag.String("sprt", "", "run an SPRT between Elo hypotheses elo0,elo1")
+	alpha       := flag.Float64("alpha", 0.05, "SPRT false positive rate")
+	beta        := flag.Float64("beta", 0.05, "SPRT false negative rate")
+	out         := flag.String("pgn", "", "write the games here")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-match [flags] -a name:spec -b name:spec\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg := defaults
+	cfg.Games, cfg.Concurrency = *games, *concurrency
+	cfg.Depth, cfg.MoveTime, cfg.HashMB = *depth, *movetime, *hash
+	cfg.ResignScore, cfg.DrawScore, cfg.MaxPlies = *resign, *draw, *maxPlies
+	if *movetime > 0 {
+		cfg.Depth = 0
+	}
+	cfg.A, cfg.B = player(*a), player(*b)
+
+	if err := run(cfg, *openings, *plies, *sprt, *alpha, *beta, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-match:", err)
+		os.Exit(1)
+	}
+}
+
+// player parses a name[:spec] argument.
+func player(arg string) selfplay.Player {
+	name, spec, _ := strings.Cut(arg, ":")
+	return selfplay.Player{
+		Name: name,
+		Setup: func(e *search.Engine) error {
+			for _, item := range strings.Split(spec, ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					continue
+				}
+				param, value, ok := strings.Cut(item, "=")
+				if !ok {
+					if err := e.LoadParams(item); err != nil {
+						return err
+					}
+					continue
+				}
+				v, err := strconv.Atoi(strings.TrimSpace(value))
+				if err != nil {
+					return fmt.Errorf("%s: %q is not a number", param, value)
+				}
+				if err := e.SetParam(strings.TrimSpace(param), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func run(cfg selfplay.Config, openings string, plies int, sprt string, alpha, beta float64, out string) error {
+	cfg.Openings = selfplay.DefaultOpenings()
+	if openings != "" {
+		f, err := os.Open(openings)
+		if err != nil {
+			return err
+		}
+		if strings.ToLower(filepath.Ext(openings)) == ".pgn" {
+			cfg.Openings, err = selfplay.ReadPGNOpenings(f, plies)
+		} else {
+			cfg.Openings, err = selfplay.ReadFENOpenings(f)
+		}
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", openings, err)
+		}
+		if len(cfg.Openings) == 0 {
+			return fmt.Errorf("%s: no openings", openings)
+		}
+	}
+
+	if sprt != "" {
+		lo, hi, ok := strings.Cut(sprt, ",")
+		elo0, err0 := strconv.ParseFloat(strings.TrimSpace(lo), 64)
+		elo1, err1 := strconv.ParseFloat(strings.TrimSpace(hi), 64)
+		if !ok || err0 != nil || err1 != nil || elo0 >= elo1 {
+			return fmt.Errorf("-sprt %q: want elo0,elo1 with elo0 < elo1", sprt)
+		}
+		cfg.SPRT = &selfplay.SPRT{Elo0: elo0, Elo1: elo1, Alpha: alpha, Beta: beta}
+	}
+
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		cfg.PGN = f
+	}
+
+	fmt.Printf("%s vs %s, %d openings\n", cfg.A.Name, cfg.B.Name, len(cfg.Openings))
+	began := time.Now()
+	cfg.OnGame = func(r selfplay.GameResult, s selfplay.Stats) {
+		white, black := cfg.A.Name, cfg.B.Name
+		if !r.AWhite {
+			white, black = black, white
+		}
+		line := fmt.Sprintf("game %d: %s - %s %s (%s)  %v", r.Round, white, black, r.Result, r.Reason, s)
+		if cfg.SPRT != nil {
+			lower, upper := cfg.SPRT.Bounds()
+			line += fmt.Sprintf("  LLR %.2f [%.2f, %.2f]", cfg.SPRT.LLR(s), lower, upper)
+		}
+		fmt.Println(line)
+	}
+
+	stats, verdict, err := selfplay.Run(cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%d games in %v\n%s\n", stats.Games(), time.Since(began).Round(time.Second), stats)
+	if cfg.SPRT != nil {
+		fmt.Printf("SPRT [%g, %g]: %v\n", cfg.SPRT.Elo0, cfg.SPRT.Elo1, verdict)
+	}
+	return nil
+}
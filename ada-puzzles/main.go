@@ -0,0 +1,114 @@
+// Command ada-puzzles mines tactical puzzles from games: positions right
+// after a mistake where exactly one move wins decisively or mates, with the
+// solution checked to be the only winning move at each of its steps.
+//
+//	ada-puzzles [flags] games.pgn ...
+//
+// Puzzles are written as CSV with a header: the position as FEN, the
+// solution in UCI and SAN notation, its themes, and the game and move it
+// came from.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-analysis"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func main() {
+	def := analysis.DefaultOptions()
+	out      := flag.String("out", "", "where to write the puzzles (default standard output)")
+	depth    := flag.Int("depth", def.Depth, "search depth for each position")
+	moveTime := flag.Duration("movetime", 0, "time limit for each position, e.g. 500ms (0 for depth only)")
+	threads  := flag.Int("threads", def.Threads, "search threads")
+	hash     := flag.Int("hash", search.DefaultHashMB, "hash table size in megabytes")
+	decisive := flag.Int("decisive", def.Decisive, "centipawns a puzzle's winning move must gain")
+	moves    := flag.Int("moves", def.PuzzleMoves, "longest solution, in the solver's moves")
+	quiet    := flag.Bool("q", false, "don't report progress on standard error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-puzzles [flags] games.pgn ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := def
+	opts.Depth = *depth
+	opts.MoveTime = *moveTime
+	opts.Threads = *threads
+	opts.Decisive = *decisive
+	opts.PuzzleMoves = *moves
+
+	if err := run(search.NewEngine(*hash), flag.Args(), *out, opts, !*quiet); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-puzzles:", err)
+		os.Exit(1)
+	}
+}
+
+func run(e *search.Engine, files []string, out string, opts analysis.Options, verbose bool) error {
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"fen", "moves", "san", "themes", "game", "ply"})
+
+	games, found := 0, 0
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		r := pgn.NewReader(f)
+		for {
+			g, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			if g == nil {
+				f.Close()
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err != nil {
+				// a bad game spoils only itself
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				continue
+			}
+			games++
+
+			review := analysis.Analyze(e, g, opts, nil)
+			puzzles := analysis.FindPuzzles(e, g, review, opts)
+			source := fmt.Sprintf("%s - %s, %s %s", g.White, g.Black, g.Event, g.Date)
+			for _, p := range puzzles {
+				cw.Write([]string{p.FEN, p.UCI(), strings.Join(p.SAN, " "), strings.Join(p.Themes, " "), source, strconv.Itoa(p.Ply)})
+			}
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				f.Close()
+				return err
+			}
+			found += len(puzzles)
+			if verbose {
+				fmt.Fprintf(os.Stderr, "%s game %d: %d puzzles, %d in all\n", name, games, len(puzzles), found)
+			}
+		}
+		f.Close()
+	}
+	return nil
+}
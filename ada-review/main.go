@@ -0,0 +1,153 @@
+// Command ada-review reviews games with the engine and writes them back
+// annotated: an [%eval] comment on every move, and for inaccuracies,
+// mistakes and blunders a glyph, the better move and the engine's line.
+// With -json it writes a summary of each game instead, as a JSON object
+// with the game's tags and a report of each side's accuracy, average
+// centipawn loss and errors, and the game's critical moments.
+//
+//	ada-review [flags] games.pgn ...
+//
+// Games are read from the files given, or standard input with none, and
+// written to standard output unless -out says otherwise.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-analysis"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func main() {
+	def := analysis.DefaultOptions()
+	out        := flag.String("out", "", "where to write the annotated games (default standard output)")
+	depth      := flag.Int("depth", def.Depth, "search depth for each position")
+	moveTime   := flag.Duration("movetime", 0, "time limit for each position, e.g. 500ms (0 for depth only)")
+	threads    := flag.Int("threads", def.Threads, "search threads")
+	hash       := flag.Int("hash", search.DefaultHashMB, "hash table size in megabytes")
+	inaccuracy := flag.Int("inaccuracy", def.Inaccuracy, "centipawns lost for an inaccuracy")
+	mistake    := flag.Int("mistake", def.Mistake, "centipawns lost for a mistake")
+	blunder    := flag.Int("blunder", def.Blunder, "centipawns lost for a blunder")
+	params     := flag.String("params", "", "load engine parameters from this file")
+	asJSON     := flag.Bool("json", false, "write a JSON summary of each game instead of the annotated game")
+	critical   := flag.Int("critical", 3, "how many critical moments the summary lists")
+	quiet      := flag.Bool("q", false, "don't report progress on standard error")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-review [flags] [games.pgn ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts := analysis.Options{
+		Depth:      *depth,
+		MoveTime:   *moveTime,
+		Threads:    *threads,
+		Inaccuracy: *inaccuracy,
+		Mistake:    *mistake,
+		Blunder:    *blunder,
+	}
+	e := search.NewEngine(*hash)
+	if *params != "" {
+		if err := e.LoadParams(*params); err != nil {
+			fmt.Fprintln(os.Stderr, "ada-review:", err)
+			os.Exit(1)
+		}
+	}
+
+	var write func(io.Writer, *pgn.Game, *analysis.Review) error
+	if *asJSON {
+		write = func(w io.Writer, g *pgn.Game, r *analysis.Review) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(gameReport{Tags: g.Tags, Report: r.Report(*critical)})
+		}
+	} else {
+		write = func(w io.Writer, g *pgn.Game, r *analysis.Review) error {
+			r.Annotate(g)
+			_, err := fmt.Fprintln(w, g)
+			return err
+		}
+	}
+
+	if err := run(e, flag.Args(), *out, opts, write, !*quiet); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-review:", err)
+		os.Exit(1)
+	}
+}
+
+// gameReport is the JSON summary of a game.
+type gameReport struct {
+	Tags   map[string]string `json:"tags"`
+	Report analysis.Report   `json:"report"`
+}
+
+func run(e *search.Engine, files []string, out string, opts analysis.Options,
+	write func(io.Writer, *pgn.Game, *analysis.Review) error, verbose bool) error {
+	var w io.Writer = os.Stdout
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+
+	review := func(name string, r io.Reader) error {
+		pr := pgn.NewReader(r)
+		for n := 1; ; n++ {
+			g, err := pr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if g == nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err != nil {
+				// a bad game spoils only itself
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				continue
+			}
+
+			start := time.Now()
+			rev := analysis.Analyze(e, g, opts, func(done, total int) {
+				if verbose {
+					fmt.Fprintf(os.Stderr, "\r%s game %d: %d/%d positions", name, n, done, total)
+				}
+			})
+			if verbose {
+				fmt.Fprintf(os.Stderr, " in %s\n", time.Since(start).Round(time.Millisecond))
+			}
+			if err := write(bw, g, rev); err != nil {
+				return err
+			}
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return review("stdin", os.Stdin)
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		err = review(name, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+func TestBenchPositions(t *testing.T) {
+	fens := BenchPositions()
+	if len(fens) < 50 {
+		t.Errorf("%d bench positions", len(fens))
+	}
+	seen := map[string]bool{}
+	for _, f := range fens {
+		pos, err := fen.Parse(f)
+		if err != nil {
+			t.Errorf("%s: %v", f, err)
+			continue
+		}
+		if seen[f] {
+			t.Errorf("%s twice", f)
+		}
+		seen[f] = true
+		// the side that just moved can't be left in check
+		if movegen.InCheck(position.MakeNullMove(pos)) {
+			t.Errorf("%s: side not to move is in check", f)
+		}
+	}
+}
+
+// benchTestDepth and benchTestSignature pin the bench. A change to the
+// search or the evaluation that alters the signature must update it in the
+// same commit; one that isn't meant to change behavior must not.
+const (
+	benchTestDepth     = 5
+	benchTestSignature = 799802
+)
+
+func TestBenchSignature(t *testing.T) {
+	calls := 0
+	first := Bench(benchTestDepth, func(i int, f string, r Result) {
+		if i != calls || f != benchFENs[i] {
+			t.Errorf("position %d reported as %d, %s", calls, i, f)
+		}
+		calls++
+	})
+	if calls != len(benchFENs) || first.Positions != calls {
+		t.Fatalf("%d calls, %+v", calls, first)
+	}
+	if first.Nodes != benchTestSignature {
+		t.Errorf("bench signature %d at depth %d, want %d", first.Nodes, benchTestDepth, benchTestSignature)
+	}
+
+	// a used engine gives the same signature as a new one
+	e := NewEngine(benchHashMB)
+	pos, _ := fen.Parse(benchFENs[2])
+	e.Search(pos, 5, 1, 0)
+	e.Options.SkillLevel = 3
+	if again := e.Bench(benchTestDepth, nil); again.Nodes != first.Nodes {
+		t.Errorf("signature %d, then %d", first.Nodes, again.Nodes)
+	}
+	if e.Options.SkillLevel != 3 {
+		t.Error("bench didn't restore the options")
+	}
+
+	// a change to the search changes it
+	e.Options.PVS = false
+	if changed := e.Bench(benchTestDepth, nil); changed.Nodes == first.Nodes {
+		t.Errorf("signature %d without PVS too", changed.Nodes)
+	}
+}
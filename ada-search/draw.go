@@ -0,0 +1,76 @@
+package search
+
+import (
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// drawScore is what a draw is worth to the side to move in pos. Contempt
+// counts a draw as a small loss for the side the search is for, and so as a
+// small win for its opponent.
+func (td *threadData) drawScore(pos *position.Position) int {
+	if pos.ActiveColor == td.rootColor {
+		return -td.opts.Contempt
+	}
+	return td.opts.Contempt
+}
+
+// isDraw reports whether pos, the node at ply, is drawn by the fifty move
+// rule or by repeating a position. A single repetition of a position inside
+// the search is enough: whatever the side to move does about it now it
+// could do the next time round. A position from the game before the root
+// must have come up twice, as the opponent may still avoid the third time.
+func (td *threadData) isDraw(pos *position.Position, ply int) bool {
+	td.keys[ply] = pos.Zobrist
+	if pos.Halfmoves >= 100 {
+		// unless the move that got here was mate
+		if !movegen.InCheck(pos) {
+			return true
+		}
+		moves := movegen.LegalMoves(pos)
+		return moves.Count() > 0
+	}
+
+	// only positions since the last capture or pawn move can repeat, and
+	// only those with the same side to move
+	checked := ply
+	earlier := 0 // repetitions of positions from the root back
+	for i := 4; i <= pos.Halfmoves; i += 2 {
+		j := ply - i
+		// positions from before a null move aren't really earlier ones
+		if td.nullBetween(j, checked) {
+			break
+		}
+		checked = j
+		var key uint64
+		if j >= 0 {
+			key = td.keys[j]
+		} else if k := len(td.gameKeys) + j; k >= 0 {
+			key = td.gameKeys[k]
+		} else {
+			break
+		}
+		if key != pos.Zobrist {
+			continue
+		}
+		if j > 0 {
+			return true
+		}
+		if earlier++; earlier >= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// nullBetween reports whether a null move was made at any ply in
+// [from, to) of the search.
+func (td *threadData) nullBetween(from, to int) bool {
+	for p := max(from, 0); p < to; p++ {
+		if td.stack[p].move == core.NoMove {
+			return true
+		}
+	}
+	return false
+}
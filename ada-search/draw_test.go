@@ -0,0 +1,140 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
+)
+
+func TestDrawScore(t *testing.T) {
+	td := newThreadData()
+	td.opts.Contempt = 20
+	td.rootColor = core.Black
+	white, _ := fen.Parse("7k/8/8/8/8/8/8/K7 w - - 0 1")
+	black, _ := fen.Parse("7k/8/8/8/8/8/8/K7 b - - 0 1")
+	if got := td.drawScore(black); got != -20 {
+		t.Errorf("draw for the root side scores %d, want -20", got)
+	}
+	if got := td.drawScore(white); got != 20 {
+		t.Errorf("draw for the opponent scores %d, want 20", got)
+	}
+}
+
+func TestFiftyMoveDraw(t *testing.T) {
+	// a queen up, but every move ends the game by the fifty move rule
+	pos, _ := fen.Parse("7k/8/8/8/8/8/8/KQ6 w - - 99 80")
+	for _, contempt := range []int{0, 30} {
+		e := NewEngine(1)
+		e.SetParam("Contempt", contempt)
+		if res := e.Search(pos, 3, 1, 0); res.Score != -contempt {
+			t.Errorf("contempt %d: score %d, want %d", contempt, res.Score, -contempt)
+		}
+	}
+
+	// mate on the hundredth half move still counts
+	mate, _ := fen.Parse("7k/8/6K1/8/8/8/8/1Q6 w - - 99 80")
+	if res := Search(mate, 3, 1, 0); res.Score != Mate-1 {
+		t.Errorf("mate on the last move: %s %d", res.Move, res.Score)
+	}
+}
+
+func TestRepetitionContempt(t *testing.T) {
+	// the kings have shuffled Kd1-e1, Ke8-d8 and back twice, so Ke1 now
+	// repeats for the third time
+	shuffle := []string{"d1e1", "e8d8", "e1d1", "d8e8"}
+	history, pos := playMoves(t, "4k3/8/8/8/8/8/8/3K4 w - - 0 1", append(shuffle, shuffle...))
+
+	for _, tc := range []struct {
+		contempt int
+		repeat   bool
+	}{
+		{50, false}, {-50, true},
+	} {
+		e := NewEngine(1)
+		e.SetParam("Contempt", tc.contempt)
+		e.SetHistory(history)
+		res := e.Search(pos, 2, 1, 0)
+		if repeat := res.Move.String() == "d1e1"; repeat != tc.repeat {
+			t.Errorf("contempt %d: played %s (%d)", tc.contempt, res.Move, res.Score)
+		}
+	}
+
+	// without the history there is nothing to repeat
+	e := NewEngine(1)
+	e.SetParam("Contempt", -50)
+	if res := e.Search(pos, 2, 1, 0); res.Score != 0 {
+		t.Errorf("score %d without history", res.Score)
+	}
+
+	// after one shuffle Ke1 would only repeat for the second time, which
+	// the opponent can still avoid
+	history, pos = playMoves(t, "4k3/8/8/8/8/8/8/3K4 w - - 0 1", shuffle)
+	e = NewEngine(1)
+	e.SetParam("Contempt", -50)
+	e.SetHistory(history)
+	if res := e.Search(pos, 2, 1, 0); res.Score != 0 {
+		t.Errorf("score %d for a twofold repetition", res.Score)
+	}
+
+	// but a repetition inside the search is a draw the first time
+	td := newThreadData()
+	for p := 0; p < 5; p++ {
+		td.keys[p] = uint64(p + 1)
+		td.stack[p].move = core.NewMove(core.NewSquare(0, 3), core.NewSquare(0, 4))
+	}
+	td.keys[1] = pos.Zobrist
+	pos.Halfmoves = 4
+	if !td.isDraw(pos, 5) {
+		t.Error("a repetition inside the search isn't a draw")
+	}
+
+	// a tablebase draw is scored with contempt too, at the root and in the
+	// tree, where taking the rook leaves a drawn rook pawn ending
+	tb := tablebase.New()
+	if _, err := tb.Generate("KPK"); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"k7/8/8/8/8/P7/8/7K w - - 0 1", "8/1k6/8/8/8/P7/6r1/7K w - - 0 1"} {
+		pos, _ := fen.Parse(f)
+		for _, contempt := range []int{50, -50} {
+			e := NewEngine(1)
+			e.SetTablebase(tb)
+			e.SetParam("Contempt", contempt)
+			if res := e.Search(pos, 3, 1, 0); res.Score != -contempt || res.TBHits == 0 {
+				t.Errorf("%s, contempt %d: %s scored %d with %d tbhits", f, contempt, res.Move, res.Score, res.TBHits)
+			}
+		}
+	}
+}
+
+// playMoves plays moves in long algebraic notation from a FEN and returns
+// the positions before each move and the one after the last.
+func playMoves(t *testing.T, f string, moves []string) ([]*position.Position, *position.Position) {
+	t.Helper()
+	pos, _ := fen.Parse(f)
+	var history []*position.Position
+	for _, s := range moves {
+		m, ok := findMove(pos, s)
+		if !ok {
+			t.Fatalf("no move %s", s)
+		}
+		history = append(history, pos)
+		pos = position.MakeMove(pos, m)
+	}
+	return history, pos
+}
+
+// findMove finds the legal move written in long algebraic notation.
+func findMove(pos *position.Position, s string) (core.Move, bool) {
+	moves := movegen.LegalMoves(pos)
+	for i := 0; i < moves.Count(); i++ {
+		if m := moves.Get(i); m.String() == s {
+			return m, true
+		}
+	}
+	return core.NoMove, false
+}
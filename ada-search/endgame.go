@@ -0,0 +1,318 @@
+package search
+
+import (
+	"math/bits"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Endgame knowledge. Some endings are badly misjudged by counting material:
+// KBNK is a win only if the defending king is driven to the right corner,
+// KRKB is nearly always a draw, a rook pawn with the wrong bishop can't be
+// forced through. Before the general evaluation is trusted the position's
+// material signature is looked up in a registry of specialized evaluators,
+// which replace it, and scale factors, which shrink it toward a draw.
+
+// materialKey packs the number of pawns, knights, bishops, rooks and queens
+// of each color into 4 bits apiece. Kings are always there and not counted.
+type materialKey uint64
+
+func materialShift(color core.Color, pt core.PieceType) int {
+	return (colorIndex(color)*5 + int(pt) - 1) * 4
+}
+
+func materialKeyOf(pos *position.Position) materialKey {
+	var key materialKey
+	for _, c := range [2]core.Color{core.White, core.Black} {
+		for pt := core.Pawn; pt <= core.Queen; pt++ {
+			n := pos.Board.Pieces(core.NewPiece(pt, c)).Count()
+			key |= materialKey(min(n, 15)) << materialShift(c, pt)
+		}
+	}
+	return key
+}
+
+func (k materialKey) count(color core.Color, pt core.PieceType) int {
+	return int(k>>materialShift(color, pt)) & 15
+}
+
+// pieceLetters maps the letters of an endgame code to piece types.
+var pieceLetters = map[byte]core.PieceType{
+	'P': core.Pawn, 'N': core.Knight, 'B': core.Bishop, 'R': core.Rook, 'Q': core.Queen,
+}
+
+// codeKey returns the key of an ending written like "KBNK": the strong
+// side's pieces, then the weak side's, each starting with its king.
+func codeKey(code string, strong core.Color) materialKey {
+	var key materialKey
+	side := strong
+	for i := 1; i < len(code); i++ {
+		if code[i] == 'K' {
+			side = strong.Flip()
+			continue
+		}
+		pt := pieceLetters[code[i]]
+		key += 1 << materialShift(side, pt)
+	}
+	return key
+}
+
+// endgame is a registry entry. eval scores the position from the strong
+// side's point of view in place of the general evaluation; scale returns
+// how much of the general evaluation to keep, out of scaleNormal, when the
+// strong side is ahead.
+type endgame struct {
+	name   string
+	strong core.Color
+	eval   func(p *evalParams, pos *position.Position, strong core.Color) int
+	scale  func(p *evalParams, pos *position.Position, strong core.Color) int
+}
+
+const (
+	scaleNormal = 64
+	scaleDraw   = 0
+
+	// knownWin is added to the score of endings that are won with correct
+	// play. It dwarfs any positional score but stays clear of mate scores.
+	knownWin = 10_000
+)
+
+var endgames = map[materialKey]endgame{}
+
+func registerEval(code string, f func(*evalParams, *position.Position, core.Color) int) {
+	for _, c := range [2]core.Color{core.White, core.Black} {
+		endgames[codeKey(code, c)] = endgame{name: code, strong: c, eval: f}
+	}
+}
+
+func registerScale(code string, f func(*evalParams, *position.Position, core.Color) int) {
+	for _, c := range [2]core.Color{core.White, core.Black} {
+		endgames[codeKey(code, c)] = endgame{name: code, strong: c, scale: f}
+	}
+}
+
+func init() {
+	registerEval("KPK", evalKPK)
+	registerEval("KBNK", evalKBNK)
+	registerEval("KRKB", evalKRKB)
+	registerEval("KRKN", evalKRKN)
+	registerEval("KNNK", evalDraw)
+	registerScale("KQKQ", scaleFlat(16))
+	registerScale("KRKR", scaleFlat(16))
+}
+
+// pushToEdge rewards a king near the edge of the board, 0-90.
+func pushToEdge(sq int) int {
+	return 30 * centerDist[sq]
+}
+
+// pushClose rewards two kings standing close together, 0-120.
+func pushClose(a, b int) int {
+	return 20 * (7 - squareDistance(a, b))
+}
+
+func evalDraw(*evalParams, *position.Position, core.Color) int {
+	return 0
+}
+
+func scaleFlat(f int) func(*evalParams, *position.Position, core.Color) int {
+	return func(*evalParams, *position.Position, core.Color) int { return f }
+}
+
+// evalKXK scores a lone king against enough material to mate: drive the king
+// to the edge and bring ours next to it.
+func evalKXK(p *evalParams, pos *position.Position, strong core.Color) int {
+	ourKing, theirKing := findKingSq(pos, strong), findKingSq(pos, strong.Flip())
+	pawns := pos.Board.Pieces(core.NewPiece(core.Pawn, strong)).Count()
+	return knownWin + p.nonPawnMaterial(pos, strong) + pawns*p.pieceValue[core.Pawn] +
+		pushToEdge(theirKing) + pushClose(ourKing, theirKing)
+}
+
+// evalKBNK drives the king into a corner the bishop can cover; mate can't
+// be forced in the others.
+func evalKBNK(p *evalParams, pos *position.Position, strong core.Color) int {
+	ourKing, theirKing := findKingSq(pos, strong), findKingSq(pos, strong.Flip())
+
+	// a1 and h8 are dark, a8 and h1 light
+	corners := [2]int{0, 63}
+	for sq := range pos.Board.Pieces(core.NewPiece(core.Bishop, strong)).Squares() {
+		if lightSquare(int(sq)) {
+			corners = [2]int{7, 56}
+		}
+	}
+	dist := min(manhattan(theirKing, corners[0]), manhattan(theirKing, corners[1]))
+
+	return knownWin + p.pieceValue[core.Knight] + p.pieceValue[core.Bishop] +
+		15*(14-dist) + pushClose(ourKing, theirKing)
+}
+
+func lightSquare(sq int) bool {
+	return (sq/8+sq%8)%2 == 1
+}
+
+func manhattan(a, b int) int {
+	return abs(a/8-b/8) + abs(a%8-b%8)
+}
+
+// evalKPK looks the position up in the KPK bitbase: a win is worth a little
+// more the further the pawn has come, anything else is a draw.
+func evalKPK(p *evalParams, pos *position.Position, strong core.Color) int {
+	ourKing, theirKing := findKingSq(pos, strong), findKingSq(pos, strong.Flip())
+	pawn := 0
+	for sq := range pos.Board.Pieces(core.NewPiece(core.Pawn, strong)).Squares() {
+		pawn = int(sq)
+	}
+	stm := pos.ActiveColor
+
+	// the bitbase has white holding the pawn
+	if strong == core.Black {
+		ourKing, theirKing, pawn = ourKing^56, theirKing^56, pawn^56
+		stm = stm.Flip()
+	}
+	if !kpkProbe(stm, ourKing, pawn, theirKing) {
+		return 0
+	}
+	return knownWin + p.pieceValue[core.Pawn] + pawn/8
+}
+
+// evalKRKB is a draw with any care; only a king stuck on the edge gives
+// the rook side chances.
+func evalKRKB(p *evalParams, pos *position.Position, strong core.Color) int {
+	return pushToEdge(findKingSq(pos, strong.Flip()))
+}
+
+// evalKRKN is also drawn, unless the knight strays from its king.
+func evalKRKN(p *evalParams, pos *position.Position, strong core.Color) int {
+	theirKing := findKingSq(pos, strong.Flip())
+	knight := theirKing
+	for sq := range pos.Board.Pieces(core.NewPiece(core.Knight, strong.Flip())).Squares() {
+		knight = int(sq)
+	}
+	return pushToEdge(theirKing) + 10*squareDistance(theirKing, knight)
+}
+
+// endgameEval returns a specialized evaluation of pos from white's point of
+// view, and the ending's name, if one applies.
+func (p *evalParams) endgameEval(pos *position.Position, key materialKey) (int, string, bool) {
+	var eg endgame
+	if e, ok := endgames[key]; ok && e.eval != nil {
+		eg = e
+	} else if c, ok := loneKingAgainstMate(p, key); ok {
+		eg = endgame{name: "KXK", strong: c, eval: evalKXK}
+	} else {
+		return 0, "", false
+	}
+
+	v := eg.eval(p, pos, eg.strong)
+	if eg.strong == core.Black {
+		v = -v
+	}
+	return v, eg.name, true
+}
+
+// loneKingAgainstMate reports whether one side has only its king left and
+// the other enough pieces to force mate, and which side that is.
+func loneKingAgainstMate(p *evalParams, key materialKey) (core.Color, bool) {
+	for _, strong := range [2]core.Color{core.White, core.Black} {
+		weak := strong.Flip()
+		if key>>materialShift(weak, core.Pawn)&(1<<20-1) != 0 {
+			continue
+		}
+		if key.count(strong, core.Queen) > 0 || key.count(strong, core.Rook) > 0 ||
+			key.count(strong, core.Bishop) >= 2 ||
+			(key.count(strong, core.Bishop) > 0 && key.count(strong, core.Knight) > 0) {
+			return strong, true
+		}
+	}
+	return 0, false
+}
+
+// endgameScale returns how much of the general evaluation to keep, out of
+// scaleNormal, when strong is ahead, and the reason if it is less.
+func (p *evalParams) endgameScale(pos *position.Position, key materialKey, strong core.Color) (int, string) {
+	weak := strong.Flip()
+	if e, ok := endgames[key]; ok && e.scale != nil && e.strong == strong {
+		return e.scale(p, pos, strong), e.name
+	}
+
+	ourNPM, theirNPM := p.nonPawnMaterial(pos, strong), p.nonPawnMaterial(pos, weak)
+	ourPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, strong))
+	theirPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, weak))
+
+	// without pawns a minor piece more isn't enough to win
+	if ourPawns.Empty() && ourNPM-theirNPM <= p.pieceValue[core.Bishop] {
+		switch {
+		case ourNPM < p.pieceValue[core.Rook]:
+			return scaleDraw, "insufficient material"
+		case theirNPM <= p.pieceValue[core.Bishop]:
+			return 4, "no pawns"
+		}
+		return 14, "no pawns"
+	}
+
+	// a bishop and rook pawns queening on a square it can't cover
+	if ourNPM == p.pieceValue[core.Bishop] && key.count(strong, core.Bishop) == 1 {
+		if wrongRookPawns(pos, strong, ourPawns) {
+			return scaleDraw, "wrong rook pawn"
+		}
+	}
+	if ourNPM == 0 && wrongRookPawns(pos, strong, ourPawns) {
+		return scaleDraw, "rook pawn"
+	}
+
+	// opposite-colored bishops
+	if key.count(strong, core.Bishop) == 1 && key.count(weak, core.Bishop) == 1 {
+		ourBishop := pos.Board.Pieces(core.NewPiece(core.Bishop, strong))
+		theirBishop := pos.Board.Pieces(core.NewPiece(core.Bishop, weak))
+		if lightSquare(bits.TrailingZeros64(uint64(ourBishop))) != lightSquare(bits.TrailingZeros64(uint64(theirBishop))) {
+			if ourNPM == p.pieceValue[core.Bishop] && theirNPM == p.pieceValue[core.Bishop] {
+				passed := classifyPawns(relativePawns(ourPawns, strong), relativePawns(theirPawns, strong)).passed
+				return min(16+8*passed.Count(), scaleNormal), "opposite bishops"
+			}
+			return 44, "opposite bishops"
+		}
+	}
+	return scaleNormal, ""
+}
+
+// relativePawns flips black's pawns so classifyPawns sees the strong side
+// moving up the board.
+func relativePawns(pawns core.Bitboard, strong core.Color) core.Bitboard {
+	if strong == core.Black {
+		return flip(pawns)
+	}
+	return pawns
+}
+
+// wrongRookPawns reports whether all of strong's pawns are on one rook file
+// with the defending king in front of them near the queening corner, and no
+// bishop of strong's covers the queening square.
+func wrongRookPawns(pos *position.Position, strong core.Color, pawns core.Bitboard) bool {
+	if pawns.Empty() {
+		return false
+	}
+	var file int
+	switch {
+	case pawns&^fileA == 0:
+		file = 0
+	case pawns&^fileH == 0:
+		file = 7
+	default:
+		return false
+	}
+
+	queen := file + 56
+	if strong == core.Black {
+		queen = file
+	}
+	for sq := range pos.Board.Pieces(core.NewPiece(core.Bishop, strong)).Squares() {
+		if lightSquare(int(sq)) == lightSquare(queen) {
+			return false
+		}
+	}
+
+	// the defending king holds the corner from next to the queening square
+	theirKing := findKingSq(pos, strong.Flip())
+	return squareDistance(theirKing, queen) <= 1
+}
@@ -0,0 +1,239 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+func TestMaterialKey(t *testing.T) {
+	tests := []struct {
+		fen    string
+		code   string
+		strong core.Color
+	}{
+		{"8/8/8/4k3/8/8/8/KBN5 w - - 0 1", "KBNK", core.White},
+		{"8/8/8/4k3/8/8/8/KBN5 w - - 0 1", "KNBK", core.White},
+		{"kbn5/8/8/8/8/8/8/4K3 w - - 0 1", "KBNK", core.Black},
+		{"4k3/8/8/8/8/8/2b5/R3K3 w - - 0 1", "KRKB", core.White},
+		{"4k3/4p3/8/8/8/8/4P3/4K3 w - - 0 1", "KPKP", core.White},
+	}
+	for _, tt := range tests {
+		pos, _ := fen.Parse(tt.fen)
+		if got, want := materialKeyOf(pos), codeKey(tt.code, tt.strong); got != want {
+			t.Errorf("%s: key %x, want %s's %x", tt.fen, got, tt.code, want)
+		}
+	}
+}
+
+func kpkFromFEN(t *testing.T, f string) bool {
+	t.Helper()
+	pos, err := fen.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var psq int
+	for sq := range pos.Board.Pieces(core.NewPiece(core.Pawn, core.White)).Squares() {
+		psq = int(sq)
+	}
+	return kpkProbe(pos.ActiveColor, findKingSq(pos, core.White), psq, findKingSq(pos, core.Black))
+}
+
+func TestKPKKnownPositions(t *testing.T) {
+	tests := []struct {
+		fen string
+		win bool
+	}{
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 1", true},  // promotes unopposed
+		{"4k3/8/4K3/4P3/8/8/8/8 w - - 0 1", true}, // king on the sixth in front of its pawn
+		{"4k3/8/4K3/4P3/8/8/8/8 b - - 0 1", true},
+		{"k7/8/8/8/8/8/P7/K7 w - - 0 1", false},   // rook pawn, defender in the corner
+		{"8/8/8/8/8/8/3kP3/7K b - - 0 1", false},  // the pawn falls
+		{"4k3/4P3/4K3/8/8/8/8/8 b - - 0 1", false}, // stalemate
+		{"8/8/8/8/8/3k4/3P4/7K w - - 0 1", false}, // the pawn falls next move
+		{"8/8/8/8/8/8/3Pk3/7K w - - 0 1", true},   // d4 runs out of the king's square
+	}
+	for _, tt := range tests {
+		if got := kpkFromFEN(t, tt.fen); got != tt.win {
+			t.Errorf("%s: win = %v, want %v", tt.fen, got, tt.win)
+		}
+	}
+}
+
+// Every position's result must follow from its children's: white wins if a
+// move wins, black holds if a move holds.
+func TestKPKConsistent(t *testing.T) {
+	kings := []string{"a1", "c3", "e4", "h8", "b6", "g2", "d8", "f5"}
+	pawns := []string{"a2", "b4", "d6", "e7", "h5", "c2"}
+	for _, wk := range kings {
+		for _, bk := range kings {
+			for _, p := range pawns {
+				for _, stm := range []string{"w", "b"} {
+					pos := kpkPosition(wk, p, bk, stm)
+					if pos == nil {
+						continue
+					}
+					checkKPKNode(t, pos)
+				}
+			}
+		}
+	}
+}
+
+// kpkPosition builds a legal KPK position or returns nil.
+func kpkPosition(wk, p, bk, stm string) *position.Position {
+	sq := func(s string) int { return int(s[1]-'1')*8 + int(s[0]-'a') }
+	w, ps, b := sq(wk), sq(p), sq(bk)
+	if w == b || w == ps || b == ps || squareDistance(w, b) <= 1 {
+		return nil
+	}
+	pos := position.NewPosition()
+	pos.Board = core.NewChessboard()
+	pos.Board.Set(core.Square(w), core.NewPiece(core.King, core.White))
+	pos.Board.Set(core.Square(ps), core.NewPiece(core.Pawn, core.White))
+	pos.Board.Set(core.Square(b), core.NewPiece(core.King, core.Black))
+	if stm == "b" {
+		pos.ActiveColor = core.Black
+	}
+	// the side not to move can't be in check
+	other := position.MakeNullMove(pos)
+	if movegen.InCheck(other) {
+		return nil
+	}
+	return pos
+}
+
+func checkKPKNode(t *testing.T, pos *position.Position) {
+	t.Helper()
+	result := func(p *position.Position) bool {
+		var psq = -1
+		for sq := range p.Board.Pieces(core.NewPiece(core.Pawn, core.White)).Squares() {
+			psq = int(sq)
+		}
+		if psq < 0 {
+			return false // captured
+		}
+		if psq >= 56 {
+			return true // promoted and survived the bitbase's check
+		}
+		return kpkProbe(p.ActiveColor, findKingSq(p, core.White), psq, findKingSq(p, core.Black))
+	}
+
+	moves := movegen.LegalMoves(pos)
+	if moves.Count() == 0 {
+		if result(pos) {
+			t.Errorf("%v: no moves but scored a win", pos.Board)
+		}
+		return
+	}
+
+	white := pos.ActiveColor == core.White
+	want := !white
+	for i := 0; i < moves.Count(); i++ {
+		m := moves.Get(i)
+		child := position.MakeMove(pos, m)
+		if m.MoveType() == core.MovePromotion {
+			// only a queen that can't be taken is counted as a win
+			if m.PromoPiece() != core.Queen || movegen.IsAttacked(child, m.To(), core.Black) && !movegen.IsAttacked(child, m.To(), core.White) {
+				continue
+			}
+			want = true
+			break
+		}
+		if r := result(child); white && r {
+			want = true
+			break
+		} else if !white && !r {
+			want = false
+			break
+		}
+	}
+	if got := result(pos); got != want {
+		t.Errorf("%v %v to move: bitbase says %v, children say %v", pos.Board, pos.ActiveColor, got, want)
+	}
+}
+
+func TestEndgameEvaluators(t *testing.T) {
+	eval := func(f string) (int, Breakdown) {
+		pos, err := fen.Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := EvaluateDetailed(pos)
+		return b.Total, b
+	}
+
+	// drawn endings score nothing
+	for _, f := range []string{
+		"8/8/8/4k3/8/8/8/KN6 w - - 0 1",  // KNK
+		"8/8/8/4k3/8/8/8/KB6 w - - 0 1",  // KBK
+		"8/8/8/4k3/8/8/8/KNN5 w - - 0 1", // KNNK
+		"8/8/8/4k3/8/8/2n5/KB6 w - - 0 1",
+		"k7/8/8/8/8/8/P7/K1B5 w - - 0 1", // wrong bishop
+		"k7/8/8/8/8/8/P7/K7 w - - 0 1",   // KPK rook pawn
+	} {
+		if v, b := eval(f); v != 0 {
+			t.Errorf("%s: %d (%s), want a draw", f, v, b.Endgame)
+		}
+	}
+
+	// with the right bishop the general evaluation stands
+	if v, b := eval("k7/8/8/8/8/8/P7/KB6 w - - 0 1"); b.Scale != scaleNormal || v < 300 {
+		t.Errorf("right bishop: %d (%s)", v, b.Endgame)
+	}
+
+	// KRKB is close to level
+	if v, b := eval("4k3/8/8/8/8/8/2b5/R3K3 w - - 0 1"); b.Endgame != "KRKB" || v < 0 || v > 100 {
+		t.Errorf("KRKB: %d (%s)", v, b.Endgame)
+	}
+
+	// KQK: a known win, better with the king on the edge
+	center, b := eval("8/8/8/4k3/8/8/8/KQ6 w - - 0 1")
+	edge, _ := eval("4k3/8/8/8/8/8/8/KQ6 w - - 0 1")
+	if b.Endgame != "KXK" || center < knownWin || edge <= center {
+		t.Errorf("KQK: center %d, edge %d (%s)", center, edge, b.Endgame)
+	}
+	if v, _ := eval("4K3/8/8/8/8/8/8/kq6 w - - 0 1"); v > -knownWin {
+		t.Errorf("KQK for black: %d", v)
+	}
+
+	// KBNK: mate is only forced in the bishop's corners
+	right, _ := eval("8/8/8/8/8/2K5/8/k1B1N3 w - - 0 1")
+	wrong, _ := eval("k7/8/2K5/8/8/8/8/2B1N3 w - - 0 1")
+	if right <= wrong || wrong < knownWin {
+		t.Errorf("KBNK: right corner %d, wrong corner %d", right, wrong)
+	}
+}
+
+func TestOppositeBishopsScaled(t *testing.T) {
+	// white's light bishop against black's dark one, two pawns up
+	pos, _ := fen.Parse("4k3/8/3b4/8/2PP4/3B4/8/4K3 w - - 0 1")
+	b := EvaluateDetailed(pos)
+	if b.Endgame != "opposite bishops" || b.Scale >= scaleNormal || b.Total >= b.White() {
+		t.Errorf("scale %d (%s): total %d, unscaled %d", b.Scale, b.Endgame, b.Total, b.White())
+	}
+
+	// same colored bishops are left alone
+	pos, _ = fen.Parse("4k3/8/2b5/8/2PP4/3B4/8/4K3 w - - 0 1")
+	if b := EvaluateDetailed(pos); b.Scale != scaleNormal || b.Total != b.White() {
+		t.Errorf("same colored bishops scaled by %d (%s)", b.Scale, b.Endgame)
+	}
+}
+
+func TestEndgameSymmetric(t *testing.T) {
+	tests := [][2]string{
+		{"8/8/8/4k3/8/8/4P3/4K3 w - - 0 1", "4k3/4p3/8/8/4K3/8/8/8 b - - 0 1"},
+		{"8/8/8/8/8/2K5/8/k1B1N3 w - - 0 1", "K1b1n3/8/2k5/8/8/8/8/8 b - - 0 1"},
+		{"4k3/8/3b4/8/2PP4/3B4/8/4K3 w - - 0 1", "4k3/8/3b4/2pp4/8/3B4/8/4K3 b - - 0 1"},
+	}
+	for _, tt := range tests {
+		a, _ := fen.Parse(tt[0])
+		b, _ := fen.Parse(tt[1])
+		if Evaluate(a) != Evaluate(b) {
+			t.Errorf("%s = %d, mirrored %s = %d", tt[0], Evaluate(a), tt[1], Evaluate(b))
+		}
+	}
+}
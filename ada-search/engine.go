@@ -0,0 +1,201 @@
+package search
+
+import (
+	"sync/atomic"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/book"
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
+)
+
+// Engine keeps the state that outlives a single search, such as the
+// transposition table, so consecutive searches can reuse earlier work.
+type Engine struct {
+	tt *TT
+
+	// Options takes effect from the next search.
+	Options Options
+
+	// NodeLimit, unless 0, stops searches once a thread has searched that
+	// many nodes, though never before depth 1 is done. It takes effect
+	// from the next search.
+	NodeLimit uint64
+
+	// evaluation weights, copied into each search
+	eval evalParams
+
+	// network evaluation, used instead of eval when Options.NNUE is set
+	net *Network
+
+	// endgame tablebases, probed when Options.Tablebases is set
+	tb *tablebase.Tablebase
+
+	// opening book, played from when Options.OwnBook is set
+	book *book.Book
+
+	// keys of the positions played before the one searched, oldest first
+	gameKeys []uint64
+
+	// the search in progress, if any
+	running atomic.Pointer[sharedSearch]
+}
+
+// NewEngine creates an engine with a transposition table of hashMB megabytes.
+func NewEngine(hashMB int) *Engine {
+	return &Engine{
+		tt:      NewTT(hashMB),
+		Options: DefaultOptions(),
+		eval:    defaultEvalParams(),
+	}
+}
+
+// SetHash replaces the transposition table with one of the given size.
+func (e *Engine) SetHash(hashMB int) {
+	e.tt = NewTT(hashMB)
+}
+
+// Clear forgets everything learned by previous searches.
+func (e *Engine) Clear() {
+	e.tt.Clear()
+}
+
+// Stop ends the search in progress, which returns its best result so far.
+// It is safe to call from another goroutine and does nothing when no search
+// is running.
+func (e *Engine) Stop() {
+	if s := e.running.Load(); s != nil {
+		s.stop.Store(true)
+	}
+}
+
+// Hashfull reports transposition table occupancy in permille.
+func (e *Engine) Hashfull() int {
+	return e.tt.Hashfull()
+}
+
+// SetNetwork sets the network used when Options.NNUE is on, or removes it
+// when net is nil. It takes effect from the next search.
+func (e *Engine) SetNetwork(net *Network) {
+	e.net = net
+}
+
+// LoadNetwork reads a network weights file and uses it from the next search.
+func (e *Engine) LoadNetwork(path string) error {
+	net, err := LoadNetwork(path)
+	if err != nil {
+		return err
+	}
+	e.net = net
+	return nil
+}
+
+// network returns the network searches evaluate with, or nil for the
+// handcrafted evaluation.
+func (e *Engine) network() *Network {
+	if e.Options.NNUE {
+		return e.net
+	}
+	return nil
+}
+
+// UsesNetwork reports whether searches evaluate with the network.
+func (e *Engine) UsesNetwork() bool {
+	return e.network() != nil
+}
+
+// SetTablebase sets the endgame tablebases searches probe, or removes them
+// when tb is nil. It takes effect from the next search, and tb must not be
+// changed while a search uses it.
+func (e *Engine) SetTablebase(tb *tablebase.Tablebase) {
+	e.tb = tb
+}
+
+// LoadTablebases reads every table file in a directory and probes them
+// from the next search.
+func (e *Engine) LoadTablebases(dir string) error {
+	tb, err := tablebase.Load(dir)
+	if err != nil {
+		return err
+	}
+	e.tb = tb
+	return nil
+}
+
+// Tablebase returns the engine's tablebases, nil if there are none.
+func (e *Engine) Tablebase() *tablebase.Tablebase {
+	return e.tb
+}
+
+// SetHistory tells the engine the positions the game went through before
+// the next position it searches, oldest first, so it can see repetitions of
+// them. It should be called again whenever the game moves on, or with nil
+// when the next search starts a new one.
+func (e *Engine) SetHistory(positions []*position.Position) {
+	// a new slice, as a running search may still be reading the old one
+	keys := make([]uint64, len(positions))
+	for i, p := range positions {
+		keys[i] = p.Zobrist
+	}
+	e.gameKeys = keys
+}
+
+// SkillLevel returns the level the engine plays at, MaxSkillLevel for full
+// strength.
+func (e *Engine) SkillLevel() int {
+	return e.Options.skillLevel()
+}
+
+// tablebases returns the tablebases searches probe, or nil. A weakened
+// engine doesn't get perfect endgames.
+func (e *Engine) tablebases() *tablebase.Tablebase {
+	if e.Options.Tablebases && e.tb != nil && e.tb.MaxPieces() > 0 && e.SkillLevel() == MaxSkillLevel {
+		return e.tb
+	}
+	return nil
+}
+
+// SetBook sets the opening book searches play from, or removes it when b is
+// nil. It takes effect from the next search.
+func (e *Engine) SetBook(b *book.Book) {
+	e.book = b
+}
+
+// LoadBook reads a Polyglot book file and plays from it from the next
+// search.
+func (e *Engine) LoadBook(path string) error {
+	b, err := book.Load(path)
+	if err != nil {
+		return err
+	}
+	e.book = b
+	return nil
+}
+
+// Book returns the engine's opening book, nil if there is none.
+func (e *Engine) Book() *book.Book {
+	return e.book
+}
+
+// bookMove returns the book move a search plays in pos, if there is one.
+func (e *Engine) bookMove(pos *position.Position) (core.Move, bool) {
+	if !e.Options.OwnBook || e.book == nil {
+		return core.NoMove, false
+	}
+	return e.book.Pick(pos, e.Options.BookRandom)
+}
+
+// Evaluate is the static evaluation searches use: the network when it is on,
+// otherwise the handcrafted evaluation with this engine's weights.
+func (e *Engine) Evaluate(pos *position.Position) int {
+	if net := e.network(); net != nil {
+		return net.Evaluate(pos)
+	}
+	return e.eval.evaluate(pos, nil)
+}
+
+// EvaluateDetailed is the handcrafted evaluation with this engine's weights,
+// split into its terms.
+func (e *Engine) EvaluateDetailed(pos *position.Position) Breakdown {
+	return e.eval.evaluateDetailed(pos)
+}
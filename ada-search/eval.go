@@ -6,47 +6,52 @@ import (
 	"github.com/WilliamDann/AdaEngine/ada-chess/position"
 )
 
-// Piece values in centipawns.
-var pieceValue = [7]int{
-	0,    // None
-	100,  // Pawn
-	320,  // Knight
-	330,  // Bishop
-	500,  // Rook
-	900,  // Queen
-	0,    // King (not counted)
+// score is a pair of middlegame and endgame values. Every evaluation term is
+// scored both ways and the two are blended by game phase, so a term can
+// matter with queens on the board and fade in a king and pawn ending (or
+// the other way round).
+type score struct {
+	mg, eg int
 }
 
-// Advancement bonus per rank in centipawns (for non-pawn pieces).
-var advanceBonus = [7]int{
-	0,  // None
-	0,  // Pawn — uses pawnAdvance table instead
-	3,  // Knight
-	2,  // Bishop
-	1,  // Rook
-	1,  // Queen
-	0,  // King
+func (a score) add(b score) score {
+	return score{a.mg + b.mg, a.eg + b.eg}
 }
 
-// Pawn advancement bonus by rank. Negligible until very advanced.
-//            rank: 0  1  2  3  4   5   6   7
-var pawnAdvance = [8]int{0, 0, 0, 2, 5, 10, 25, 50}
-
-// Center bonus per piece type, scaled by closeness to center (0-3).
-var centerBonus = [7]int{
-	0,  // None
-	5,  // Pawn — control the center
-	5,  // Knight — strongest center preference
-	3,  // Bishop
-	1,  // Rook
-	2,  // Queen
-	0,  // King
+func (a score) scale(n int) score {
+	return score{a.mg * n, a.eg * n}
 }
 
-// King zone coordination weight — multiplied by count^2 so a lone piece
-// near the king gets almost nothing but a group gets a large bonus.
-const kingZoneWeight = 3
+// Game phase runs from maxPhase with all pieces on the board down to 0 with
+// only kings and pawns. Each non-pawn piece contributes its phase weight.
+const maxPhase = 24
+
+var phaseWeight = [7]int{
+	0, // None
+	0, // Pawn
+	1, // Knight
+	1, // Bishop
+	2, // Rook
+	4, // Queen
+	0, // King
+}
+
+// gamePhase returns the phase of the position from remaining material,
+// clamped to maxPhase so early promotions don't overflow it.
+func gamePhase(pos *position.Position) int {
+	phase := 0
+	for pt := core.Knight; pt <= core.Queen; pt++ {
+		n := pos.Board.Pieces(core.NewPiece(pt, core.White)).Count() +
+			pos.Board.Pieces(core.NewPiece(pt, core.Black)).Count()
+		phase += n * phaseWeight[pt]
+	}
+	return min(phase, maxPhase)
+}
 
+// taper blends a score by phase.
+func taper(s score, phase int) int {
+	return (s.mg*phase + s.eg*(maxPhase-phase)) / maxPhase
+}
 
 // Board region masks: queenside (files a-c), center (files d-e), kingside (files f-h).
 var regionMask [3]core.Bitboard
@@ -57,10 +62,6 @@ const (
 	regionKingside  = 2
 )
 
-// Weight for pieces in the broad region around the enemy king.
-// Lighter than the tight king zone — rewards directing forces to the right side.
-const kingRegionWeight = 2
-
 // Precomputed tables, filled in init().
 var (
 	centerDist [64]int           // Chebyshev distance from center (0-3)
@@ -127,48 +128,162 @@ func findKingSq(pos *position.Position, color core.Color) int {
 	return 0
 }
 
+// nonPawnMaterial sums the piece values of a side's knights, bishops, rooks
+// and queens.
+func (p *evalParams) nonPawnMaterial(pos *position.Position, color core.Color) int {
+	total := 0
+	for pt := core.Knight; pt <= core.Queen; pt++ {
+		total += pos.Board.Pieces(core.NewPiece(pt, color)).Count() * p.pieceValue[pt]
+	}
+	return total
+}
+
+// Term is one component of the evaluation.
+type Term int
+
+const (
+	TermMaterial    Term = iota // piece values
+	TermPST                     // piece placement: advancement, centralization and king position
+	TermKingZone                // groups of pieces next to the enemy king
+	TermKingRegion              // pieces on the enemy king's side of the board
+	TermPawnControl             // squares attacked by pawns
+	TermSpace                   // squares attacked by pieces and not by enemy pawns
+	TermOutposts                // squares only the enemy's pawns control
+	TermPawns                   // pawn structure: doubled, isolated, backward and connected pawns
+	TermPassed                  // passed pawns
+	TermMobility                // squares each piece can safely move to
+	TermKingSafety              // pawn shelter, open files and attacks near the king
+	TermPieces                  // bishop pair, rook files, outposts and trapped pieces
+	NumTerms
+)
+
+var termNames = [NumTerms]string{
+	"Material",
+	"Piece-square",
+	"King zone",
+	"King region",
+	"Pawn control",
+	"Space",
+	"Outposts",
+	"Pawns",
+	"Passed pawns",
+	"Mobility",
+	"King safety",
+	"Pieces",
+}
+
+func (t Term) String() string {
+	if t < 0 || t >= NumTerms {
+		return "Unknown"
+	}
+	return termNames[t]
+}
+
+// Breakdown explains an evaluation. Each term is scored separately for
+// white and black in centipawns from that side's own point of view, so a
+// bigger number is always better for the side it belongs to.
+type Breakdown struct {
+	Terms [NumTerms][2]int // indexed [term][0 = white, 1 = black]
+
+	// Phase is the game phase the terms were blended at, from maxPhase
+	// (all pieces on the board) down to 0 (kings and pawns only).
+	Phase int
+
+	// Endgame names the specialized evaluator that replaced the terms, or
+	// why they were scaled down, when endgame knowledge applied.
+	Endgame string
+
+	// Scale is how much of the terms' endgame half was kept, out of 64. It
+	// is 64 when nothing was scaled.
+	Scale int
+
+	// Total is the final score from the side to move's perspective, the
+	// same value Evaluate returns. It differs from White when endgame
+	// knowledge applied.
+	Total int
+}
+
+// Net returns a term's contribution from white's perspective.
+func (b *Breakdown) Net(t Term) int {
+	return b.Terms[t][0] - b.Terms[t][1]
+}
+
+// White returns the sum of the terms from white's perspective.
+func (b *Breakdown) White() int {
+	total := 0
+	for t := Term(0); t < NumTerms; t++ {
+		total += b.Net(t)
+	}
+	return total
+}
+
 // Evaluate returns a score in centipawns from the active color's perspective.
 // Positive means the active color is better.
+// It uses the default weights; see Engine.Evaluate for an engine's own.
 func Evaluate(pos *position.Position) int {
+	return defaultParams.evaluate(pos, nil)
+}
+
+// evaluate is Evaluate with pawn structure cached in pawns, which may be nil.
+func (p *evalParams) evaluate(pos *position.Position, pawns *pawnTable) int {
+	return p.evaluateTerms(pos, pawns, nil)
+}
+
+// EvaluateDetailed returns the evaluation split into its terms, using the
+// default weights.
+func EvaluateDetailed(pos *position.Position) Breakdown {
+	return defaultParams.evaluateDetailed(pos)
+}
+
+func (p *evalParams) evaluateDetailed(pos *position.Position) Breakdown {
+	var b Breakdown
+	p.evaluateTerms(pos, nil, &b)
+	return b
+}
+
+// evaluateTerms returns the evaluation from the active color's perspective.
+// When b is not nil it also fills in every term of b and its total; the
+// search passes nil so the hot path skips those stores.
+func (p *evalParams) evaluateTerms(pos *position.Position, pawns *pawnTable, b *Breakdown) int {
+	var terms [NumTerms][2]score
+
 	wkSq := findKingSq(pos, core.White)
 	bkSq := findKingSq(pos, core.Black)
 
-	score := 0
 	for pt := core.PieceType(1); pt <= 5; pt++ {
-		val := pieceValue[pt]
-		adv := advanceBonus[pt]
-		cb := centerBonus[pt]
+		val := p.pieceScore[pt]
+		adv := p.advanceBonus[pt]
+		cb := p.centerBonus[pt]
 
 		white := pos.Board.Pieces(core.NewPiece(pt, core.White))
 		black := pos.Board.Pieces(core.NewPiece(pt, core.Black))
 
-		ws := 0
+		terms[TermMaterial][0] = terms[TermMaterial][0].add(val.scale(white.Count()))
+		terms[TermMaterial][1] = terms[TermMaterial][1].add(val.scale(black.Count()))
+
 		for sq := range white.Squares() {
 			r := int(sq) / 8
 			if pt == core.Pawn {
-				ws += val + pawnAdvance[r] + cb*(3-centerDist[sq])
+				terms[TermPST][0] = terms[TermPST][0].add(p.pawnAdvance[r]).add(cb.scale(3 - centerDist[sq]))
 			} else {
-				ws += val + adv*r + cb*(3-centerDist[sq])
+				terms[TermPST][0] = terms[TermPST][0].add(adv.scale(r)).add(cb.scale(3 - centerDist[sq]))
 			}
 		}
 
-		bs := 0
 		for sq := range black.Squares() {
 			r := int(sq) / 8
 			if pt == core.Pawn {
-				bs += val + pawnAdvance[7-r] + cb*(3-centerDist[sq])
+				terms[TermPST][1] = terms[TermPST][1].add(p.pawnAdvance[7-r]).add(cb.scale(3 - centerDist[sq]))
 			} else {
-				bs += val + adv*(7-r) + cb*(3-centerDist[sq])
+				terms[TermPST][1] = terms[TermPST][1].add(adv.scale(7 - r)).add(cb.scale(3 - centerDist[sq]))
 			}
 		}
-
-		if pos.ActiveColor == core.White {
-			score += ws - bs
-		} else {
-			score += bs - ws
-		}
 	}
 
+	// Kings: black's tables are white's mirrored vertically.
+	terms[TermPST][0] = terms[TermPST][0].add(score{p.kingMG[wkSq], p.kingEG[wkSq]})
+	terms[TermPST][1] = terms[TermPST][1].add(score{p.kingMG[bkSq^56], p.kingEG[bkSq^56]})
+
 	// King zone coordination: count non-king pieces in 3x3 around enemy king.
 	// Bonus scales with count^2 so only group attacks are rewarded.
 	wNonKing := pos.Board.ColorPieces(core.White).Subtract(
@@ -186,19 +301,14 @@ func Evaluate(pos *position.Position) int {
 	wRegionCount := regionMask[bkRegion].Intersection(wNonKing).Count()
 	bRegionCount := regionMask[wkRegion].Intersection(bNonKing).Count()
 
-	if pos.ActiveColor == core.White {
-		score += wZoneCount*wZoneCount*kingZoneWeight - bZoneCount*bZoneCount*kingZoneWeight
-		score += wRegionCount*kingRegionWeight - bRegionCount*kingRegionWeight
-	} else {
-		score += bZoneCount*bZoneCount*kingZoneWeight - wZoneCount*wZoneCount*kingZoneWeight
-		score += bRegionCount*kingRegionWeight - wRegionCount*kingRegionWeight
-	}
+	terms[TermKingZone][0] = p.kingZoneWeight.scale(wZoneCount * wZoneCount)
+	terms[TermKingZone][1] = p.kingZoneWeight.scale(bZoneCount * bZoneCount)
+	terms[TermKingRegion][0] = p.kingRegionWeight.scale(wRegionCount)
+	terms[TermKingRegion][1] = p.kingRegionWeight.scale(bRegionCount)
 
 	// Space control: pawn attacks are strong permanent control,
 	// piece attacks only count where enemy pawns don't cover.
 	// Enemy outposts (squares they attack with pawns that we don't) are penalized.
-	occupied := pos.Board.Occupied()
-
 	var wPawnAtk, bPawnAtk core.Bitboard
 	for sq := range pos.Board.Pieces(core.NewPiece(core.Pawn, core.White)).Squares() {
 		wPawnAtk = wPawnAtk.Union(movegen.PawnAttacks(sq, core.White))
@@ -207,37 +317,12 @@ func Evaluate(pos *position.Position) int {
 		bPawnAtk = bPawnAtk.Union(movegen.PawnAttacks(sq, core.Black))
 	}
 
-	var wPieceAtk, bPieceAtk core.Bitboard
-	for _, pt := range []core.PieceType{core.Knight, core.Bishop, core.Rook, core.Queen, core.King} {
-		for sq := range pos.Board.Pieces(core.NewPiece(pt, core.White)).Squares() {
-			switch pt {
-			case core.Knight:
-				wPieceAtk = wPieceAtk.Union(movegen.KnightMoves(sq))
-			case core.Bishop:
-				wPieceAtk = wPieceAtk.Union(movegen.BishopMoves(sq, occupied))
-			case core.Rook:
-				wPieceAtk = wPieceAtk.Union(movegen.RookMoves(sq, occupied))
-			case core.Queen:
-				wPieceAtk = wPieceAtk.Union(movegen.BishopMoves(sq, occupied).Union(movegen.RookMoves(sq, occupied)))
-			case core.King:
-				wPieceAtk = wPieceAtk.Union(movegen.KingMoves(sq))
-			}
-		}
-		for sq := range pos.Board.Pieces(core.NewPiece(pt, core.Black)).Squares() {
-			switch pt {
-			case core.Knight:
-				bPieceAtk = bPieceAtk.Union(movegen.KnightMoves(sq))
-			case core.Bishop:
-				bPieceAtk = bPieceAtk.Union(movegen.BishopMoves(sq, occupied))
-			case core.Rook:
-				bPieceAtk = bPieceAtk.Union(movegen.RookMoves(sq, occupied))
-			case core.Queen:
-				bPieceAtk = bPieceAtk.Union(movegen.BishopMoves(sq, occupied).Union(movegen.RookMoves(sq, occupied)))
-			case core.King:
-				bPieceAtk = bPieceAtk.Union(movegen.KingMoves(sq))
-			}
-		}
-	}
+	// Piece activity, which also gives the squares each side's pieces attack
+	wPieces := p.evaluatePieces(pos, core.White, wPawnAtk, bPawnAtk, bkSq)
+	bPieces := p.evaluatePieces(pos, core.Black, bPawnAtk, wPawnAtk, wkSq)
+
+	wPieceAtk := wPieces.attacks.Union(movegen.KingMoves(core.Square(wkSq)))
+	bPieceAtk := bPieces.attacks.Union(movegen.KingMoves(core.Square(bkSq)))
 
 	// Safe piece control: squares attacked by pieces but not defended by enemy pawns
 	wSafe := wPieceAtk.Subtract(bPawnAtk).Count()
@@ -247,21 +332,77 @@ func Evaluate(pos *position.Position) int {
 	wOutposts := bPawnAtk.Subtract(wPawnAtk).Count() // squares black controls with pawns, we can't contest
 	bOutposts := wPawnAtk.Subtract(bPawnAtk).Count() // squares white controls with pawns, black can't contest
 
-	const (
-		pawnControlWeight = 3
-		safeControlWeight = 1
-		outpostPenalty    = 2
-	)
-
-	if pos.ActiveColor == core.White {
-		score += wPawnAtk.Count()*pawnControlWeight - bPawnAtk.Count()*pawnControlWeight
-		score += wSafe*safeControlWeight - bSafe*safeControlWeight
-		score -= wOutposts*outpostPenalty - bOutposts*outpostPenalty
-	} else {
-		score += bPawnAtk.Count()*pawnControlWeight - wPawnAtk.Count()*pawnControlWeight
-		score += bSafe*safeControlWeight - wSafe*safeControlWeight
-		score -= bOutposts*outpostPenalty - wOutposts*outpostPenalty
+	terms[TermPawnControl][0] = p.pawnControlWeight.scale(wPawnAtk.Count())
+	terms[TermPawnControl][1] = p.pawnControlWeight.scale(bPawnAtk.Count())
+	terms[TermSpace][0] = p.safeControlWeight.scale(wSafe)
+	terms[TermSpace][1] = p.safeControlWeight.scale(bSafe)
+	terms[TermOutposts][0] = p.outpostPenalty.scale(-wOutposts)
+	terms[TermOutposts][1] = p.outpostPenalty.scale(-bOutposts)
+
+	terms[TermMobility][0] = wPieces.mobility
+	terms[TermMobility][1] = bPieces.mobility
+	terms[TermPieces][0] = wPieces.pieces
+	terms[TermPieces][1] = bPieces.pieces
+
+	// King safety: the shelter in front of our king less the pressure the
+	// enemy's pieces put on it.
+	terms[TermKingSafety][0] = p.kingShelter(pos, core.White, wkSq).add(bPieces.kingAttack.scale(-1))
+	terms[TermKingSafety][1] = p.kingShelter(pos, core.Black, bkSq).add(wPieces.kingAttack.scale(-1))
+
+	// Pawn structure comes from the pawn hash; passed pawns also depend on
+	// the kings and other pieces, so they are scored fresh every time.
+	pe := pawns.probe(p, pos)
+	terms[TermPawns][0] = pe.score[0]
+	terms[TermPawns][1] = pe.score[1]
+	terms[TermPassed][0] = p.passedPawns(pos, pe.passed[0], core.White, wkSq, bkSq)
+	terms[TermPassed][1] = p.passedPawns(pos, pe.passed[1], core.Black, bkSq, wkSq)
+
+	// blend every term by phase
+	phase := gamePhase(pos)
+	var net score
+	white := 0
+	for t := range terms {
+		w, bl := taper(terms[t][0], phase), taper(terms[t][1], phase)
+		if b != nil {
+			b.Terms[t][0], b.Terms[t][1] = w, bl
+		}
+		white += w - bl
+		net = net.add(terms[t][0]).add(terms[t][1].scale(-1))
 	}
 
-	return score
+	total, scale, name := p.applyEndgame(pos, phase, white, net)
+	if pos.ActiveColor == core.Black {
+		total = -total
+	}
+	if b != nil {
+		b.Phase, b.Scale, b.Endgame, b.Total = phase, scale, name, total
+	}
+	return total
+}
+
+// applyEndgame consults the endgame knowledge and returns the evaluation
+// from white's perspective: a specialized evaluator's score, or the terms'
+// tapered sum, white, with its endgame half, net, scaled toward a draw.
+// It also returns the scale applied and the name of the endgame, if any.
+func (p *evalParams) applyEndgame(pos *position.Position, phase, white int, net score) (int, int, string) {
+	key := materialKeyOf(pos)
+	if v, name, ok := p.endgameEval(pos, key); ok {
+		return v, scaleNormal, name
+	}
+	if net.eg == 0 {
+		return white, scaleNormal, ""
+	}
+
+	strong := core.White
+	if net.eg < 0 {
+		strong = core.Black
+	}
+	scale, name := p.endgameScale(pos, key, strong)
+	switch scale {
+	case scaleNormal:
+		return white, scale, name
+	case scaleDraw:
+		return 0, scale, name
+	}
+	return taper(score{net.mg, net.eg * scale / scaleNormal}, phase), scale, name
 }
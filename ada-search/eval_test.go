@@ -0,0 +1,164 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+var evalPositions = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r1bqkbnr/pppppppp/2n5/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2",
+	"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+	"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 b - - 0 1",
+}
+
+// TestEvaluateFastPath checks that the search's evaluation, which skips the
+// breakdown, gives the same total as EvaluateDetailed, endgames included.
+func TestEvaluateFastPath(t *testing.T) {
+	fens := append([]string{
+		"4k3/8/8/8/8/8/4P3/4K3 b - - 0 1",       // KPK, specialized evaluator
+		"4k3/8/8/8/3r4/8/3R4/4K3 w - - 0 1",     // KRKR, scaled
+		"8/8/8/4k3/8/8/8/KB6 w - - 0 1",         // KBK, drawn
+		"7k/8/8/8/8/8/P7/K1B5 b - - 0 1",        // wrong rook pawn
+		"6k1/5ppp/8/8/8/8/5PPP/3Q2K1 w - - 0 1", // unscaled
+	}, evalPositions...)
+	pawns := newPawnTable()
+	for _, f := range fens {
+		pos, err := fen.Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := EvaluateDetailed(pos).Total
+		if got := defaultParams.evaluate(pos, nil); got != want {
+			t.Errorf("%s: evaluate = %d, EvaluateDetailed total = %d", f, got, want)
+		}
+		if got := defaultParams.evaluate(pos, pawns); got != want {
+			t.Errorf("%s: evaluate with pawn table = %d, EvaluateDetailed total = %d", f, got, want)
+		}
+	}
+}
+
+func TestEvaluateDetailedMatchesEvaluate(t *testing.T) {
+	for _, f := range evalPositions {
+		pos, err := fen.Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := EvaluateDetailed(pos)
+		if b.Total != Evaluate(pos) {
+			t.Errorf("%s: breakdown total %d != Evaluate %d", f, b.Total, Evaluate(pos))
+		}
+
+		sum := 0
+		for term := Term(0); term < NumTerms; term++ {
+			sum += b.Net(term)
+		}
+		if sum != b.White() {
+			t.Errorf("%s: terms sum to %d, White() = %d", f, sum, b.White())
+		}
+	}
+}
+
+func TestEvaluateDetailedStartingPosition(t *testing.T) {
+	pos, _ := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b := EvaluateDetailed(pos)
+
+	for term := Term(0); term < NumTerms; term++ {
+		if b.Net(term) != 0 {
+			t.Errorf("%s is not balanced: white %d, black %d", term, b.Terms[term][0], b.Terms[term][1])
+		}
+	}
+	if want := 8*100 + 2*320 + 2*330 + 2*500 + 900; b.Terms[TermMaterial][0] != want {
+		t.Errorf("white material = %d, want %d", b.Terms[TermMaterial][0], want)
+	}
+}
+
+func TestTermString(t *testing.T) {
+	seen := make(map[string]bool)
+	for term := Term(0); term < NumTerms; term++ {
+		name := term.String()
+		if name == "" || name == "Unknown" || seen[name] {
+			t.Errorf("term %d has bad name %q", term, name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestGamePhase(t *testing.T) {
+	tests := []struct {
+		fen   string
+		phase int
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", maxPhase},
+		{"4k3/pppppppp/8/8/8/8/PPPPPPPP/4K3 w - - 0 1", 0},
+		{"r3k3/8/8/8/8/8/8/3QK3 w - - 0 1", 6},
+		// extra queens don't push the phase past the opening
+		{"qqqqkqqq/8/8/8/8/8/8/QQQQKQQQ w - - 0 1", maxPhase},
+	}
+	for _, tt := range tests {
+		pos, _ := fen.Parse(tt.fen)
+		if got := gamePhase(pos); got != tt.phase {
+			t.Errorf("%s: phase %d, want %d", tt.fen, got, tt.phase)
+		}
+		if got := EvaluateDetailed(pos).Phase; got != tt.phase {
+			t.Errorf("%s: breakdown phase %d, want %d", tt.fen, got, tt.phase)
+		}
+	}
+}
+
+func TestTaper(t *testing.T) {
+	s := score{100, 20}
+	if got := taper(s, maxPhase); got != 100 {
+		t.Errorf("opening taper = %d, want 100", got)
+	}
+	if got := taper(s, 0); got != 20 {
+		t.Errorf("endgame taper = %d, want 20", got)
+	}
+	if got := taper(s, maxPhase/2); got != 60 {
+		t.Errorf("half taper = %d, want 60", got)
+	}
+}
+
+// Mirroring a position vertically and swapping colors must not change the
+// evaluation from the side to move's point of view.
+func TestEvaluateSymmetric(t *testing.T) {
+	tests := [][2]string{
+		{
+			"r1bqkbnr/pppppppp/2n5/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2",
+			"rnbqkb1r/pppp1ppp/5n2/4p3/8/2N5/PPPPPPPP/R1BQKBNR w KQkq - 2 2",
+		},
+		{
+			"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 b - - 0 1",
+			"8/4p1p1/8/1r3P1K/kp5R/3P4/2P5/8 w - - 0 1",
+		},
+		{
+			"6k1/5ppp/8/8/8/8/1K6/8 w - - 0 1",
+			"8/1k6/8/8/8/8/5PPP/6K1 b - - 0 1",
+		},
+	}
+	for _, tt := range tests {
+		a, _ := fen.Parse(tt[0])
+		b, _ := fen.Parse(tt[1])
+		if Evaluate(a) != Evaluate(b) {
+			t.Errorf("%s = %d, mirrored %s = %d", tt[0], Evaluate(a), tt[1], Evaluate(b))
+		}
+	}
+}
+
+// In a pawn ending the king belongs in the center; with pieces on the board
+// it belongs behind its pawns.
+func TestKingPlacementByPhase(t *testing.T) {
+	// a pawn each, so it isn't KPK and left to the bitbase
+	central, _ := fen.Parse("4k3/7p/8/8/3K4/8/4P3/8 w - - 0 1")
+	corner, _ := fen.Parse("4k3/7p/8/8/8/8/4P3/K7 w - - 0 1")
+	if Evaluate(central) <= Evaluate(corner) {
+		t.Errorf("endgame: central king %d should beat cornered king %d", Evaluate(central), Evaluate(corner))
+	}
+
+	castled, _ := fen.Parse("r1bq1rk1/pppp1ppp/2n2n2/2b1p3/2B1P3/2N2N2/PPPP1PPP/R1BQ1RK1 w - - 0 1")
+	exposed, _ := fen.Parse("r1bq1rk1/pppp1ppp/2n2n2/2b1p3/2B1P3/2N2NK1/PPPP1PPP/R1BQ1R2 w - - 0 1")
+	if Evaluate(castled) <= Evaluate(exposed) {
+		t.Errorf("middlegame: castled king %d should beat exposed king %d", Evaluate(castled), Evaluate(exposed))
+	}
+}
@@ -0,0 +1,194 @@
+package search
+
+// evalParams holds every weight the evaluation uses. Scores are middlegame,
+// endgame pairs; tables indexed by rank are from the scoring side's point of
+// view, tables indexed by piece type use core.PieceType.
+type evalParams struct {
+	// piece values the search uses for move ordering and pruning margins
+	pieceValue [7]int
+
+	// material. Pawns and rooks gain in the endgame, knights lose as the
+	// board empties.
+	pieceScore [7]score
+
+	// piece placement
+	advanceBonus [7]score  // per rank advanced, non-pawn pieces
+	pawnAdvance  [8]score  // pawns, by rank
+	centerBonus  [7]score  // scaled by closeness to center (0-3)
+	kingMG       [64]int   // king placement, white's point of view (a1 = 0)
+	kingEG       [64]int
+
+	// king zone coordination — multiplied by count^2 so a lone piece near
+	// the king gets almost nothing but a group gets a large bonus
+	kingZoneWeight score
+
+	// pieces in the broad region around the enemy king. Lighter than the
+	// tight king zone — rewards directing forces to the right side.
+	kingRegionWeight score
+
+	// space control
+	pawnControlWeight score // per square attacked by a pawn
+	safeControlWeight score // per square attacked by a piece and not an enemy pawn
+	outpostPenalty    score // per square only the enemy's pawns control
+
+	// pawn structure
+	doubledPenalty  score    // per pawn with a friendly pawn behind it
+	isolatedPenalty score    // no friendly pawns on adjacent files
+	backwardPenalty score    // can't be supported and its stop square is attacked
+	connectedBonus  [8]score // supported by a pawn or standing next to one
+
+	// passed pawns
+	passedBonus           [8]score // no enemy pawn can stop or capture it
+	passedFreeBonus       [8]score // nothing stands between it and promotion
+	passedEnemyKingWeight int      // endgame, per square from the enemy king to the stop square
+	passedOwnKingWeight   int      // endgame, per square from our king to the stop square
+
+	// mobility: per square a piece attacks that isn't ours or covered by an
+	// enemy pawn, counted from a typical number of squares for the piece
+	mobility     [7]score
+	mobilityBase [7]int
+
+	// king safety
+	kingAttack       [7]score // per king zone square attacked, when two or more pieces attack
+	shieldNear       score    // pawn directly in front of the king
+	shieldFar        score    // pawn two ranks in front of the king
+	kingOpenFile     score    // no pawns at all on a file next to the king
+	kingSemiOpenFile score    // only enemy pawns on a file next to the king
+
+	// pieces
+	bishopPair       score
+	rookOpenFile     score
+	rookSemiOpenFile score
+	rookSeventh      score // on the 7th with the enemy king or pawns on the 8th or 7th
+	knightOutpost    score // supported by a pawn, no enemy pawn can chase it away
+	trappedBishop    score // a7/h7 bishop shut in by a pawn on b6/g6
+	trappedRook      score // boxed in on the back rank by its own king
+}
+
+// defaultParams are the weights used by Evaluate. Never modify them; an
+// Engine keeps its own copy.
+var defaultParams = defaultEvalParams()
+
+func defaultEvalParams() evalParams {
+	return evalParams{
+		pieceValue: [7]int{
+			0,   // None
+			100, // Pawn
+			320, // Knight
+			330, // Bishop
+			500, // Rook
+			900, // Queen
+			0,   // King (not counted)
+		},
+
+		pieceScore: [7]score{
+			{0, 0},     // None
+			{100, 120}, // Pawn
+			{320, 300}, // Knight
+			{330, 330}, // Bishop
+			{500, 530}, // Rook
+			{900, 950}, // Queen
+			{0, 0},     // King (not counted)
+		},
+
+		advanceBonus: [7]score{
+			{0, 0}, // None
+			{0, 0}, // Pawn — uses pawnAdvance table instead
+			{3, 1}, // Knight
+			{2, 1}, // Bishop
+			{1, 1}, // Rook
+			{1, 1}, // Queen
+			{0, 0}, // King — uses the king tables instead
+		},
+
+		// Negligible until very advanced in the middlegame, worth much more
+		// once pieces are off and pawns can run.
+		//                   rank:    0       1       2       3        4        5        6        7
+		pawnAdvance: [8]score{{0, 0}, {0, 0}, {0, 0}, {2, 5}, {5, 15}, {10, 30}, {25, 60}, {50, 90}},
+
+		centerBonus: [7]score{
+			{0, 0}, // None
+			{5, 0}, // Pawn — control the center
+			{5, 3}, // Knight — strongest center preference
+			{3, 2}, // Bishop
+			{1, 0}, // Rook
+			{2, 3}, // Queen
+			{0, 0}, // King — uses the king tables instead
+		},
+
+		// In the middlegame the king hides behind its pawns near a corner;
+		// in the endgame it belongs in the center.
+		kingMG: [64]int{
+			 20,  30,  10,   0,   0,  10,  30,  20,
+			 10,  10,  -5, -10, -10,  -5,  10,  10,
+			-10, -20, -20, -25, -25, -20, -20, -10,
+			-20, -30, -30, -40, -40, -30, -30, -20,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+			-30, -40, -40, -50, -50, -40, -40, -30,
+		},
+		kingEG: [64]int{
+			-50, -30, -30, -30, -30, -30, -30, -50,
+			-30, -20,   0,   0,   0,   0, -20, -30,
+			-30, -10,  20,  30,  30,  20, -10, -30,
+			-30, -10,  30,  40,  40,  30, -10, -30,
+			-30, -10,  30,  40,  40,  30, -10, -30,
+			-30, -10,  20,  30,  30,  20, -10, -30,
+			-30, -20,   0,   0,   0,   0, -20, -30,
+			-50, -30, -30, -30, -30, -30, -30, -50,
+		},
+
+		// attacks on the king matter far less once the queens are gone
+		kingZoneWeight:   score{3, 1},
+		kingRegionWeight: score{2, 0},
+
+		pawnControlWeight: score{3, 2},
+		safeControlWeight: score{1, 1},
+		outpostPenalty:    score{2, 1},
+
+		doubledPenalty:  score{-10, -20},
+		isolatedPenalty: score{-10, -15},
+		backwardPenalty: score{-8, -10},
+		//                      rank:    0       1       2       3        4         5         6       7
+		connectedBonus: [8]score{{0, 0}, {0, 0}, {3, 3}, {5, 5}, {10, 12}, {18, 25}, {30, 45}, {0, 0}},
+
+		passedBonus:           [8]score{{0, 0}, {5, 10}, {10, 15}, {15, 25}, {25, 45}, {40, 75}, {60, 110}, {0, 0}},
+		passedFreeBonus:       [8]score{{0, 0}, {0, 2}, {0, 4}, {2, 8}, {5, 15}, {10, 25}, {15, 40}, {0, 0}},
+		passedEnemyKingWeight: 5,
+		passedOwnKingWeight:   2,
+
+		mobility: [7]score{
+			{0, 0}, // None
+			{0, 0}, // Pawn
+			{4, 4}, // Knight
+			{5, 5}, // Bishop
+			{2, 4}, // Rook
+			{1, 2}, // Queen
+			{0, 0}, // King
+		},
+		mobilityBase: [7]int{0, 0, 4, 6, 7, 13, 0},
+
+		kingAttack: [7]score{
+			{0, 0}, // None
+			{0, 0}, // Pawn
+			{2, 0}, // Knight
+			{2, 0}, // Bishop
+			{3, 0}, // Rook
+			{5, 0}, // Queen
+			{0, 0}, // King
+		},
+		shieldNear:       score{10, 0},
+		shieldFar:        score{5, 0},
+		kingOpenFile:     score{-20, 0},
+		kingSemiOpenFile: score{-10, 0},
+
+		bishopPair:       score{30, 50},
+		rookOpenFile:     score{20, 10},
+		rookSemiOpenFile: score{10, 5},
+		rookSeventh:      score{10, 20},
+		knightOutpost:    score{20, 10},
+		trappedBishop:    score{-100, -100},
+		trappedRook:      score{-40, -10},
+	}
+}
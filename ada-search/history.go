@@ -0,0 +1,211 @@
+package search
+
+import (
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
+)
+
+// maxHistory bounds every history score. Updates use "gravity": the closer a
+// score is to the bound, the less a bonus moves it, so scores saturate
+// smoothly instead of overflowing and recent results keep mattering.
+const maxHistory = 16384
+
+// butterfly history, indexed by [color][from][to]
+type butterflyHistory [2][64][64]int16
+
+// pieceToHistory scores a move by the piece moved and its destination.
+type pieceToHistory [15][64]int16
+
+// continuation history: for the previous move's (piece, to), how good the
+// current move's (piece, to) has been as a follow-up
+type continuationHistory [15][64]pieceToHistory
+
+// counter moves: the quiet reply that last refuted the previous (piece, to)
+type counterMoves [15][64]core.Move
+
+// stackEntry records the move played at a ply so deeper nodes can index
+// counter-move and continuation tables by it.
+type stackEntry struct {
+	move  core.Move
+	piece core.Piece
+
+	// excluded is skipped by the search at this ply during singular
+	// extension verification
+	excluded core.Move
+}
+
+// threadData is the per-thread search state: move ordering tables and the
+// move stack. Tables are not shared, so no synchronization is needed.
+type threadData struct {
+	opts      Options
+	eval      *evalParams
+	lmr       *lmrTable
+	rootDepth int
+
+	// null move pruning is disabled for plies below this while a null
+	// move cutoff is being verified
+	nullMinPly int
+
+	// the side the search is for, whose draws Contempt counts against
+	rootColor core.Color
+
+	// position keys along the search path by ply, and of the game before
+	// the root, oldest first, to find repetitions
+	keys     [maxPly + 1]uint64
+	gameKeys []uint64
+
+	killers  killers
+	history  butterflyHistory
+	counters counterMoves
+	contHist continuationHistory
+	stack    [maxPly + 1]stackEntry
+
+	// pawn structure cache for the evaluation
+	pawns *pawnTable
+
+	// accumulators for the network evaluation, nil when it is off
+	nn *nnStack
+
+	// endgame tablebases, nil when there are none, and positions found
+	tb     *tablebase.Tablebase
+	tbHits uint64
+
+	// a node budget, 0 for none, set by Engine.NodeLimit or the skill
+	// level, and for skill levels noise of up to noise centipawns added to
+	// the evaluation
+	nodeLimit uint64
+	noise     int
+	noiseSeed uint64
+}
+
+func newThreadData() *threadData {
+	return &threadData{
+		opts:  DefaultOptions(),
+		eval:  &defaultParams,
+		lmr:   defaultLMR,
+		pawns: newPawnTable(),
+	}
+}
+
+// evaluate scores pos, the node at ply, for the side to move with whichever
+// evaluation this search uses.
+func (td *threadData) evaluate(pos *position.Position, ply int) int {
+	var v int
+	if td.nn != nil {
+		v = td.nn.evaluate(ply, pos)
+	} else {
+		v = td.eval.evaluate(pos, td.pawns)
+	}
+	if td.noise > 0 {
+		v += evalNoise(pos.Zobrist, td.noiseSeed, td.noise)
+	}
+	return v
+}
+
+// Move ordering scores. Quiet moves are ordered by history, which stays well
+// inside (badCaptureScore, counterScore).
+const (
+	ttMoveScore      = 1 << 30
+	goodCaptureScore = 1 << 20
+	killerScore      = 1 << 19
+	counterScore     = killerScore - 1
+	badCaptureScore  = -(1 << 20)
+)
+
+// scoreMove gives the ordering score of a move at ply: the TT move, then
+// captures that don't lose material, killers, the counter move, quiets by
+// history and finally losing captures.
+func (td *threadData) scoreMove(pos *position.Position, ply int, m, ttMove, counter core.Move) int {
+	if m == ttMove {
+		return ttMoveScore
+	}
+	if pos.Board.Check(m.To()) != core.None {
+		if s := td.eval.mvvlva(pos, m); s >= 0 {
+			return goodCaptureScore + s
+		}
+		return badCaptureScore + td.eval.mvvlva(pos, m)
+	}
+	if td.killers.isKiller(ply, m) {
+		return killerScore
+	}
+	if m == counter {
+		return counterScore
+	}
+	return td.quietScore(pos, ply, m)
+}
+
+// historyBonus is the update size for a cutoff at the given depth.
+func historyBonus(depth int) int {
+	return min(32*depth*depth, 1536)
+}
+
+func applyGravity(entry *int16, bonus int) {
+	v := int(*entry)
+	v += bonus - v*abs(bonus)/maxHistory
+	*entry = int16(v)
+}
+
+func colorIndex(c core.Color) int {
+	if c == core.Black {
+		return 1
+	}
+	return 0
+}
+
+// prev returns the move played n plies before ply, if there is one.
+func (td *threadData) prev(ply, n int) (stackEntry, bool) {
+	i := ply - n
+	if i < 0 || i > maxPly || td.stack[i].move == core.NoMove {
+		return stackEntry{}, false
+	}
+	return td.stack[i], true
+}
+
+// quietScore is the combined history score of a quiet move at ply.
+func (td *threadData) quietScore(pos *position.Position, ply int, m core.Move) int {
+	piece := pos.Board.Check(m.From())
+	score := int(td.history[colorIndex(pos.ActiveColor)][m.From()][m.To()])
+	if p, ok := td.prev(ply, 1); ok {
+		score += int(td.contHist[p.piece][p.move.To()][piece][m.To()])
+	}
+	if p, ok := td.prev(ply, 2); ok {
+		score += int(td.contHist[p.piece][p.move.To()][piece][m.To()])
+	}
+	return score
+}
+
+// counterMove returns the stored refutation of the move that led to ply.
+func (td *threadData) counterMove(ply int) core.Move {
+	if p, ok := td.prev(ply, 1); ok {
+		return td.counters[p.piece][p.move.To()]
+	}
+	return core.NoMove
+}
+
+// updateQuietStats rewards the quiet move that caused a cutoff and penalizes
+// the quiet moves searched before it at the same node.
+func (td *threadData) updateQuietStats(pos *position.Position, ply, depth int, best core.Move, tried []core.Move) {
+	bonus := historyBonus(depth)
+
+	td.killers.store(ply, best)
+	if p, ok := td.prev(ply, 1); ok {
+		td.counters[p.piece][p.move.To()] = best
+	}
+
+	td.updateQuiet(pos, ply, best, bonus)
+	for _, m := range tried {
+		td.updateQuiet(pos, ply, m, -bonus)
+	}
+}
+
+func (td *threadData) updateQuiet(pos *position.Position, ply int, m core.Move, bonus int) {
+	piece := pos.Board.Check(m.From())
+	applyGravity(&td.history[colorIndex(pos.ActiveColor)][m.From()][m.To()], bonus)
+	if p, ok := td.prev(ply, 1); ok {
+		applyGravity(&td.contHist[p.piece][p.move.To()][piece][m.To()], bonus)
+	}
+	if p, ok := td.prev(ply, 2); ok {
+		applyGravity(&td.contHist[p.piece][p.move.To()][piece][m.To()], bonus)
+	}
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+func TestHistoryGravityIsBounded(t *testing.T) {
+	var v int16
+	for i := 0; i < 1000; i++ {
+		applyGravity(&v, historyBonus(20))
+	}
+	if v <= 0 || int(v) > maxHistory {
+		t.Fatalf("history saturated at %d, want (0, %d]", v, maxHistory)
+	}
+
+	for i := 0; i < 1000; i++ {
+		applyGravity(&v, -historyBonus(20))
+	}
+	if v >= 0 || int(v) < -maxHistory {
+		t.Fatalf("history saturated at %d, want [-%d, 0)", v, maxHistory)
+	}
+}
+
+func TestUpdateQuietStats(t *testing.T) {
+	pos, _ := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	td := newThreadData()
+
+	e4 := core.NewMove(core.NewSquare(1, 4), core.NewSquare(3, 4))
+	a3 := core.NewMove(core.NewSquare(1, 0), core.NewSquare(2, 0))
+	h3 := core.NewMove(core.NewSquare(1, 7), core.NewSquare(2, 7))
+
+	// a previous move so counter and continuation tables are indexed
+	nf6 := core.NewMove(core.NewSquare(7, 6), core.NewSquare(5, 5))
+	td.stack[0] = stackEntry{move: nf6, piece: core.NewPiece(core.Knight, core.Black)}
+
+	td.updateQuietStats(pos, 1, 6, e4, []core.Move{a3, h3})
+
+	if td.quietScore(pos, 1, e4) <= 0 {
+		t.Error("cutoff move should have a positive history")
+	}
+	if td.quietScore(pos, 1, a3) >= 0 || td.quietScore(pos, 1, h3) >= 0 {
+		t.Error("failed quiets should have a negative history")
+	}
+	if !td.killers.isKiller(1, e4) {
+		t.Error("cutoff move should be a killer")
+	}
+	if td.counterMove(1) != e4 {
+		t.Errorf("counter move = %s, want %s", td.counterMove(1), e4)
+	}
+
+	// continuation history only applies after the same previous move
+	withPrev := td.quietScore(pos, 1, e4)
+	td.stack[0] = stackEntry{}
+	if withoutPrev := td.quietScore(pos, 1, e4); withoutPrev >= withPrev {
+		t.Errorf("continuation history should raise the score: %d vs %d", withPrev, withoutPrev)
+	}
+}
+
+func TestScoreMoveOrdering(t *testing.T) {
+	// white can capture the queen with a pawn or the pawn with the queen
+	pos, _ := fen.Parse("4k3/8/8/3q4/4P3/8/3p4/3QK3 w - - 0 1")
+	td := newThreadData()
+
+	exd5 := core.NewMove(core.NewSquare(3, 4), core.NewSquare(4, 3))
+	qxd2 := core.NewMove(core.NewSquare(0, 3), core.NewSquare(1, 3))
+	qd4 := core.NewMove(core.NewSquare(0, 3), core.NewSquare(3, 3))
+	e5 := core.NewMove(core.NewSquare(3, 4), core.NewSquare(4, 4))
+	td.killers.store(2, e5)
+
+	tt := td.scoreMove(pos, 2, qd4, qd4, core.NoMove)
+	good := td.scoreMove(pos, 2, exd5, qd4, core.NoMove)
+	losing := td.scoreMove(pos, 2, qxd2, qd4, core.NoMove)
+	killer := td.scoreMove(pos, 2, e5, qd4, core.NoMove)
+	quiet := td.scoreMove(pos, 2, core.NewMove(core.NewSquare(0, 4), core.NewSquare(0, 5)), qd4, core.NoMove)
+
+	if !(tt > good && good > killer && killer > quiet) {
+		t.Errorf("bad ordering: tt=%d good=%d killer=%d quiet=%d", tt, good, killer, quiet)
+	}
+	// QxP risks more than it wins, so it goes after the quiet moves
+	if losing >= quiet {
+		t.Errorf("QxP should be ordered after quiets: %d vs %d", losing, quiet)
+	}
+}
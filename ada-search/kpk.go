@@ -0,0 +1,162 @@
+package search
+
+import (
+	"sync"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+)
+
+// KPK bitbase: whether king and pawn against king is a win, for every
+// placement with white holding the pawn. The pawn is mirrored onto files a-d,
+// so there are 2 sides to move × 24 pawn squares × 64 × 64 king squares.
+// It is built by retrograde analysis the first time it is needed.
+
+const kpkSize = 2 * 24 * 64 * 64
+
+// kpkIndex numbers a position: white to move (stm 0) or black (1), the
+// black king, the white king and the pawn on files a-d, ranks 2-7.
+func kpkIndex(stm, bk, wk, psq int) int {
+	pawn := (psq/8-1)*4 + psq%8
+	return stm + 2*(bk+64*(wk+64*pawn))
+}
+
+var (
+	kpkOnce sync.Once
+	kpkWins [kpkSize / 64]uint64
+)
+
+// results of a position while the bitbase is built; a node's result is the
+// union of its children's
+const (
+	kpkInvalid = 0
+	kpkUnknown = 1
+	kpkDraw    = 2
+	kpkWin     = 4
+)
+
+// kpkProbe reports whether white wins with its king on wk and pawn on psq
+// against the black king on bk, with stm to move. Any pawn file is fine.
+func kpkProbe(stm core.Color, wk, psq, bk int) bool {
+	kpkOnce.Do(buildKPK)
+	if psq%8 > 3 {
+		wk, psq, bk = wk^7, psq^7, bk^7
+	}
+	i := kpkIndex(colorIndex(stm), bk, wk, psq)
+	return kpkWins[i/64]&(1<<(i%64)) != 0
+}
+
+func buildKPK() {
+	db := make([]uint8, kpkSize)
+	for pawn := 0; pawn < 24; pawn++ {
+		psq := (pawn/4+1)*8 + pawn%4
+		for wk := 0; wk < 64; wk++ {
+			for bk := 0; bk < 64; bk++ {
+				for stm := 0; stm < 2; stm++ {
+					db[kpkIndex(stm, bk, wk, psq)] = kpkInitial(stm, bk, wk, psq)
+				}
+			}
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for pawn := 0; pawn < 24; pawn++ {
+			psq := (pawn/4+1)*8 + pawn%4
+			for wk := 0; wk < 64; wk++ {
+				for bk := 0; bk < 64; bk++ {
+					for stm := 0; stm < 2; stm++ {
+						i := kpkIndex(stm, bk, wk, psq)
+						if db[i] != kpkUnknown {
+							continue
+						}
+						if r := kpkClassify(db, stm, bk, wk, psq); r != kpkUnknown {
+							db[i] = r
+							changed = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for i, r := range db {
+		if r == kpkWin {
+			kpkWins[i/64] |= 1 << (i % 64)
+		}
+	}
+}
+
+func kingAttacks(sq int) core.Bitboard {
+	return movegen.KingMoves(core.Square(sq))
+}
+
+func whitePawnAttacks(sq int) core.Bitboard {
+	return movegen.PawnAttacks(core.Square(sq), core.White)
+}
+
+// kpkInitial settles the positions whose result doesn't depend on others.
+func kpkInitial(stm, bk, wk, psq int) uint8 {
+	switch {
+	case wk == bk || wk == psq || bk == psq,
+		kingAttacks(wk).Check(core.Square(bk)),
+		stm == 0 && whitePawnAttacks(psq).Check(core.Square(bk)):
+		// kings touching or black in check with white to move
+		return kpkInvalid
+	}
+
+	if stm == 0 && psq/8 == 6 {
+		// the pawn promotes safely if the queen can't simply be taken
+		queen := psq + 8
+		if queen != wk && queen != bk &&
+			(!kingAttacks(bk).Check(core.Square(queen)) || kingAttacks(wk).Check(core.Square(queen))) {
+			return kpkWin
+		}
+	}
+
+	if stm == 1 {
+		// stalemate, or the black king takes an undefended pawn
+		free := kingAttacks(bk).Subtract(kingAttacks(wk) | whitePawnAttacks(psq))
+		if free.Empty() {
+			return kpkDraw
+		}
+		if free.Check(core.Square(psq)) {
+			return kpkDraw
+		}
+	}
+	return kpkUnknown
+}
+
+// kpkClassify settles a position from its children, if they allow it.
+func kpkClassify(db []uint8, stm, bk, wk, psq int) uint8 {
+	var r uint8
+	if stm == 0 {
+		for sq := range kingAttacks(wk).Squares() {
+			r |= db[kpkIndex(1, bk, int(sq), psq)]
+		}
+		if push := psq + 8; psq/8 < 6 && push != wk && push != bk {
+			r |= db[kpkIndex(1, bk, wk, push)]
+			if double := push + 8; psq/8 == 1 && double != wk && double != bk {
+				r |= db[kpkIndex(1, bk, wk, double)]
+			}
+		}
+		switch {
+		case r&kpkWin != 0:
+			return kpkWin
+		case r&kpkUnknown != 0:
+			return kpkUnknown
+		}
+		return kpkDraw
+	}
+
+	for sq := range kingAttacks(bk).Squares() {
+		r |= db[kpkIndex(0, int(sq), wk, psq)]
+	}
+	switch {
+	case r&kpkDraw != 0:
+		return kpkDraw
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	}
+	return kpkWin
+}
@@ -0,0 +1,304 @@
+package search
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// An efficiently updatable neural network (NNUE) evaluation.
+//
+// The network is HalfKA: from each side's point of view every piece on the
+// board, kings included, is one input feature indexed by that side's king
+// square, the piece (ours or theirs) and its square, with the board flipped
+// for black so both sides see themselves at the bottom. That gives
+// 64 * 12 * 64 = 49152 inputs, of which about 32 are active at once.
+//
+// The first layer is the sum of the weight columns of the active features
+// plus a bias: the accumulator. A move only changes a few features, so the
+// search updates each node's accumulator from its parent's by adding and
+// subtracting those columns, and only rebuilds a side's half from scratch
+// when its own king moves. Both halves go through a clipped ReLU, the side
+// to move's first, into a single output:
+//
+//	eval = (outBias + Σ crelu(us[i])·w[i] + Σ crelu(them[i])·w[H+i]) · 400 / (255 · 64)
+//
+// in centipawns for the side to move, where crelu clamps to [0, 255].
+//
+// Weights file format, all integers little endian:
+//
+//	magic       8 bytes  "ADANNUE1"
+//	hidden      uint32   accumulator size H, 1 to 4096
+//	ftWeights   int16    49152 × H, feature major: feature f's column is
+//	                     entries f·H through f·H+H-1
+//	ftBias      int16    H
+//	outWeights  int16    2H, side to move's half first
+//	outBias     int32
+//
+// A feature's index is (king·12 + piece)·64 + square, where king and square
+// are flipped vertically (sq ^ 56) for black's view and piece is 0-5 for our
+// pawn through king and 6-11 for theirs.
+
+const (
+	nnInputs    = 64 * 12 * 64
+	nnMaxHidden = 4096
+
+	nnQA    = 255 // accumulator values are clamped to [0, nnQA]
+	nnQB    = 64  // output weights are scaled by nnQB
+	nnScale = 400 // network output to centipawns
+)
+
+var nnMagic = [8]byte{'A', 'D', 'A', 'N', 'N', 'U', 'E', '1'}
+
+// Network holds the weights of an NNUE evaluation. It is read only once
+// loaded and can be shared by every search thread.
+type Network struct {
+	hidden     int
+	ftWeights  []int16
+	ftBias     []int16
+	outWeights []int16
+	outBias    int32
+}
+
+func newNetwork(hidden int) *Network {
+	return &Network{
+		hidden:     hidden,
+		ftWeights:  make([]int16, nnInputs*hidden),
+		ftBias:     make([]int16, hidden),
+		outWeights: make([]int16, 2*hidden),
+	}
+}
+
+// LoadNetwork reads a weights file.
+func LoadNetwork(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	n, err := ReadNetwork(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return n, nil
+}
+
+// ReadNetwork reads weights in the format described above.
+func ReadNetwork(r io.Reader) (*Network, error) {
+	var magic [8]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return nil, err
+	}
+	if magic != nnMagic {
+		return nil, errors.New("not an AdaEngine network file")
+	}
+
+	var hidden uint32
+	if err := binary.Read(r, binary.LittleEndian, &hidden); err != nil {
+		return nil, err
+	}
+	if hidden == 0 || hidden > nnMaxHidden {
+		return nil, fmt.Errorf("bad accumulator size %d", hidden)
+	}
+
+	n := newNetwork(int(hidden))
+	for _, data := range []any{n.ftWeights, n.ftBias, n.outWeights, &n.outBias} {
+		if err := binary.Read(r, binary.LittleEndian, data); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("truncated network: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// Write writes the network in the format ReadNetwork reads.
+func (n *Network) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(nnMagic[:])
+	for _, data := range []any{uint32(n.hidden), n.ftWeights, n.ftBias, n.outWeights, n.outBias} {
+		if err := binary.Write(bw, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Hidden returns the accumulator size.
+func (n *Network) Hidden() int {
+	return n.hidden
+}
+
+// nnFeature returns the input index of piece on sq seen by persp, whose king
+// is on king.
+func nnFeature(persp core.Color, king int, piece core.Piece, sq int) int {
+	pt := int(piece.Type()) - 1
+	if piece.Color() != persp {
+		pt += 6
+	}
+	if persp == core.Black {
+		king ^= 56
+		sq ^= 56
+	}
+	return (king*12+pt)*64 + sq
+}
+
+// nnPieces lists every colored piece, in the order features are visited.
+var nnPieces = [12]core.Piece{
+	core.NewPiece(core.Pawn, core.White), core.NewPiece(core.Knight, core.White),
+	core.NewPiece(core.Bishop, core.White), core.NewPiece(core.Rook, core.White),
+	core.NewPiece(core.Queen, core.White), core.NewPiece(core.King, core.White),
+	core.NewPiece(core.Pawn, core.Black), core.NewPiece(core.Knight, core.Black),
+	core.NewPiece(core.Bishop, core.Black), core.NewPiece(core.Rook, core.Black),
+	core.NewPiece(core.Queen, core.Black), core.NewPiece(core.King, core.Black),
+}
+
+// accumulator is the first layer's output for both points of view, indexed
+// by colorIndex.
+type accumulator [2][]int16
+
+func (n *Network) newAccumulator() accumulator {
+	return accumulator{make([]int16, n.hidden), make([]int16, n.hidden)}
+}
+
+func (n *Network) addFeature(acc []int16, f int) {
+	col := n.ftWeights[f*n.hidden : (f+1)*n.hidden]
+	for i, w := range col {
+		acc[i] += w
+	}
+}
+
+func (n *Network) subFeature(acc []int16, f int) {
+	col := n.ftWeights[f*n.hidden : (f+1)*n.hidden]
+	for i, w := range col {
+		acc[i] -= w
+	}
+}
+
+// refresh rebuilds persp's half of acc from the position.
+func (n *Network) refresh(acc []int16, pos *position.Position, persp core.Color) {
+	copy(acc, n.ftBias)
+	king := findKingSq(pos, persp)
+	for _, pc := range nnPieces {
+		for sq := range pos.Board.Pieces(pc).Squares() {
+			n.addFeature(acc, nnFeature(persp, king, pc, int(sq)))
+		}
+	}
+}
+
+// update sets persp's half of acc to parentAcc, computed for parent, changed
+// by whatever pieces differ between parent and child. When persp's king has
+// moved every feature changes and the half is rebuilt instead.
+func (n *Network) update(acc, parentAcc []int16, parent, child *position.Position, persp core.Color) {
+	ourKing := core.NewPiece(core.King, persp)
+	if parent.Board.Pieces(ourKing) != child.Board.Pieces(ourKing) {
+		n.refresh(acc, child, persp)
+		return
+	}
+
+	copy(acc, parentAcc)
+	king := findKingSq(child, persp)
+	for _, pc := range nnPieces {
+		before, after := parent.Board.Pieces(pc), child.Board.Pieces(pc)
+		if before == after {
+			continue
+		}
+		for sq := range before.Subtract(after).Squares() {
+			n.subFeature(acc, nnFeature(persp, king, pc, int(sq)))
+		}
+		for sq := range after.Subtract(before).Squares() {
+			n.addFeature(acc, nnFeature(persp, king, pc, int(sq)))
+		}
+	}
+}
+
+// output runs the layers after the accumulator for the side to move.
+func (n *Network) output(acc *accumulator, stm core.Color) int {
+	us, them := acc[colorIndex(stm)], acc[1-colorIndex(stm)]
+	sum := int(n.outBias)
+	for i := 0; i < n.hidden; i++ {
+		sum += crelu(us[i]) * int(n.outWeights[i])
+		sum += crelu(them[i]) * int(n.outWeights[n.hidden+i])
+	}
+	return sum * nnScale / (nnQA * nnQB)
+}
+
+func crelu(x int16) int {
+	return min(max(int(x), 0), nnQA)
+}
+
+// Evaluate scores a position from scratch, in centipawns for the side to
+// move.
+func (n *Network) Evaluate(pos *position.Position) int {
+	acc := n.newAccumulator()
+	n.refresh(acc[0], pos, core.White)
+	n.refresh(acc[1], pos, core.Black)
+	return n.output(&acc, pos.ActiveColor)
+}
+
+// nnSlot is the accumulator of the node at one ply of the current line.
+type nnSlot struct {
+	pos      *position.Position
+	computed bool
+	acc      accumulator
+}
+
+// nnStack holds one accumulator per ply for a search thread. Entering a
+// node records its position; its accumulator is only computed when the node
+// is evaluated, from its parent's, which is computed first if needed. Since
+// copy-make leaves nothing to undo, leaving a node needs no work: the next
+// node entered at that ply simply replaces it.
+type nnStack struct {
+	net   *Network
+	slots [maxPly + 1]nnSlot
+}
+
+func newNNStack(net *Network) *nnStack {
+	s := &nnStack{net: net}
+	for i := range s.slots {
+		s.slots[i].acc = net.newAccumulator()
+	}
+	return s
+}
+
+// enter records pos as the node at ply.
+func (s *nnStack) enter(ply int, pos *position.Position) {
+	slot := &s.slots[ply]
+	if slot.pos != pos {
+		slot.pos = pos
+		slot.computed = false
+	}
+}
+
+func (s *nnStack) compute(ply int) {
+	slot := &s.slots[ply]
+	if slot.computed {
+		return
+	}
+
+	if ply == 0 || s.slots[ply-1].pos == nil {
+		s.net.refresh(slot.acc[0], slot.pos, core.White)
+		s.net.refresh(slot.acc[1], slot.pos, core.Black)
+	} else {
+		s.compute(ply - 1)
+		parent := &s.slots[ply-1]
+		s.net.update(slot.acc[0], parent.acc[0], parent.pos, slot.pos, core.White)
+		s.net.update(slot.acc[1], parent.acc[1], parent.pos, slot.pos, core.Black)
+	}
+	slot.computed = true
+}
+
+// evaluate scores pos, the node at ply, for the side to move.
+func (s *nnStack) evaluate(ply int, pos *position.Position) int {
+	s.enter(ply, pos)
+	s.compute(ply)
+	return s.net.output(&s.slots[ply].acc, pos.ActiveColor)
+}
@@ -0,0 +1,189 @@
+package search
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// randomNetwork builds a small network with weights large enough that the
+// clipped ReLU matters.
+func randomNetwork(hidden int, seed int64) *Network {
+	rng := rand.New(rand.NewSource(seed))
+	n := newNetwork(hidden)
+	for i := range n.ftWeights {
+		n.ftWeights[i] = int16(rng.Intn(129) - 64)
+	}
+	for i := range n.ftBias {
+		n.ftBias[i] = int16(rng.Intn(256))
+	}
+	for i := range n.outWeights {
+		n.outWeights[i] = int16(rng.Intn(129) - 64)
+	}
+	n.outBias = int32(rng.Intn(2001) - 1000)
+	return n
+}
+
+func TestNNFeatureMirror(t *testing.T) {
+	// a white pawn on e2 seen by white with its king on e1 is the same
+	// feature as a black pawn on e7 seen by black with its king on e8
+	w := nnFeature(core.White, 4, core.NewPiece(core.Pawn, core.White), 12)
+	b := nnFeature(core.Black, 60, core.NewPiece(core.Pawn, core.Black), 52)
+	if w != b {
+		t.Errorf("features %d and %d should match", w, b)
+	}
+	if f := nnFeature(core.Black, 63, core.NewPiece(core.King, core.White), 0); f < 0 || f >= nnInputs {
+		t.Errorf("feature %d out of range", f)
+	}
+}
+
+func TestNetworkRoundTrip(t *testing.T) {
+	net := randomNetwork(8, 1)
+	var buf bytes.Buffer
+	if err := net.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := 8 + 4 + 2*(nnInputs*8+8+16) + 4; buf.Len() != want {
+		t.Errorf("file is %d bytes, want %d", buf.Len(), want)
+	}
+
+	got, err := ReadNetwork(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.hidden != net.hidden || got.outBias != net.outBias ||
+		!slices.Equal(got.ftWeights, net.ftWeights) || !slices.Equal(got.ftBias, net.ftBias) ||
+		!slices.Equal(got.outWeights, net.outWeights) {
+		t.Error("network changed in the round trip")
+	}
+
+	if _, err := ReadNetwork(bytes.NewReader(buf.Bytes()[:buf.Len()-3])); err == nil {
+		t.Error("expected an error for a truncated file")
+	}
+	bad := slices.Clone(buf.Bytes())
+	bad[0] = 'X'
+	if _, err := ReadNetwork(bytes.NewReader(bad)); err == nil {
+		t.Error("expected an error for a bad magic number")
+	}
+}
+
+// checkAccumulator compares an incrementally built slot with one rebuilt from
+// scratch.
+func checkAccumulator(t *testing.T, net *Network, slot *nnSlot, line []core.Move) {
+	t.Helper()
+	want := net.newAccumulator()
+	net.refresh(want[0], slot.pos, core.White)
+	net.refresh(want[1], slot.pos, core.Black)
+	for c := range 2 {
+		if !slices.Equal(slot.acc[c], want[c]) {
+			t.Fatalf("after %v: perspective %d accumulator differs from a refresh", line, c)
+		}
+	}
+}
+
+func TestIncrementalAccumulatorMatchesRefresh(t *testing.T) {
+	net := randomNetwork(16, 2)
+	rng := rand.New(rand.NewSource(3))
+
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		// kiwipete: castling, en passant and promotions come up quickly
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"8/2P5/8/8/8/8/5p2/K6k w - - 0 1",
+		"rnbqkbnr/ppp1p1pp/8/3pPp2/8/8/PPPP1PPP/RNBQKBNR w KQkq f6 0 3",
+	}
+
+	for _, f := range fens {
+		root, err := fen.Parse(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for game := 0; game < 20; game++ {
+			s := newNNStack(net)
+			s.enter(0, root)
+			pos := root
+			var line []core.Move
+			for ply := 1; ply <= 40; ply++ {
+				moves := movegen.LegalMoves(pos)
+				if moves.Count() == 0 {
+					break
+				}
+				if rng.Intn(10) == 0 && !movegen.InCheck(pos) {
+					// a null move changes nothing on the board
+					pos = position.MakeNullMove(pos)
+					line = append(line, core.NoMove)
+				} else {
+					m := moves.Get(rng.Intn(moves.Count()))
+					pos = position.MakeMove(pos, m)
+					line = append(line, m)
+				}
+
+				// evaluate only some nodes so updates skip plies
+				s.enter(ply, pos)
+				if rng.Intn(3) == 0 || ply == 40 {
+					if got, want := s.evaluate(ply, pos), net.Evaluate(pos); got != want {
+						t.Fatalf("after %v: incremental eval %d, from scratch %d", line, got, want)
+					}
+					checkAccumulator(t, net, &s.slots[ply], line)
+				}
+			}
+		}
+	}
+}
+
+func TestAccumulatorFromUnrelatedPosition(t *testing.T) {
+	// the update diffs boards, so it is right even when the slot below
+	// holds some other position
+	net := randomNetwork(16, 4)
+	a, _ := fen.Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	b, _ := fen.Parse("r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1")
+
+	s := newNNStack(net)
+	s.enter(0, a)
+	s.evaluate(0, a)
+	s.enter(1, b)
+	if got, want := s.evaluate(1, b), net.Evaluate(b); got != want {
+		t.Errorf("eval %d, want %d", got, want)
+	}
+	checkAccumulator(t, net, &s.slots[1], nil)
+}
+
+func TestSearchWithNetwork(t *testing.T) {
+	pos, _ := fen.Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	net := randomNetwork(16, 5)
+
+	e := NewEngine(1)
+	classical := e.Evaluate(pos)
+	e.SetNetwork(net)
+	if !e.UsesNetwork() || e.Evaluate(pos) != net.Evaluate(pos) {
+		t.Error("engine should evaluate with the network")
+	}
+
+	res := e.Search(pos, 3, 1, 0)
+	if !isLegal(pos, res.Move) {
+		t.Fatalf("search returned %v, not a legal move", res.Move)
+	}
+
+	if err := e.SetParam("NNUE", 0); err != nil {
+		t.Fatal(err)
+	}
+	if e.UsesNetwork() || e.Evaluate(pos) != classical {
+		t.Error("turning NNUE off should restore the handcrafted evaluation")
+	}
+}
+
+func isLegal(pos *position.Position, m core.Move) bool {
+	moves := movegen.LegalMoves(pos)
+	for i := 0; i < moves.Count(); i++ {
+		if moves.Get(i) == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package search
+
+// Options switches individual search features on or off and holds their
+// tuning parameters, so their effect can be measured, e.g. in self-play.
+// The zero value disables everything; use DefaultOptions for normal play.
+// Margins are in centipawns and are multiplied by the remaining depth.
+type Options struct {
+	// PVS searches every move after the first with a null window and only
+	// re-searches with the full window when that fails high.
+	PVS bool
+
+	// CheckExtensions searches checking moves one ply deeper.
+	CheckExtensions bool
+
+	// SingularExtensions searches the TT move one ply deeper when every
+	// other move fails low against a margin below its stored score.
+	SingularExtensions bool
+
+	// QuiescenceChecks also searches quiet checking moves at the first
+	// ply of quiescence.
+	QuiescenceChecks bool
+
+	// NullMove skips a turn and prunes if the opponent still can't reach
+	// beta. The reduction is NullMoveBase + depth/NullMoveDivisor, and in
+	// endgames where the side to move has at most NullVerifyMaterial in
+	// pieces the cutoff is verified by a normal reduced search.
+	NullMove           bool
+	NullMoveBase       int
+	NullMoveDivisor    int
+	NullVerifyMaterial int
+
+	// Futility skips quiet moves within FutilityDepth of the horizon when
+	// the static eval plus the margin can't reach alpha.
+	Futility       bool
+	FutilityDepth  int
+	FutilityMargin int
+
+	// ReverseFutility (static null move) prunes a node when the static
+	// eval beats beta by the margin.
+	ReverseFutility       bool
+	ReverseFutilityDepth  int
+	ReverseFutilityMargin int
+
+	// Razoring drops straight into quiescence when the static eval is so
+	// far below alpha that only a tactic could help.
+	Razoring    bool
+	RazorDepth  int
+	RazorMargin int
+
+	// LateMovePruning stops searching quiet moves once LMPBase + depth²
+	// have been tried.
+	LateMovePruning bool
+	LMPDepth        int
+	LMPBase         int
+
+	// ProbCut prunes when a capture beats beta by ProbCutMargin in a
+	// search ProbCutReduction plies shallower.
+	ProbCut          bool
+	ProbCutDepth     int
+	ProbCutMargin    int
+	ProbCutReduction int
+
+	// IIR (internal iterative reduction) searches a ply shallower when no
+	// TT move is available to order the node.
+	IIR      bool
+	IIRDepth int
+
+	// AspirationWindow is the half-width of the window around the previous
+	// iteration's score, in centipawns.
+	AspirationWindow int
+
+	// QuiesceDelta is the margin for delta pruning in quiescence: a capture
+	// is skipped when even winning the piece plus this can't reach alpha.
+	QuiesceDelta int
+
+	// Late move reductions are LMRBase + ln(depth)·ln(move number) /
+	// LMRDivisor plies, with both values in hundredths.
+	LMRBase    int
+	LMRDivisor int
+
+	// NNUE evaluates with the engine's network instead of the handcrafted
+	// evaluation, when a network is loaded.
+	NNUE bool
+
+	// Tablebases looks positions up in the engine's endgame tablebases,
+	// when any are loaded, at the root and in the tree.
+	Tablebases bool
+
+	// OwnBook plays from the engine's opening book, when one is loaded,
+	// instead of searching. BookRandom picks among the book moves by
+	// weight rather than always taking the heaviest.
+	OwnBook    bool
+	BookRandom bool
+
+	// MultiPV finds the best MultiPV moves at the root with exact scores,
+	// reported in Result.Lines, instead of only the best one.
+	MultiPV int
+
+	// Contempt is how much, in centipawns, the engine counts a draw as a
+	// loss for itself: positive avoids draws, negative seeks them. It
+	// applies to stalemate, repetition and the fifty move rule alike.
+	Contempt int
+
+	// SkillLevel weakens the engine below MaxSkillLevel; 0 is full
+	// strength. With LimitStrength set the level follows Elo instead.
+	SkillLevel    int
+	LimitStrength bool
+	Elo           int
+}
+
+// DefaultOptions returns the options the engine plays with.
+func DefaultOptions() Options {
+	return Options{
+		PVS:                true,
+		CheckExtensions:    true,
+		SingularExtensions: true,
+		QuiescenceChecks:   true,
+
+		NullMove:           true,
+		NullMoveBase:       3,
+		NullMoveDivisor:    6,
+		NullVerifyMaterial: 500,
+
+		Futility:       true,
+		FutilityDepth:  2,
+		FutilityMargin: 150,
+
+		ReverseFutility:       true,
+		ReverseFutilityDepth:  6,
+		ReverseFutilityMargin: 90,
+
+		Razoring:    true,
+		RazorDepth:  3,
+		RazorMargin: 250,
+
+		LateMovePruning: true,
+		LMPDepth:        5,
+		LMPBase:         4,
+
+		ProbCut:          true,
+		ProbCutDepth:     5,
+		ProbCutMargin:    200,
+		ProbCutReduction: 4,
+
+		IIR:      true,
+		IIRDepth: 4,
+
+		AspirationWindow: 50,
+		QuiesceDelta:     200,
+
+		LMRBase:    50,
+		LMRDivisor: 200,
+
+		NNUE:       true,
+		Tablebases: true,
+		OwnBook:    true,
+		BookRandom: true,
+
+		MultiPV:    1,
+		SkillLevel: MaxSkillLevel,
+		Elo:        MaxElo,
+	}
+}
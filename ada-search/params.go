@@ -0,0 +1,407 @@
+package search
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Param describes one value that can be set by name: a search option from
+// Options or an evaluation weight. Switches have Check set and take 0 or 1.
+type Param struct {
+	Name     string
+	Value    int
+	Default  int
+	Min, Max int
+	Check    bool
+}
+
+// paramRef points at the storage behind a Param.
+type paramRef struct {
+	name     string
+	val      *int
+	flag     *bool
+	min, max int
+}
+
+func (r paramRef) get() int {
+	if r.flag != nil {
+		if *r.flag {
+			return 1
+		}
+		return 0
+	}
+	return *r.val
+}
+
+func (r paramRef) set(v int) {
+	if r.flag != nil {
+		*r.flag = v != 0
+		return
+	}
+	*r.val = v
+}
+
+type paramList []paramRef
+
+// bounds for evaluation weights
+const (
+	minWeight = -2000
+	maxWeight = 2000
+)
+
+var pieceNames = [7]string{"", "Pawn", "Knight", "Bishop", "Rook", "Queen", "King"}
+
+func (l *paramList) int(name string, v *int, min, max int) {
+	*l = append(*l, paramRef{name: name, val: v, min: min, max: max})
+}
+
+func (l *paramList) flag(name string, v *bool) {
+	*l = append(*l, paramRef{name: name, flag: v, min: 0, max: 1})
+}
+
+func (l *paramList) score(name string, s *score) {
+	l.int(name+".MG", &s.mg, minWeight, maxWeight)
+	l.int(name+".EG", &s.eg, minWeight, maxWeight)
+}
+
+// pieces adds the entries of a table indexed by piece type, first through last.
+func (l *paramList) pieces(name string, t *[7]score, first, last int) {
+	for pt := first; pt <= last; pt++ {
+		l.score(name+"."+pieceNames[pt], &t[pt])
+	}
+}
+
+// ranks adds the entries of a table indexed by relative rank, numbered 1-8.
+func (l *paramList) ranks(name string, t *[8]score) {
+	for r := range t {
+		l.score(name+"."+strconv.Itoa(r+1), &t[r])
+	}
+}
+
+// squares adds a table indexed by square, named like KingMG.e1.
+func (l *paramList) squares(name string, t *[64]int) {
+	for sq := range t {
+		l.int(fmt.Sprintf("%s.%c%d", name, 'a'+sq%8, sq/8+1), &t[sq], minWeight, maxWeight)
+	}
+}
+
+// paramRefs lists every named parameter backed by opts and p, in a fixed
+// order, so the same index refers to the same parameter for any pair.
+func paramRefs(opts *Options, p *evalParams) paramList {
+	return append(optionRefs(opts), evalRefs(p)...)
+}
+
+// optionRefs lists the search options that can be set by name.
+func optionRefs(opts *Options) paramList {
+	var l paramList
+	l.flag("PVS", &opts.PVS)
+	l.flag("CheckExtensions", &opts.CheckExtensions)
+	l.flag("SingularExtensions", &opts.SingularExtensions)
+	l.flag("QuiescenceChecks", &opts.QuiescenceChecks)
+	l.flag("NullMove", &opts.NullMove)
+	l.int("NullMoveBase", &opts.NullMoveBase, 0, 10)
+	l.int("NullMoveDivisor", &opts.NullMoveDivisor, 1, 20)
+	l.int("NullVerifyMaterial", &opts.NullVerifyMaterial, 0, 5000)
+	l.flag("Futility", &opts.Futility)
+	l.int("FutilityDepth", &opts.FutilityDepth, 0, 10)
+	l.int("FutilityMargin", &opts.FutilityMargin, 0, 1000)
+	l.flag("ReverseFutility", &opts.ReverseFutility)
+	l.int("ReverseFutilityDepth", &opts.ReverseFutilityDepth, 0, 20)
+	l.int("ReverseFutilityMargin", &opts.ReverseFutilityMargin, 0, 1000)
+	l.flag("Razoring", &opts.Razoring)
+	l.int("RazorDepth", &opts.RazorDepth, 0, 10)
+	l.int("RazorMargin", &opts.RazorMargin, 0, 2000)
+	l.flag("LateMovePruning", &opts.LateMovePruning)
+	l.int("LMPDepth", &opts.LMPDepth, 0, 20)
+	l.int("LMPBase", &opts.LMPBase, 0, 100)
+	l.flag("ProbCut", &opts.ProbCut)
+	l.int("ProbCutDepth", &opts.ProbCutDepth, 1, 20)
+	l.int("ProbCutMargin", &opts.ProbCutMargin, 0, 2000)
+	l.int("ProbCutReduction", &opts.ProbCutReduction, 1, 10)
+	l.flag("IIR", &opts.IIR)
+	l.int("IIRDepth", &opts.IIRDepth, 1, 20)
+	l.int("AspirationWindow", &opts.AspirationWindow, 1, 1000)
+	l.int("QuiesceDelta", &opts.QuiesceDelta, 0, 2000)
+	l.int("LMRBase", &opts.LMRBase, -500, 500)
+	l.int("LMRDivisor", &opts.LMRDivisor, 1, 1000)
+	l.flag("NNUE", &opts.NNUE)
+	l.flag("Tablebases", &opts.Tablebases)
+	l.flag("OwnBook", &opts.OwnBook)
+	l.flag("BookRandom", &opts.BookRandom)
+	l.int("Contempt", &opts.Contempt, -1000, 1000)
+	l.int("MultiPV", &opts.MultiPV, 1, 256)
+	l.int("SkillLevel", &opts.SkillLevel, 1, MaxSkillLevel)
+	l.flag("UCI_LimitStrength", &opts.LimitStrength)
+	l.int("UCI_Elo", &opts.Elo, MinElo, MaxElo)
+	return l
+}
+
+// evalRefs lists the evaluation weights that can be set by name.
+func evalRefs(p *evalParams) paramList {
+	var l paramList
+	for pt := 1; pt <= 5; pt++ {
+		l.int("PieceValue."+pieceNames[pt], &p.pieceValue[pt], 0, 5000)
+	}
+	l.pieces("Material", &p.pieceScore, 1, 5)
+	l.pieces("Advance", &p.advanceBonus, 2, 5)
+	l.ranks("PawnAdvance", &p.pawnAdvance)
+	l.pieces("Center", &p.centerBonus, 1, 5)
+	l.squares("KingMG", &p.kingMG)
+	l.squares("KingEG", &p.kingEG)
+	l.score("KingZone", &p.kingZoneWeight)
+	l.score("KingRegion", &p.kingRegionWeight)
+	l.score("PawnControl", &p.pawnControlWeight)
+	l.score("SafeControl", &p.safeControlWeight)
+	l.score("OutpostPenalty", &p.outpostPenalty)
+	l.score("Doubled", &p.doubledPenalty)
+	l.score("Isolated", &p.isolatedPenalty)
+	l.score("Backward", &p.backwardPenalty)
+	l.ranks("Connected", &p.connectedBonus)
+	l.ranks("Passed", &p.passedBonus)
+	l.ranks("PassedFree", &p.passedFreeBonus)
+	l.int("PassedEnemyKing", &p.passedEnemyKingWeight, 0, 100)
+	l.int("PassedOwnKing", &p.passedOwnKingWeight, 0, 100)
+	l.pieces("Mobility", &p.mobility, 2, 5)
+	for pt := 2; pt <= 5; pt++ {
+		l.int("MobilityBase."+pieceNames[pt], &p.mobilityBase[pt], 0, 27)
+	}
+	l.pieces("KingAttack", &p.kingAttack, 2, 5)
+	l.score("ShieldNear", &p.shieldNear)
+	l.score("ShieldFar", &p.shieldFar)
+	l.score("KingOpenFile", &p.kingOpenFile)
+	l.score("KingSemiOpenFile", &p.kingSemiOpenFile)
+	l.score("BishopPair", &p.bishopPair)
+	l.score("RookOpenFile", &p.rookOpenFile)
+	l.score("RookSemiOpenFile", &p.rookSemiOpenFile)
+	l.score("RookSeventh", &p.rookSeventh)
+	l.score("KnightOutpost", &p.knightOutpost)
+	l.score("TrappedBishop", &p.trappedBishop)
+	l.score("TrappedRook", &p.trappedRook)
+
+	return l
+}
+
+// find looks a parameter up by name, ignoring case as UCI does.
+func (l paramList) find(name string) (int, bool) {
+	for i, r := range l {
+		if strings.EqualFold(r.name, name) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// Params lists every parameter with its current and default value.
+func (e *Engine) Params() []Param {
+	opts, eval := DefaultOptions(), defaultEvalParams()
+	defaults := paramRefs(&opts, &eval)
+
+	refs := paramRefs(&e.Options, &e.eval)
+	out := make([]Param, len(refs))
+	for i, r := range refs {
+		out[i] = Param{
+			Name:    r.name,
+			Value:   r.get(),
+			Default: defaults[i].get(),
+			Min:     r.min,
+			Max:     r.max,
+			Check:   r.flag != nil,
+		}
+	}
+	return out
+}
+
+// Param returns the current value of a parameter.
+func (e *Engine) Param(name string) (int, error) {
+	refs := paramRefs(&e.Options, &e.eval)
+	i, ok := refs.find(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown parameter %q", name)
+	}
+	return refs[i].get(), nil
+}
+
+// SetParam sets a parameter by name. It takes effect from the next search
+// and must not be called while one is running.
+func (e *Engine) SetParam(name string, value int) error {
+	refs := paramRefs(&e.Options, &e.eval)
+	i, ok := refs.find(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+	if r := refs[i]; value < r.min || value > r.max {
+		return fmt.Errorf("%s: %d out of range [%d, %d]", r.name, value, r.min, r.max)
+	}
+	refs[i].set(value)
+	return nil
+}
+
+// ResetParams restores every parameter to its default.
+func (e *Engine) ResetParams() {
+	e.Options = DefaultOptions()
+	e.eval = defaultEvalParams()
+}
+
+// LoadParams reads parameters from a JSON or TOML file, chosen by the file
+// extension, and sets each one. Parameters not in the file keep their
+// values. Nothing is changed if any entry is invalid.
+func (e *Engine) LoadParams(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var values map[string]int
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		values, err = readParamsJSON(f)
+	case ".toml":
+		values, err = readParamsTOML(f)
+	default:
+		return fmt.Errorf("%s: unknown parameter file type, want .json or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return e.setParams(values)
+}
+
+// setParams sets every value or none of them.
+func (e *Engine) setParams(values map[string]int) error {
+	savedOpts, savedEval := e.Options, e.eval
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := e.SetParam(name, values[name]); err != nil {
+			e.Options, e.eval = savedOpts, savedEval
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteParams writes every parameter that differs from its default as TOML,
+// in a form LoadParams reads back.
+func (e *Engine) WriteParams(w io.Writer) error {
+	for _, p := range e.Params() {
+		if p.Value == p.Default {
+			continue
+		}
+		v := strconv.Itoa(p.Value)
+		if p.Check {
+			v = strconv.FormatBool(p.Value != 0)
+		}
+		if _, err := fmt.Fprintf(w, "%s = %s\n", p.Name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readParamsJSON reads a flat object of names to numbers or booleans.
+func readParamsJSON(r io.Reader) (map[string]int, error) {
+	var raw map[string]any
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int, len(raw))
+	for name, v := range raw {
+		switch v := v.(type) {
+		case float64:
+			if v != float64(int(v)) {
+				return nil, fmt.Errorf("%s: %v is not an integer", name, v)
+			}
+			values[name] = int(v)
+		case bool:
+			values[name] = boolParam(v)
+		default:
+			return nil, fmt.Errorf("%s: want a number or boolean", name)
+		}
+	}
+	return values, nil
+}
+
+// readParamsTOML reads the subset of TOML parameter files need: comments,
+// [tables] and key = value pairs with integer or boolean values. Tables and
+// dotted keys join into dotted names, so
+//
+//	[Material.Knight]
+//	MG = 330
+//
+// sets Material.Knight.MG.
+func readParamsTOML(r io.Reader) (map[string]int, error) {
+	values := make(map[string]int)
+	table := ""
+
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return nil, fmt.Errorf("line %d: bad table header", line)
+			}
+			table = tomlKey(text[1 : len(text)-1])
+			continue
+		}
+
+		key, val, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: want key = value", line)
+		}
+		name := tomlKey(key)
+		if table != "" {
+			name = table + "." + name
+		}
+
+		val = strings.TrimSpace(val)
+		switch val {
+		case "true", "false":
+			values[name] = boolParam(val == "true")
+		default:
+			n, err := strconv.Atoi(strings.ReplaceAll(val, "_", ""))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: want an integer or boolean", line, name)
+			}
+			values[name] = n
+		}
+	}
+	return values, sc.Err()
+}
+
+// tomlKey normalizes a possibly dotted and quoted key to a dotted name.
+func tomlKey(key string) string {
+	parts := strings.Split(strings.TrimSpace(key), ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+func boolParam(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+const bishopDown = "rn1qkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 1"
+
+func TestParamsDefaults(t *testing.T) {
+	e := NewEngine(1)
+	seen := make(map[string]bool)
+	for _, p := range e.Params() {
+		if seen[p.Name] {
+			t.Errorf("duplicate parameter %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.Value != p.Default {
+			t.Errorf("%s = %d, default %d", p.Name, p.Value, p.Default)
+		}
+		if p.Default < p.Min || p.Default > p.Max {
+			t.Errorf("%s default %d outside [%d, %d]", p.Name, p.Default, p.Min, p.Max)
+		}
+	}
+
+	for _, name := range []string{"AspirationWindow", "QuiesceDelta", "FutilityMargin", "LMRBase", "Material.Pawn.MG", "KingZone.MG", "KingMG.g1"} {
+		if !seen[name] {
+			t.Errorf("missing parameter %s", name)
+		}
+	}
+	if v, _ := e.Param("QuiesceDelta"); v != 200 {
+		t.Errorf("QuiesceDelta = %d, want 200", v)
+	}
+	if v, _ := e.Param("AspirationWindow"); v != 50 {
+		t.Errorf("AspirationWindow = %d, want 50", v)
+	}
+}
+
+func TestSetParam(t *testing.T) {
+	e := NewEngine(1)
+
+	if err := e.SetParam("futilitymargin", 300); err != nil {
+		t.Fatal(err)
+	}
+	if e.Options.FutilityMargin != 300 {
+		t.Errorf("FutilityMargin = %d, want 300", e.Options.FutilityMargin)
+	}
+
+	if err := e.SetParam("PVS", 0); err != nil || e.Options.PVS {
+		t.Errorf("PVS not switched off: %v", err)
+	}
+
+	if err := e.SetParam("NoSuchParam", 1); err == nil {
+		t.Error("unknown name accepted")
+	}
+	if err := e.SetParam("LMRDivisor", 0); err == nil {
+		t.Error("out of range value accepted")
+	}
+
+	// evaluation weights are per engine; black is down a bishop
+	pos, _ := fen.Parse(bishopDown)
+	if err := e.SetParam("Material.Queen.MG", 1500); err != nil {
+		t.Fatal(err)
+	}
+	if e.Evaluate(pos) != Evaluate(pos) {
+		t.Error("queens are balanced, their weight shouldn't change the eval")
+	}
+	if err := e.SetParam("BishopPair.MG", 500); err != nil {
+		t.Fatal(err)
+	}
+	if e.Evaluate(pos) == Evaluate(pos) {
+		t.Error("bishop pair weight had no effect")
+	}
+	if NewEngine(1).Evaluate(pos) != Evaluate(pos) {
+		t.Error("setting a weight leaked into another engine")
+	}
+
+	e.ResetParams()
+	if e.Evaluate(pos) != Evaluate(pos) || e.Options != DefaultOptions() {
+		t.Error("ResetParams didn't restore defaults")
+	}
+}
+
+func TestSearchUsesEngineParams(t *testing.T) {
+	pos, _ := fen.Parse(bishopDown)
+
+	e := NewEngine(1)
+	if err := e.SetParam("BishopPair.MG", 500); err != nil {
+		t.Fatal(err)
+	}
+	tuned := e.Search(pos, 1, 1, 0)
+	plain := NewEngine(1).Search(pos, 1, 1, 0)
+	if tuned.Score == plain.Score {
+		t.Errorf("search ignored engine weights: both scored %d", tuned.Score)
+	}
+}
+
+func TestLoadParams(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	e := NewEngine(1)
+	path := write("p.json", `{"FutilityMargin": 175, "NullMove": false, "Material.Knight.EG": 310}`)
+	if err := e.LoadParams(path); err != nil {
+		t.Fatal(err)
+	}
+	if e.Options.FutilityMargin != 175 || e.Options.NullMove {
+		t.Errorf("options not loaded: %+v", e.Options)
+	}
+	if v, _ := e.Param("Material.Knight.EG"); v != 310 {
+		t.Errorf("Material.Knight.EG = %d, want 310", v)
+	}
+
+	e = NewEngine(1)
+	path = write("p.toml", `
+# search
+AspirationWindow = 35
+Razoring = false
+
+[Material.Rook]
+MG = 480   # cheaper rooks
+"EG" = 1_000
+`)
+	if err := e.LoadParams(path); err != nil {
+		t.Fatal(err)
+	}
+	if e.Options.AspirationWindow != 35 || e.Options.Razoring {
+		t.Errorf("options not loaded: %+v", e.Options)
+	}
+	if v, _ := e.Param("Material.Rook.MG"); v != 480 {
+		t.Errorf("Material.Rook.MG = %d, want 480", v)
+	}
+	if v, _ := e.Param("Material.Rook.EG"); v != 1000 {
+		t.Errorf("Material.Rook.EG = %d, want 1000", v)
+	}
+
+	// a bad entry leaves everything as it was
+	e = NewEngine(1)
+	path = write("bad.json", `{"FutilityMargin": 175, "Bogus": 1}`)
+	if err := e.LoadParams(path); err == nil {
+		t.Error("unknown name accepted")
+	}
+	if e.Options != DefaultOptions() {
+		t.Error("failed load changed options")
+	}
+
+	if err := e.LoadParams(write("p.yaml", "")); err == nil {
+		t.Error("unknown file type accepted")
+	}
+}
+
+func TestWriteParamsRoundTrip(t *testing.T) {
+	e := NewEngine(1)
+	e.SetParam("IIR", 0)
+	e.SetParam("KingMG.g1", 45)
+	e.SetParam("LMRBase", 75)
+
+	var buf bytes.Buffer
+	if err := e.WriteParams(&buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "out.toml")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewEngine(1)
+	if err := f.LoadParams(path); err != nil {
+		t.Fatal(err)
+	}
+	want, got := e.Params(), f.Params()
+	for i := range want {
+		if want[i].Value != got[i].Value {
+			t.Errorf("%s: wrote %d, read %d", want[i].Name, want[i].Value, got[i].Value)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package search
+
+import (
+	"math/bits"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+const (
+	fileA core.Bitboard = 0x0101010101010101
+	fileH core.Bitboard = fileA << 7
+)
+
+// bitboard fills and shifts, from white's point of view
+
+func northFill(b core.Bitboard) core.Bitboard {
+	b |= b << 8
+	b |= b << 16
+	b |= b << 32
+	return b
+}
+
+func southFill(b core.Bitboard) core.Bitboard {
+	b |= b >> 8
+	b |= b >> 16
+	b |= b >> 32
+	return b
+}
+
+func fileFill(b core.Bitboard) core.Bitboard {
+	return northFill(b) | southFill(b)
+}
+
+func eastOne(b core.Bitboard) core.Bitboard {
+	return (b &^ fileH) << 1
+}
+
+func westOne(b core.Bitboard) core.Bitboard {
+	return (b &^ fileA) >> 1
+}
+
+func sides(b core.Bitboard) core.Bitboard {
+	return eastOne(b) | westOne(b)
+}
+
+// flip mirrors a bitboard vertically, swapping rank 1 with rank 8.
+func flip(b core.Bitboard) core.Bitboard {
+	return core.Bitboard(bits.ReverseBytes64(uint64(b)))
+}
+
+// pawnSets holds the pawns that have each structural feature.
+type pawnSets struct {
+	doubled   core.Bitboard // a friendly pawn behind on the same file
+	isolated  core.Bitboard // no friendly pawns on adjacent files
+	backward  core.Bitboard // can't be supported and the stop square is attacked
+	passed    core.Bitboard // no enemy pawn ahead on this or an adjacent file
+	connected core.Bitboard // supported by a pawn or standing next to one
+}
+
+// classifyPawns finds the structural features of our pawns against theirs
+// with us moving up the board.
+func classifyPawns(us, them core.Bitboard) pawnSets {
+	var p pawnSets
+
+	ourAttacks := sides(us << 8)
+	theirAttacks := sides(them >> 8)
+
+	p.doubled = us & (northFill(us) << 8)
+	p.isolated = us &^ sides(fileFill(us))
+
+	// no friendly pawn beside or behind on an adjacent file to come up in
+	// support, and an enemy pawn covers the stop square
+	p.backward = us &^ sides(northFill(us)) &^ p.isolated & (theirAttacks >> 8)
+
+	// the rear pawn of a doubled pair isn't passed, the front one may be
+	theirFront := southFill(them) >> 8
+	p.passed = us &^ (theirFront | sides(theirFront)) &^ (southFill(us) >> 8)
+
+	p.connected = us & (ourAttacks | sides(us))
+
+	return p
+}
+
+// pawnStructure scores our pawns against theirs with us moving up the board.
+// It returns the structure score and our passed pawns.
+func (p *evalParams) pawnStructure(us, them core.Bitboard) (score, core.Bitboard) {
+	var s score
+
+	sets := classifyPawns(us, them)
+	s = s.add(p.doubledPenalty.scale(sets.doubled.Count()))
+	s = s.add(p.isolatedPenalty.scale(sets.isolated.Count()))
+	s = s.add(p.backwardPenalty.scale(sets.backward.Count()))
+	for sq := range sets.connected.Squares() {
+		s = s.add(p.connectedBonus[sq.Rank()])
+	}
+
+	return s, sets.passed
+}
+
+// pawnEntry caches the part of the evaluation that depends only on pawns.
+type pawnEntry struct {
+	key    uint64
+	score  [2]score         // structure, per color
+	passed [2]core.Bitboard // passed pawns, per color
+}
+
+// pawnTableSize is the number of entries in a pawn hash table. Pawn
+// structures repeat far more than positions, so a small table hits often.
+const pawnTableSize = 1 << 13
+
+// pawnTable is a per-thread cache of pawn structure evaluations indexed by
+// position.PawnKey.
+type pawnTable [pawnTableSize]pawnEntry
+
+func newPawnTable() *pawnTable {
+	return new(pawnTable)
+}
+
+// evaluatePawns computes the pawn entry for a position.
+func (p *evalParams) evaluatePawns(pos *position.Position) pawnEntry {
+	white := pos.Board.Pieces(core.NewPiece(core.Pawn, core.White))
+	black := pos.Board.Pieces(core.NewPiece(core.Pawn, core.Black))
+
+	e := pawnEntry{key: pos.PawnKey}
+	e.score[0], e.passed[0] = p.pawnStructure(white, black)
+	e.score[1], e.passed[1] = p.pawnStructure(flip(black), flip(white))
+	e.passed[1] = flip(e.passed[1])
+	return e
+}
+
+// probe returns the pawn entry for pos, computing and storing it on a miss.
+// A nil table computes the entry every time. A table must only ever be used
+// with one set of weights.
+func (pt *pawnTable) probe(p *evalParams, pos *position.Position) *pawnEntry {
+	if pt == nil {
+		e := p.evaluatePawns(pos)
+		return &e
+	}
+	e := &pt[pos.PawnKey&(pawnTableSize-1)]
+	if e.key != pos.PawnKey {
+		*e = p.evaluatePawns(pos)
+	}
+	return e
+}
+
+// squareDistance is the number of king moves between two squares.
+func squareDistance(a, b int) int {
+	return max(abs(a/8-b/8), abs(a%8-b%8))
+}
+
+// passedPawns scores color's passed pawns against the rest of the board:
+// the pawn's rank, whether its path is clear and how close the kings are.
+func (p *evalParams) passedPawns(pos *position.Position, passed core.Bitboard, color core.Color, ourKing, theirKing int) score {
+	var s score
+
+	occupied := pos.Board.Occupied()
+	for sq := range passed.Squares() {
+		rank := sq.Rank()
+		stop := int(sq) + 8
+		path := northFill(core.NewBitboard().Set(sq)) << 8
+		if color == core.Black {
+			rank = 7 - rank
+			stop = int(sq) - 8
+			path = southFill(core.NewBitboard().Set(sq)) >> 8
+		}
+
+		s = s.add(p.passedBonus[rank])
+		if path&occupied == 0 {
+			s = s.add(p.passedFreeBonus[rank])
+		}
+
+		if w := rank - 2; w > 0 {
+			s.eg += (squareDistance(theirKing, stop)*p.passedEnemyKingWeight -
+				squareDistance(ourKing, stop)*p.passedOwnKingWeight) * w
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// squares builds a bitboard from square names like "e4"
+func squares(names ...string) core.Bitboard {
+	var b core.Bitboard
+	for _, n := range names {
+		b = b.Set(core.NewSquare(int(n[1]-'1'), int(n[0]-'a')))
+	}
+	return b
+}
+
+func TestFills(t *testing.T) {
+	if got, want := northFill(squares("c3")), squares("c3", "c4", "c5", "c6", "c7", "c8"); got != want {
+		t.Errorf("northFill = %x, want %x", uint64(got), uint64(want))
+	}
+	if got, want := southFill(squares("c3")), squares("c3", "c2", "c1"); got != want {
+		t.Errorf("southFill = %x, want %x", uint64(got), uint64(want))
+	}
+	if got, want := sides(squares("a4", "h5")), squares("b4", "g5"); got != want {
+		t.Errorf("sides = %x, want %x", uint64(got), uint64(want))
+	}
+	if got, want := flip(squares("a2", "e7")), squares("a7", "e2"); got != want {
+		t.Errorf("flip = %x, want %x", uint64(got), uint64(want))
+	}
+}
+
+func TestClassifyPawns(t *testing.T) {
+	tests := []struct {
+		name     string
+		us, them core.Bitboard
+		want     pawnSets
+	}{
+		{
+			name: "doubled isolated and connected",
+			us:   squares("a2", "a3", "c2", "d3"),
+			want: pawnSets{
+				doubled:   squares("a3"),
+				isolated:  squares("a2", "a3"),
+				passed:    squares("a3", "c2", "d3"),
+				connected: squares("d3"),
+			},
+		},
+		{
+			name: "backward",
+			us:   squares("c4", "d3"),
+			them: squares("e5"),
+			want: pawnSets{
+				backward:  squares("d3"),
+				passed:    squares("c4"),
+				connected: squares("c4"),
+			},
+		},
+		{
+			name: "phalanx",
+			us:   squares("d4", "e4"),
+			them: squares("d6"),
+			want: pawnSets{
+				connected: squares("d4", "e4"),
+			},
+		},
+		{
+			name: "enemy pawn beside is not in front",
+			us:   squares("e5"),
+			them: squares("d5"),
+			want: pawnSets{
+				isolated: squares("e5"),
+				passed:   squares("e5"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := classifyPawns(tt.us, tt.them); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluatePawnsSymmetric(t *testing.T) {
+	white, _ := fen.Parse("4k3/pp3p2/2p5/3P4/8/1P6/P4PPP/4K3 w - - 0 1")
+	black, _ := fen.Parse("4k3/p4ppp/1p6/8/3p4/2P5/PP3P2/4K3 b - - 0 1")
+
+	a := defaultParams.evaluatePawns(white)
+	b := defaultParams.evaluatePawns(black)
+	if a.score[0] != b.score[1] || a.score[1] != b.score[0] {
+		t.Errorf("structure scores not mirrored: %v vs %v", a.score, b.score)
+	}
+	if a.passed[0] != flip(b.passed[1]) || a.passed[1] != flip(b.passed[0]) {
+		t.Errorf("passed pawns not mirrored")
+	}
+	// c6 guards d5, nothing stops h2
+	if a.passed[0] != squares("h2") {
+		t.Errorf("white passed = %x, want h2", uint64(a.passed[0]))
+	}
+}
+
+// the pawn hash must never change the evaluation
+func TestPawnTableMatchesUncached(t *testing.T) {
+	pawns := newPawnTable()
+	for _, f := range evalPositions {
+		pos, _ := fen.Parse(f)
+		moves := movegen.LegalMoves(pos)
+		for i := 0; i < moves.Count(); i++ {
+			m := moves.Get(i)
+			next := position.MakeMove(pos, m)
+			for range 2 {
+				if got, want := defaultParams.evaluate(next, pawns), Evaluate(next); got != want {
+					t.Fatalf("%s %s: cached eval %d, uncached %d", f, m, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPassedPawnKingProximity(t *testing.T) {
+	// same pawn, the defending king near or far from its path
+	near, _ := fen.Parse("8/8/4k3/8/3P4/8/8/3K4 w - - 0 1")
+	far, _ := fen.Parse("8/8/8/8/3P4/8/8/3K3k w - - 0 1")
+	if Evaluate(far) <= Evaluate(near) {
+		t.Errorf("far king %d should be worse for the defender than near king %d", Evaluate(far), Evaluate(near))
+	}
+
+	blocked, _ := fen.Parse("8/3n4/8/8/3P4/8/8/3K3k w - - 0 1")
+	free, _ := fen.Parse("8/7n/8/8/3P4/8/8/3K3k w - - 0 1")
+	if EvaluateDetailed(free).Terms[TermPassed][0] <= EvaluateDetailed(blocked).Terms[TermPassed][0] {
+		t.Error("a free path should be worth more than a blocked one")
+	}
+}
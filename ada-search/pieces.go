@@ -0,0 +1,196 @@
+package search
+
+import (
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// pieceEval is what evaluatePieces finds for one side.
+type pieceEval struct {
+	mobility score
+	pieces   score
+
+	// pressure on the enemy king, scored against the enemy
+	kingAttack score
+
+	// every square attacked by a knight, bishop, rook or queen
+	attacks core.Bitboard
+}
+
+// relativeRank is a square's rank from color's side of the board.
+func relativeRank(sq core.Square, color core.Color) int {
+	if color == core.Black {
+		return 7 - sq.Rank()
+	}
+	return sq.Rank()
+}
+
+// relativeSquare maps a square from white's point of view to color's.
+func relativeSquare(sq int, color core.Color) core.Square {
+	if color == core.Black {
+		return core.Square(sq ^ 56)
+	}
+	return core.Square(sq)
+}
+
+// pawnAttackSpan is every square color's pawns attack now or could attack
+// after advancing.
+func pawnAttackSpan(pawns core.Bitboard, color core.Color) core.Bitboard {
+	if color == core.Black {
+		return sides(southFill(pawns) >> 8)
+	}
+	return sides(northFill(pawns) << 8)
+}
+
+// evaluatePieces scores color's knights, bishops, rooks and queens: mobility,
+// attacks on the enemy king zone, the bishop pair, rook files and the 7th
+// rank, knight outposts and trapped pieces.
+func (p *evalParams) evaluatePieces(pos *position.Position, color core.Color, ourPawnAtk, theirPawnAtk core.Bitboard, theirKing int) pieceEval {
+	var e pieceEval
+
+	them := color.Flip()
+	occupied := pos.Board.Occupied()
+	ourPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, color))
+	theirPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, them))
+	ourKing := pos.Board.Pieces(core.NewPiece(core.King, color))
+
+	// squares worth moving to: not blocked by our own pawns or king, not
+	// covered by an enemy pawn
+	area := (ourPawns | ourKing | theirPawnAtk).Invert()
+
+	// squares enemy pawns can never chase a piece from
+	safeFromPawns := pawnAttackSpan(theirPawns, them).Invert()
+
+	zone := kingZone[theirKing]
+	var attackers int
+	var attack score
+
+	for pt := core.Knight; pt <= core.Queen; pt++ {
+		for sq := range pos.Board.Pieces(core.NewPiece(pt, color)).Squares() {
+			var atk core.Bitboard
+			switch pt {
+			case core.Knight:
+				atk = movegen.KnightMoves(sq)
+			case core.Bishop:
+				atk = movegen.BishopMoves(sq, occupied)
+			case core.Rook:
+				atk = movegen.RookMoves(sq, occupied)
+			case core.Queen:
+				atk = movegen.BishopMoves(sq, occupied) | movegen.RookMoves(sq, occupied)
+			}
+			e.attacks |= atk
+
+			moves := (atk & area).Count()
+			e.mobility = e.mobility.add(p.mobility[pt].scale(moves - p.mobilityBase[pt]))
+
+			if n := (atk & zone).Count(); n > 0 {
+				attackers++
+				attack = attack.add(p.kingAttack[pt].scale(n))
+			}
+
+			rank := relativeRank(sq, color)
+			switch pt {
+			case core.Knight:
+				if rank >= 3 && rank <= 5 && ourPawnAtk.Check(sq) && safeFromPawns.Check(sq) {
+					e.pieces = e.pieces.add(p.knightOutpost)
+				}
+
+			case core.Bishop:
+				// a7 shut in by b6, h7 by g6
+				rel := relativeSquare(int(sq), color)
+				if (rel == 48 && theirPawns.Check(relativeSquare(41, color))) ||
+					(rel == 55 && theirPawns.Check(relativeSquare(46, color))) {
+					e.pieces = e.pieces.add(p.trappedBishop)
+				}
+
+			case core.Rook:
+				file := fileA << sq.File()
+				if file&ourPawns == 0 {
+					if file&theirPawns == 0 {
+						e.pieces = e.pieces.add(p.rookOpenFile)
+					} else {
+						e.pieces = e.pieces.add(p.rookSemiOpenFile)
+					}
+				}
+
+				if rank == 6 {
+					seventh := rankMask(relativeSquare(48, color).Rank())
+					eighth := rankMask(relativeSquare(56, color).Rank())
+					if seventh&theirPawns != 0 || eighth.Check(core.Square(theirKing)) {
+						e.pieces = e.pieces.add(p.rookSeventh)
+					}
+				}
+
+				// a rook on the back rank with its own king between it and
+				// the center has nowhere to go
+				if rank == 0 && moves <= 3 {
+					for k := range ourKing.Squares() {
+						if relativeRank(k, color) == 0 &&
+							((k.File() >= 4 && sq.File() > k.File()) || (k.File() < 4 && sq.File() < k.File())) {
+							e.pieces = e.pieces.add(p.trappedRook)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if pos.Board.Pieces(core.NewPiece(core.Bishop, color)).Count() >= 2 {
+		e.pieces = e.pieces.add(p.bishopPair)
+	}
+
+	// a lone attacker is easy to meet, pressure counts once pieces combine
+	if attackers >= 2 {
+		e.kingAttack = attack
+	}
+
+	return e
+}
+
+// rankMask is every square on a rank.
+func rankMask(rank int) core.Bitboard {
+	return core.Bitboard(0xff) << (8 * rank)
+}
+
+// kingShelter scores the pawns in front of color's king and the files next
+// to it.
+func (p *evalParams) kingShelter(pos *position.Position, color core.Color, kingSq int) score {
+	var s score
+
+	king := core.Square(kingSq)
+	ourPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, color))
+	theirPawns := pos.Board.Pieces(core.NewPiece(core.Pawn, color.Flip()))
+
+	// pawns only shelter a king that is still at home
+	rank := relativeRank(king, color)
+	if rank > 1 {
+		return s
+	}
+
+	dir := 8
+	if color == core.Black {
+		dir = -8
+	}
+
+	for f := max(0, king.File()-1); f <= min(7, king.File()+1); f++ {
+		file := fileA << f
+
+		near := kingSq - king.File() + f + dir
+		if ourPawns.Check(core.Square(near)) {
+			s = s.add(p.shieldNear)
+		} else if far := near + dir; far >= 0 && far < 64 && ourPawns.Check(core.Square(far)) {
+			s = s.add(p.shieldFar)
+		}
+
+		if file&ourPawns == 0 {
+			if file&theirPawns == 0 {
+				s = s.add(p.kingOpenFile)
+			} else {
+				s = s.add(p.kingSemiOpenFile)
+			}
+		}
+	}
+
+	return s
+}
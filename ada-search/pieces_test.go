@@ -0,0 +1,113 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+// termFor evaluates a FEN and returns one side's value of a term.
+func termFor(t *testing.T, f string, term Term, side int) int {
+	t.Helper()
+	pos, err := fen.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return EvaluateDetailed(pos).Terms[term][side]
+}
+
+func TestPieceTerms(t *testing.T) {
+	tests := []struct {
+		name          string
+		term          Term
+		side          int
+		better, worse string
+	}{
+		{
+			"centralized knight is more mobile", TermMobility, 0,
+			"4k3/8/8/8/3N4/8/8/4K3 w - - 0 1",
+			"4k3/8/8/8/8/8/8/N3K3 w - - 0 1",
+		},
+		{
+			"squares covered by enemy pawns don't count", TermMobility, 0,
+			"4k3/8/8/8/3N4/8/8/4K3 w - - 0 1",
+			"4k3/8/2p1p3/8/3N4/8/8/4K3 w - - 0 1",
+		},
+		{
+			"bishop pair", TermPieces, 0,
+			"4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1",
+			"4k3/8/8/8/8/8/8/2N1KB2 w - - 0 1",
+		},
+		{
+			"rook on an open file", TermPieces, 0,
+			"4k3/pp6/8/8/8/8/P7/3RK3 w - - 0 1",
+			"4k3/3p4/8/8/8/8/3P4/3RK3 w - - 0 1",
+		},
+		{
+			"rook on a semi-open file", TermPieces, 0,
+			"4k3/3p4/8/8/8/8/8/3RK3 w - - 0 1",
+			"4k3/3p4/8/8/8/8/3P4/3RK3 w - - 0 1",
+		},
+		{
+			"rook on the 7th", TermPieces, 0,
+			"4k3/1R3ppp/8/8/8/8/P7/4K3 w - - 0 1",
+			"4k3/5ppp/1R6/8/8/8/P7/4K3 w - - 0 1",
+		},
+		{
+			"knight outpost", TermPieces, 0,
+			"4k3/p7/8/4N3/3P4/8/8/4K3 w - - 0 1",
+			"4k3/p4p2/8/4N3/3P4/8/8/4K3 w - - 0 1",
+		},
+		{
+			"trapped bishop", TermPieces, 0,
+			"4k3/1B6/p7/1p6/8/8/8/4K3 w - - 0 1",
+			"4k3/B7/1p6/8/8/8/8/4K3 w - - 0 1",
+		},
+		{
+			"rook trapped by its own king", TermPieces, 0,
+			"4k3/8/8/8/8/8/5PPP/5RK1 w - - 0 1",
+			"4k3/8/8/8/8/8/5PPP/4K2R w - - 0 1",
+		},
+		{
+			"pawn shield", TermKingSafety, 0,
+			"q3k3/8/8/8/8/8/5PPP/Q5K1 w - - 0 1",
+			"q3k3/8/8/8/5PPP/8/8/Q5K1 w - - 0 1",
+		},
+		{
+			"open file next to the king", TermKingSafety, 0,
+			"q3k3/6p1/8/8/8/8/5PPP/Q5K1 w - - 0 1",
+			"q3k3/8/8/8/8/8/5P1P/Q5K1 w - - 0 1",
+		},
+		{
+			"pieces combining on the king", TermKingSafety, 1,
+			"6k1/5ppp/8/8/8/8/8/Q1B1K3 w - - 0 1",
+			"6k1/5ppp/8/8/8/2B5/8/4K2Q w - - 0 1",
+		},
+	}
+
+	for _, tt := range tests {
+		better := termFor(t, tt.better, tt.term, tt.side)
+		worse := termFor(t, tt.worse, tt.term, tt.side)
+		if better <= worse {
+			t.Errorf("%s: %s %d should beat %d", tt.name, tt.term, better, worse)
+		}
+	}
+}
+
+func TestKingAttackNeedsTwoPieces(t *testing.T) {
+	lone := termFor(t, "6k1/5ppp/8/8/8/8/8/4K2Q w - - 0 1", TermKingSafety, 1)
+	none := termFor(t, "6k1/5ppp/8/8/8/8/8/Q3K3 w - - 0 1", TermKingSafety, 1)
+	if lone != none {
+		t.Errorf("a lone attacker changed king safety: %d vs %d", lone, none)
+	}
+}
+
+func TestPawnAttackSpan(t *testing.T) {
+	if got, want := pawnAttackSpan(squares("e6"), core.Black), squares("d5", "f5", "d4", "f4", "d3", "f3", "d2", "f2", "d1", "f1"); got != want {
+		t.Errorf("black span = %x, want %x", uint64(got), uint64(want))
+	}
+	if got, want := pawnAttackSpan(squares("e6"), core.White), squares("d7", "f7", "d8", "f8"); got != want {
+		t.Errorf("white span = %x, want %x", uint64(got), uint64(want))
+	}
+}
@@ -2,6 +2,7 @@ package search
 
 import (
 	"math"
+	"math/rand/v2"
 	"sync"
 	"sync/atomic"
 	"runtime"
@@ -9,28 +10,63 @@ import (
 	"github.com/WilliamDann/AdaEngine/ada-chess/core"
 	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
 	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
 )
 
 const maxDepth = 64
 const maxMoves = 256
 
-// Precomputed LMR reduction table: lmrTable[depth][moveIndex]
-var lmrTable [maxDepth][maxMoves]int
+// singularDepth is the minimum depth for trying a singular extension.
+const singularDepth = 8
 
-func init() {
+// Precomputed LMR reduction table: lmrTable[depth][moveIndex]
+type lmrTable [maxDepth][maxMoves]int
+
+// newLMRTable fills a reduction table from the options' formula,
+// base + ln(depth)·ln(moveIndex) / divisor, with base and divisor given in
+// hundredths.
+func newLMRTable(opts Options) *lmrTable {
+	var t lmrTable
+	divisor := float64(max(opts.LMRDivisor, 1)) / 100
 	for d := 1; d < maxDepth; d++ {
 		for m := 1; m < maxMoves; m++ {
-			lmrTable[d][m] = int(0.5 + math.Log(float64(d))*math.Log(float64(m))/2.0)
+			t[d][m] = int(float64(opts.LMRBase)/100 + math.Log(float64(d))*math.Log(float64(m))/divisor)
 		}
 	}
+	return &t
 }
 
+var defaultLMR = newLMRTable(DefaultOptions())
+
 const (
 	Mate   = 30_000
 	Inf    = Mate + 1
 	maxPly = 128
+
+	// MaxMatePly bounds the distance to mate a score can encode: scores
+	// within it of Mate are mates found by the search or the tablebases,
+	// at Mate minus the plies to mate from the root.
+	MaxMatePly = 512
 )
 
+// isMate reports whether a score is a mate for either side.
+func isMate(score int) bool {
+	return score > Mate-MaxMatePly || score < -Mate+MaxMatePly
+}
+
+// MateIn returns the number of moves to the mate a score encodes, negative
+// when the side to move is the one getting mated, and whether it is a mate
+// score at all.
+func MateIn(score int) (int, bool) {
+	switch {
+	case score > Mate-MaxMatePly:
+		return (Mate - score + 1) / 2, true
+	case score < -Mate+MaxMatePly:
+		return -(Mate + score + 1) / 2, true
+	}
+	return 0, false
+}
+
 // killers stores two killer moves per ply — quiet moves that caused
 // beta cutoffs in sibling nodes at the same depth.
 type killers [maxPly][2]core.Move
@@ -58,9 +94,37 @@ type Result struct {
 	Score int
 	Depth int
 	Nodes uint64
+
+	// Hashfull is the transposition table occupancy in permille.
+	Hashfull int
+
+	// TBHits counts positions found in the tablebases.
+	TBHits uint64
+
+	// Book is set when the move came from the opening book unsearched.
+	Book bool
+
+	// Lines holds the best Options.MultiPV root moves, best first, when
+	// more than one is asked for.
+	Lines []Line
+
+	// PV is the line the search expects, starting with Move, as far as the
+	// transposition table still remembers it. The final result and those
+	// passed to the onDepth callback have one.
+	PV []core.Move
 }
 
-func quiesce(tt *TT, pos *position.Position, ply int, alpha, beta int, nodes *uint64) int {
+// quiesce resolves captures until the position is quiet so the static eval
+// isn't trusted in the middle of an exchange. qply counts plies since the
+// main search handed over.
+func quiesce(tt *TT, td *threadData, pos *position.Position, ply, qply int, alpha, beta int, nodes *uint64) int {
+	if td.nn != nil {
+		td.nn.enter(ply, pos)
+	}
+	if ply >= maxPly {
+		return td.evaluate(pos, ply)
+	}
+
 	entry, found := tt.Probe(pos.Zobrist)
 	startAlpha   := alpha
 	if found {
@@ -80,30 +144,77 @@ func quiesce(tt *TT, pos *position.Position, ply int, alpha, beta int, nodes *ui
 		}
 	}
 
-	stand := Evaluate(pos)
-	if stand >= beta {
-		return beta
+	// In check standing pat isn't an option: every evasion is searched, and
+	// having none is mate.
+	inCheck := movegen.InCheck(pos)
+	stand := -Inf
+	var moves core.MoveList
+	if inCheck {
+		moves = movegen.LegalMoves(pos)
+		if moves.Count() == 0 {
+			return -Mate + ply
+		}
+	} else {
+		stand = td.evaluate(pos, ply)
+		if stand >= beta {
+			return beta
+		}
+		if stand > alpha {
+			alpha = stand
+		}
+
+		moves = movegen.LegalCaptures(pos)
+		if qply == 0 && td.opts.QuiescenceChecks {
+			checks := movegen.LegalQuietChecks(pos)
+			for i := 0; i < checks.Count(); i++ {
+				moves.Add(checks.Get(i))
+			}
+		}
+	}
+
+	ttMove := core.NoMove
+	if found {
+		ttMove = entry.Move
 	}
-	if stand > alpha {
-		alpha = stand
+
+	n := moves.Count()
+	var scores [maxMoves]int
+	for i := 0; i < n; i++ {
+		mv := moves.Get(i)
+		switch {
+		case mv == ttMove:
+			scores[i] = ttMoveScore
+		case pos.Board.HasPiece(mv.To()):
+			scores[i] = goodCaptureScore + td.eval.mvvlva(pos, mv)
+		default:
+			scores[i] = td.quietScore(pos, ply, mv)
+		}
 	}
 
-	captures := movegen.LegalCaptures(pos)
-	for i := 0; i < captures.Count(); i++ {
-		captured := pos.Board.Check(captures.Get(i).To()).Type()
-		if stand + pieceValue[captured] + 200 < alpha {
+	bestMove := core.NoMove
+	for i := 0; i < n; i++ {
+		pickMove(&moves, scores[:n], i)
+		mv := moves.Get(i)
+		captured := pos.Board.Check(mv.To()).Type()
+
+		// delta pruning: even winning this piece can't lift us to alpha
+		if !inCheck && captured != 0 && stand + td.eval.pieceValue[captured] + td.opts.QuiesceDelta < alpha {
 			continue
 		}
 
-		child := position.MakeMove(pos, captures.Get(i))
+		child := position.MakeMove(pos, mv)
 		*nodes++
+		// the continuation history of the replies looks back at this move
+		td.stack[ply].move = mv
+		td.stack[ply].piece = pos.Board.Check(mv.From())
 
-		score := -quiesce(tt, child, ply+1, -beta, -alpha, nodes)
+		score := -quiesce(tt, td, child, ply+1, qply+1, -beta, -alpha, nodes)
 		if score >= beta {
 			return beta
 		}
 		if score > alpha {
 			alpha = score
+			bestMove = mv
 		}
 	}
 
@@ -118,7 +229,7 @@ func quiesce(tt *TT, pos *position.Position, ply int, alpha, beta int, nodes *ui
 
 	tt.Store(TTEntry{
 		Key: pos.Zobrist,
-		Move: core.NoMove,
+		Move: bestMove,
 		Depth: 0,
 		Score: adjustScoreForStore(alpha, ply),
 		Flag: flagType,
@@ -128,10 +239,10 @@ func quiesce(tt *TT, pos *position.Position, ply int, alpha, beta int, nodes *ui
 }
 
 func adjustScoreForStore(score int, ply int) int16 {
-	if score > Mate-100 {
+	if score > Mate-MaxMatePly {
 		return int16(score + ply)
 	}
-	if score < -Mate+100 {
+	if score < -Mate+MaxMatePly {
 		return int16(score - ply)
 	}
 	return int16(score)
@@ -139,74 +250,160 @@ func adjustScoreForStore(score int, ply int) int16 {
 
 func adjustScoreForProbe(score int16, ply int) int {
 	s := int(score)
-	if s > Mate-100 {
+	if s > Mate-MaxMatePly {
 		return s - ply
 	}
-	if s < -Mate+100 {
+	if s < -Mate+MaxMatePly {
 		return s + ply
 	}
 	return s
 }
 
+// tbScore converts a tablebase result for the node at ply into a score,
+// with draw what a draw is worth there.
+func tbScore(r tablebase.Result, ply, draw int) int {
+	switch r.Outcome {
+	case tablebase.Win:
+		return Mate - ply - r.Plies
+	case tablebase.Loss:
+		return -Mate + ply + r.Plies
+	}
+	return draw
+}
+
 // score for diff in attacking vs attacked peices
-func mvvlva(pos *position.Position, m core.Move) int {
+func (p *evalParams) mvvlva(pos *position.Position, m core.Move) int {
 	victim := pos.Board.Check(m.To()).Type()
 	attacker := pos.Board.Check(m.From()).Type()
 	if victim == 0 {
 		return 0 // quiet move
 	}
-	return pieceValue[victim] - pieceValue[attacker]
+	return p.pieceValue[victim] - p.pieceValue[attacker]
+}
+
+// Search runs iterative deepening alpha-beta to the given depth with a
+// fresh transposition table. See Engine.Search.
+func Search(pos *position.Position, depth int, threads int, timeLimit time.Duration, onDepth ...func(Result)) Result {
+	return NewEngine(DefaultHashMB).Search(pos, depth, threads, timeLimit, onDepth...)
 }
 
 // Search runs iterative deepening alpha-beta to the given depth.
 // If timeLimit > 0 the search is aborted when time expires; depth is
 // used as a hard upper bound (use maxPly for "unlimited").
 // The optional onDepth callback is called after each iteration completes.
-func Search(pos *position.Position, depth int, threads int, timeLimit time.Duration, onDepth ...func(Result)) Result {
-	tt := NewTT(1 << 22)
-	stop := &atomic.Bool{}
-
-	if timeLimit > 0 {
-		timer := time.AfterFunc(timeLimit, func() { stop.Store(true) })
-		defer timer.Stop()
-	}
-
+func (e *Engine) Search(pos *position.Position, depth int, threads int, timeLimit time.Duration, onDepth ...func(Result)) Result {
 	numThreads := threads
 	if numThreads <= 0 {
 		numThreads = runtime.NumCPU()
 	}
 
+	var cb func(Result)
+	if len(onDepth) > 0 {
+		cb = onDepth[0]
+	}
+
+	if m, ok := e.bookMove(pos); ok {
+		res := Result{Move: m, Hashfull: e.tt.Hashfull(), Book: true, PV: []core.Move{m}}
+		if cb != nil {
+			cb(res)
+		}
+		return res
+	}
+
+	// a position in the tablebases needs no search: the best move keeps
+	// the result
+	if tb := e.tablebases(); tb != nil {
+		if m, r, ok := tb.BestMove(pos); ok {
+			res := Result{Move: m, Score: tbScore(r, 0, -e.Options.Contempt), Depth: 1, TBHits: 1, Hashfull: e.tt.Hashfull(), PV: []core.Move{m}}
+			if cb != nil {
+				cb(res)
+			}
+			return res
+		}
+	}
+
+	opts := e.Options
+	level := opts.skillLevel()
+	if level < MaxSkillLevel {
+		numThreads = 1
+		depth = min(depth, skillDepth(level))
+		opts.MultiPV = max(opts.MultiPV, skillLines)
+	}
+
+	e.tt.NewSearch()
+	shared := newSharedSearch(e.tt, opts, e.eval, numThreads, cb)
+	shared.net = e.network()
+	shared.tb = e.tablebases()
+	shared.root = pos
+	shared.gameKeys = e.gameKeys
+	shared.nodeLimit = e.NodeLimit
+	if level < MaxSkillLevel {
+		if shared.nodeLimit == 0 || skillNodes(level) < shared.nodeLimit {
+			shared.nodeLimit = skillNodes(level)
+		}
+		shared.noise = skillNoise(level)
+		shared.noiseSeed = rand.Uint64()
+	}
+	e.running.Store(shared)
+	defer e.running.Store(nil)
+
+	if timeLimit > 0 {
+		timer := time.AfterFunc(timeLimit, func() { shared.stop.Store(true) })
+		defer timer.Stop()
+	}
+
 	results    := make([]Result, numThreads)
 	var wg sync.WaitGroup
 
 	for t := 0; t < numThreads; t++ {
 		wg.Add(1)
-		var cb func(Result)
-		if t == 0 && len(onDepth) > 0 {
-			cb = onDepth[0]
-		}
-		go func(thread int, callback func(Result)) {
+		go func(thread int) {
 			defer wg.Done()
-			results[thread] = searchWorker(tt, stop, pos, depth, thread, callback)
-		}(t, cb)
+			results[thread] = searchWorker(shared, pos, depth, thread)
+
+			// helpers only exist to support the main thread
+			if thread == 0 {
+				shared.stop.Store(true)
+			}
+		}(t)
 	}
 	wg.Wait()
 
-	best := results[0]
-	for _, r := range results[1:] {
-		if r.Depth > best.Depth || (r.Depth == best.Depth && r.Score > best.Score) {
-			best = r
-		}
+	best := voteResult(results)
+	best.Hashfull = e.tt.Hashfull()
+	if level < MaxSkillLevel {
+		best = weaken(best, pos, level)
+	}
+	if best.Move != core.NoMove {
+		best.PV = e.tt.line(pos, best.Move, maxPVLength)
 	}
 
 	return best
 }
 
-func searchWorker(tt *TT, stop *atomic.Bool, pos *position.Position, depth int, thread int, onDepth func(Result)) Result {
+// searchWorker runs iterative deepening for one thread. Each thread has its
+// own history and killer tables.
+func searchWorker(shared *sharedSearch, pos *position.Position, depth int, thread int) Result {
 	var best Result
 	best.Score = -Inf
 	var nodes uint64
-	var k killers
+	td := newThreadData()
+	td.opts = shared.opts
+	td.eval = &shared.eval
+	td.lmr = shared.lmr
+	if shared.net != nil {
+		td.nn = newNNStack(shared.net)
+		td.nn.enter(0, pos)
+	}
+	td.tb = shared.tb
+	td.rootColor = pos.ActiveColor
+	td.keys[0] = pos.Zobrist
+	td.gameKeys = shared.gameKeys
+	td.nodeLimit = shared.nodeLimit
+	td.noise = shared.noise
+	td.noiseSeed = shared.noiseSeed
+	tt := shared.tt
+	stop := &shared.stop
 
 	moves := movegen.LegalMoves(pos)
 	n := moves.Count()
@@ -216,17 +413,32 @@ func searchWorker(tt *TT, stop *atomic.Bool, pos *position.Position, depth int,
 		ordered[i] = moves.Get(i)
 	}
 
-	const aspirationWindow = 50
+	// nothing to search in checkmate or stalemate
+	if n == 0 {
+		return best
+	}
+
+	// a search stopped before finishing depth 1 still has to return a
+	// legal move
+	best.Move = ordered[0]
+
+	aspirationWindow := td.opts.AspirationWindow
+	multiPV := min(td.opts.MultiPV, n)
 
 	for d := 1; d <= depth; d++ {
 		if stop.Load() {
 			break
 		}
+		if skipDepth(thread, d) {
+			continue
+		}
+		td.rootDepth = d
 		alpha := -Inf
 		beta := Inf
 
 		// Aspiration window: use previous score to narrow the search
-		if d >= 4 && best.Score > -Mate+100 && best.Score < Mate-100 {
+		windowed := d >= 4 && !isMate(best.Score) && multiPV == 1
+		if windowed {
 			alpha = best.Score - aspirationWindow
 			beta = best.Score + aspirationWindow
 		}
@@ -235,9 +447,22 @@ func searchWorker(tt *TT, stop *atomic.Bool, pos *position.Position, depth int,
 		for i := 0; i < n; i++ {
 			child := position.MakeMove(pos, ordered[i])
 			nodes++
-			score := -alphabeta(tt, &k, stop, child, 1, d-1, -beta, -alpha, &nodes)
+			td.stack[0] = stackEntry{move: ordered[i], piece: pos.Board.Check(ordered[i].From())}
+
+			var score int
+			if td.opts.PVS && i > 0 {
+				score = -alphabeta(tt, td, stop, child, 1, d-1, -(alpha+1), -alpha, &nodes)
+				if score > alpha && score < beta {
+					score = -alphabeta(tt, td, stop, child, 1, d-1, -beta, -alpha, &nodes)
+				}
+			} else {
+				score = -alphabeta(tt, td, stop, child, 1, d-1, -beta, -alpha, &nodes)
+			}
 			scores[i] = score
-			if score > alpha {
+			if multiPV > 1 {
+				// a move only has to beat the worst of the lines so far
+				alpha = max(alpha, kthBest(scores[:i+1], multiPV))
+			} else if score > alpha {
 				alpha = score
 			}
 		}
@@ -254,7 +479,8 @@ func searchWorker(tt *TT, stop *atomic.Bool, pos *position.Position, depth int,
 				bestIdx = i
 			}
 		}
-		if d >= 4 && (scores[bestIdx] <= best.Score-aspirationWindow || scores[bestIdx] >= best.Score+aspirationWindow) {
+		if windowed && (scores[bestIdx] <= best.Score-aspirationWindow || scores[bestIdx] >= best.Score+aspirationWindow) {
+			windowed = false
 			alpha = -Inf
 			beta = Inf
 			goto research
@@ -264,19 +490,35 @@ func searchWorker(tt *TT, stop *atomic.Bool, pos *position.Position, depth int,
 		best.Score = scores[bestIdx]
 		best.Depth = d
 		best.Nodes = nodes
-
-		if onDepth != nil {
-			onDepth(best)
-		}
+		best.TBHits = td.tbHits
 
 		// Sort moves descending by score for next iteration
 		sortMoves(ordered, scores, n)
+		if multiPV > 1 {
+			best.Lines = topLines(ordered, scores, multiPV)
+		}
+		shared.report(thread, best)
 	}
 
 	best.Nodes = nodes
+	best.TBHits = td.tbHits
 	return best
 }
 
+// pickMove swaps the best scoring move at or after i into slot i, keeping
+// scores aligned with the list. Ordering this way lazily avoids sorting moves
+// that are never searched after a cutoff.
+func pickMove(moves *core.MoveList, scores []int, i int) {
+	best := i
+	for j := i + 1; j < len(scores); j++ {
+		if scores[j] > scores[best] {
+			best = j
+		}
+	}
+	moves.Swap(i, best)
+	scores[i], scores[best] = scores[best], scores[i]
+}
+
 // sortMoves does an insertion sort of moves by descending score.
 func sortMoves(moves []core.Move, scores []int, n int) {
 	for i := 1; i < n; i++ {
@@ -290,16 +532,32 @@ func sortMoves(moves []core.Move, scores []int, n int) {
 	}
 }
 
-func alphabeta(tt *TT, k *killers, stop *atomic.Bool, pos *position.Position, ply int, depth int, alpha, beta int, nodes *uint64) int {
+func alphabeta(tt *TT, td *threadData, stop *atomic.Bool, pos *position.Position, ply int, depth int, alpha, beta int, nodes *uint64) int {
+	if td.nodeLimit > 0 && td.rootDepth > 1 && *nodes >= td.nodeLimit {
+		stop.Store(true)
+	}
 	if stop.Load() {
 		return 0
 	}
+	if td.nn != nil {
+		td.nn.enter(ply, pos)
+	}
+	if ply >= maxPly {
+		return td.evaluate(pos, ply)
+	}
+	if td.isDraw(pos, ply) {
+		return td.drawScore(pos)
+	}
+
+	// a singular extension verification search skips the TT move and must
+	// not use or overwrite the entry it is verifying
+	excluded := td.stack[ply].excluded
 
 	// look up in transposition table
 	entry, found := tt.Probe(pos.Zobrist)
 	startAlpha   := alpha
 	bestMove     := core.NoMove
-	if found {
+	if found && excluded == core.NoMove {
 		if entry.Depth >= int8(depth) {
 			score := adjustScoreForProbe(entry.Score, ply)
 
@@ -318,118 +576,245 @@ func alphabeta(tt *TT, k *killers, stop *atomic.Bool, pos *position.Position, pl
 		}
 	}
 
+	// an endgame in the tablebases has an exact score
+	if td.tb != nil && excluded == core.NoMove {
+		if r, ok := td.tb.Probe(pos); ok {
+			td.tbHits++
+			return tbScore(r, ply, td.drawScore(pos))
+		}
+	}
+
 	moves := movegen.LegalMoves(pos)
 
 	// Terminal: no legal moves
 	if moves.Count() == 0 {
 		if movegen.InCheck(pos) {
-			return -Mate // Checkmated
+			return -Mate + ply // Checkmated
 		}
-		return 0 // Stalemate
+		return td.drawScore(pos) // Stalemate
 	}
 
 	if depth == 0 {
-		return quiesce(tt, pos, ply, alpha, beta, nodes)
+		return quiesce(tt, td, pos, ply, 0, alpha, beta, nodes)
 	}
 
 	inCheck := movegen.InCheck(pos)
+	pvNode := beta-alpha > 1
+	opts := &td.opts
+
+	staticEval := 0
+	if !inCheck {
+		staticEval = td.evaluate(pos, ply)
+	}
+
+	// internal iterative reduction: without a TT move this node is poorly
+	// ordered, so spend less on it now and let a later iteration fill the TT
+	if opts.IIR && depth >= opts.IIRDepth && excluded == core.NoMove && (!found || entry.Move == core.NoMove) {
+		depth--
+	}
 
-	// null move pruning (if we can skip a move and be winning just prune)
-	if depth >= 3 && !inCheck {
-		nullChild := position.MakeNullMove(pos)
-		nullScore := -alphabeta(tt, k, stop, nullChild, ply+1, depth-3, -beta, -beta+1, nodes)
-		if nullScore >= beta {
+	// pruning that trusts the static eval or a reduced search only applies
+	// to nodes expected to fail low or high, never to PV nodes
+	if !pvNode && !inCheck && excluded == core.NoMove && beta < Mate-MaxMatePly && alpha > -Mate+MaxMatePly {
+		// reverse futility: far enough above beta that no reply will matter
+		if opts.ReverseFutility && depth <= opts.ReverseFutilityDepth && staticEval-opts.ReverseFutilityMargin*depth >= beta {
 			return beta
 		}
+
+		// razoring: far enough below alpha that only captures can save us
+		if opts.Razoring && depth <= opts.RazorDepth && staticEval+opts.RazorMargin*depth < alpha {
+			if quiesce(tt, td, pos, ply, 0, alpha-1, alpha, nodes) < alpha {
+				return alpha
+			}
+		}
+
+		// null move pruning (if we can skip a move and be winning just prune).
+		// Never with only pawns left, where passing is often the best move.
+		material := td.eval.nonPawnMaterial(pos, pos.ActiveColor)
+		if opts.NullMove && depth >= 3 && staticEval >= beta && material > 0 && ply >= td.nullMinPly {
+			R := min(opts.NullMoveBase+depth/max(1, opts.NullMoveDivisor), depth)
+			nullChild := position.MakeNullMove(pos)
+			td.stack[ply] = stackEntry{}
+			nullScore := -alphabeta(tt, td, stop, nullChild, ply+1, depth-R, -beta, -beta+1, nodes)
+			if nullScore >= beta {
+				if material > opts.NullVerifyMaterial {
+					return beta
+				}
+
+				// zugzwang-prone endgame: confirm with a reduced normal
+				// search that doesn't null move near the root of it
+				saved := td.nullMinPly
+				td.nullMinPly = ply + 3*(depth-R)/4
+				v := alphabeta(tt, td, stop, pos, ply, depth-R, beta-1, beta, nodes)
+				td.nullMinPly = saved
+				if v >= beta {
+					return beta
+				}
+			}
+		}
+
+		// ProbCut: a capture that beats beta by a margin in a shallower
+		// search almost certainly beats beta at full depth
+		if opts.ProbCut && depth >= opts.ProbCutDepth {
+			pcBeta := beta + opts.ProbCutMargin
+			captures := movegen.LegalCaptures(pos)
+			for i := 0; i < captures.Count(); i++ {
+				mv := captures.Get(i)
+				if td.eval.mvvlva(pos, mv) < 0 {
+					continue
+				}
+				child := position.MakeMove(pos, mv)
+				*nodes++
+				td.stack[ply] = stackEntry{move: mv, piece: pos.Board.Check(mv.From())}
+
+				// cheap quiescence check before committing to the search
+				score := -quiesce(tt, td, child, ply+1, 0, -pcBeta, -pcBeta+1, nodes)
+				if score >= pcBeta {
+					score = -alphabeta(tt, td, stop, child, ply+1, depth-opts.ProbCutReduction, -pcBeta, -pcBeta+1, nodes)
+				}
+				if score >= pcBeta {
+					return beta
+				}
+			}
+		}
 	}
 
 	// futility pruning: at shallow depths, skip quiet moves that can't beat alpha
 	futile := false
-	if depth <= 2 && !inCheck {
-		if Evaluate(pos)+depth*150 <= alpha {
+	if opts.Futility && depth <= opts.FutilityDepth && !inCheck {
+		if staticEval+depth*opts.FutilityMargin <= alpha {
 			futile = true
 		}
 	}
 
-	// search best tt move
-	if found && entry.Move != core.NoMove {
-		child := position.MakeMove(pos, entry.Move)
-		*nodes++
-		score := -alphabeta(tt, k, stop, child, ply+1, depth-1, -beta, -alpha, nodes)
-		if score >= beta {
-			return beta
-		}
-		if score > alpha {
-			alpha = score
-			bestMove = entry.Move
-		}
+	// late move pruning: at shallow non-PV nodes stop trying quiets after
+	// this many
+	lmpLimit := maxMoves
+	if opts.LateMovePruning && !pvNode && !inCheck && depth <= opts.LMPDepth {
+		lmpLimit = opts.LMPBase + depth*depth
+	}
+
+	ttMove := core.NoMove
+	if found {
+		ttMove = entry.Move
 	}
 
-	for i := 0; i < moves.Count(); i++ {
-		// skip TT move
-		if found && moves.Get(i) == entry.Move {
+	n := moves.Count()
+	var scores [maxMoves]int
+	counter := td.counterMove(ply)
+	for i := 0; i < n; i++ {
+		scores[i] = td.scoreMove(pos, ply, moves.Get(i), ttMove, counter)
+	}
+
+	// quiet moves searched without a cutoff, penalized if a later one cuts
+	var quiets [maxMoves]core.Move
+	numQuiets := 0
+
+	for i := 0; i < n; i++ {
+		pickMove(&moves, scores[:n], i)
+		mv := moves.Get(i)
+		if mv == excluded {
 			continue
 		}
+		piece := pos.Board.Check(mv.From())
+		isCapture := pos.Board.Check(mv.To()).Type() != 0
 
-		bestIdx   := i
-		bestScore := mvvlva(pos, moves.Get(i))
-		if k.isKiller(ply, moves.Get(i)) {
-			bestScore += 50
-		}
-		for j := i + 1; j < moves.Count(); j++ {
-			if found && moves.Get(j) == entry.Move {
-				continue
-			}
-			s := mvvlva(pos, moves.Get(j))
-			if k.isKiller(ply, moves.Get(j)) {
-				s += 50
-			}
-			if s > bestScore {
-				bestScore = s
-				bestIdx   = j
-			}
-		}
-		moves.Swap(i, bestIdx)
-		mv := moves.Get(i)
 		child := position.MakeMove(pos, mv)
 		*nodes++
-
-		isCapture := pos.Board.Check(mv.To()).Type() != 0
 		givesCheck := movegen.InCheck(child)
 
-		if futile && !isCapture && !givesCheck {
+		if futile && mv != ttMove && !isCapture && !givesCheck {
 			continue
 		}
+		if numQuiets >= lmpLimit && !isCapture && !givesCheck {
+			continue
+		}
+
+		// extensions are capped by ply so checks can't extend forever
+		extension := 0
+		if ply < 2*td.rootDepth {
+			if mv == ttMove && td.opts.SingularExtensions && depth >= singularDepth &&
+				excluded == core.NoMove && entry.Depth >= int8(depth-3) && entry.Flag != UpperBound {
+				ttScore := adjustScoreForProbe(entry.Score, ply)
+				if !isMate(ttScore) {
+					// is every other move worse than the TT move by a margin?
+					singularBeta := ttScore - 2*depth
+					td.stack[ply].excluded = mv
+					s := alphabeta(tt, td, stop, pos, ply, (depth-1)/2, singularBeta-1, singularBeta, nodes)
+					td.stack[ply].excluded = core.NoMove
+
+					if s < singularBeta {
+						extension = 1
+					} else if singularBeta >= beta {
+						// multi-cut: several moves beat beta, so this node will too
+						return singularBeta
+					}
+				}
+			}
+			if extension == 0 && givesCheck && td.opts.CheckExtensions {
+				extension = 1
+			}
+		}
+		newDepth := depth - 1 + extension
 
-		// LMR: reduced search for late quiet moves
+		td.stack[ply].move = mv
+		td.stack[ply].piece = piece
+
+		// LMR: reduced search for late quiet moves, reducing moves with a
+		// good history less and moves with a bad history more
 		var score int
 		doFull := true
 		if i >= 3 && depth >= 3 && !isCapture && !givesCheck {
-			R := lmrTable[min(depth, maxDepth-1)][min(i, maxMoves-1)]
+			R := td.lmr[min(depth, maxDepth-1)][min(i, maxMoves-1)]
+			R -= td.quietScore(pos, ply, mv) / 8192
+			if scores[i] >= counterScore {
+				R--
+			}
 			if R < 1 {
 				R = 1
 			}
-			if R >= depth {
-				R = depth - 1
+			if R >= newDepth {
+				R = newDepth - 1
 			}
-			score = -alphabeta(tt, k, stop, child, ply+1, depth-1-R, -(alpha+1), -alpha, nodes)
+			score = -alphabeta(tt, td, stop, child, ply+1, newDepth-R, -(alpha+1), -alpha, nodes)
 			doFull = score > alpha
 		}
+
+		// PVS: moves after the first only need to prove they can't beat
+		// alpha, which a null window does more cheaply
+		if doFull && td.opts.PVS && i > 0 {
+			score = -alphabeta(tt, td, stop, child, ply+1, newDepth, -(alpha+1), -alpha, nodes)
+			doFull = score > alpha && score < beta
+		}
 		if doFull {
-			score = -alphabeta(tt, k, stop, child, ply+1, depth-1, -beta, -alpha, nodes)
+			score = -alphabeta(tt, td, stop, child, ply+1, newDepth, -beta, -alpha, nodes)
 		}
 		if score >= beta {
 			if !isCapture {
-				k.store(ply, mv)
+				td.updateQuietStats(pos, ply, depth, mv, quiets[:numQuiets])
 			}
 			return beta
 		}
 		if score > alpha {
 			alpha    = score
-			bestMove = moves.Get(i)
+			bestMove = mv
+		}
+		if !isCapture {
+			quiets[numQuiets] = mv
+			numQuiets++
 		}
 	}
 
+	if excluded != core.NoMove {
+		return alpha
+	}
+
+	// a stopped search's children returned nothing to go by, and storing
+	// what came of them would mislead the next search
+	if stop.Load() {
+		return 0
+	}
+
 	// store move in the transposition table
 	var flagType SearchFlag = Exact
 	if alpha <= startAlpha {
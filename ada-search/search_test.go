@@ -1,10 +1,17 @@
 package search
 
 import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/WilliamDann/AdaEngine/ada-chess/book"
 	"github.com/WilliamDann/AdaEngine/ada-chess/core"
 	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
 )
 
 func TestSearchStartingPosition(t *testing.T) {
@@ -36,8 +43,323 @@ func TestSearchMateIn1(t *testing.T) {
 	if res.Move.To() != core.NewSquare(6, 5) {
 		t.Errorf("expected mate move Qxf7#, got %s", res.Move)
 	}
-	if res.Score != Mate {
-		t.Errorf("expected mate score %d, got %d", Mate, res.Score)
+	if res.Score != Mate-1 {
+		t.Errorf("expected mate score %d, got %d", Mate-1, res.Score)
+	}
+	if moves, ok := MateIn(res.Score); !ok || moves != 1 {
+		t.Errorf("MateIn(%d) = %d, %v, want 1", res.Score, moves, ok)
 	}
 	t.Logf("mate in 1: move=%s score=%d nodes=%d", res.Move, res.Score, res.Nodes)
 }
+
+func TestSearchFeatureToggles(t *testing.T) {
+	pos, err := fen.Parse("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for mask := 0; mask < 8; mask++ {
+		e := NewEngine(1)
+		e.Options = Options{
+			PVS:                mask&1 != 0,
+			CheckExtensions:    mask&2 != 0,
+			SingularExtensions: mask&4 != 0,
+		}
+		res := e.Search(pos, 4, 1, 0)
+		if res.Move.To() != core.NewSquare(6, 5) || res.Score != Mate-1 {
+			t.Errorf("%+v: expected Qxf7# with mate score, got %s %d", e.Options, res.Move, res.Score)
+		}
+		t.Logf("%+v: nodes=%d", e.Options, res.Nodes)
+	}
+}
+
+func TestExcludedMoveIsSkipped(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 4")
+	mate := core.NewMove(core.NewSquare(4, 7), core.NewSquare(6, 5))
+
+	var nodes uint64
+	td := newThreadData()
+	if s := alphabeta(nil, td, &atomic.Bool{}, pos, 1, 1, -Inf, Inf, &nodes); s != Mate-2 {
+		t.Fatalf("expected mate score, got %d", s)
+	}
+
+	td.stack[1].excluded = mate
+	if s := alphabeta(nil, td, &atomic.Bool{}, pos, 1, 1, -Inf, Inf, &nodes); isMate(s) {
+		t.Fatalf("excluded mating move still found: %d", s)
+	}
+}
+
+func TestPruningKeepsMateInTwo(t *testing.T) {
+	// Nf6+ gxf6 Bxf7#
+	pos, err := fen.Parse("r2qkb1r/pp2nppp/3p4/2pNN1B1/2BnP3/3P4/PPP2PPP/R2bK2R w KQkq - 1 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	disable := map[string]func(*Options){
+		"none":             func(o *Options) {},
+		"null move":        func(o *Options) { o.NullMove = false },
+		"reverse futility": func(o *Options) { o.ReverseFutility = false },
+		"razoring":         func(o *Options) { o.Razoring = false },
+		"late move":        func(o *Options) { o.LateMovePruning = false },
+		"probcut":          func(o *Options) { o.ProbCut = false },
+		"iir":              func(o *Options) { o.IIR = false },
+	}
+	for name, off := range disable {
+		e := NewEngine(1)
+		off(&e.Options)
+		res := e.Search(pos, 5, 1, 0)
+		if res.Move.String() != "d5f6" || res.Score != Mate-3 {
+			t.Errorf("without %s: expected d5f6 mating, got %s %d", name, res.Move, res.Score)
+		}
+	}
+}
+
+func TestNonPawnMaterial(t *testing.T) {
+	pos, _ := fen.Parse("4k3/pppp4/8/8/8/8/PPPP4/RN2K3 w - - 0 1")
+	if got := defaultParams.nonPawnMaterial(pos, core.White); got != defaultParams.pieceValue[core.Rook]+defaultParams.pieceValue[core.Knight] {
+		t.Errorf("white non-pawn material = %d", got)
+	}
+	if got := defaultParams.nonPawnMaterial(pos, core.Black); got != 0 {
+		t.Errorf("black non-pawn material = %d, want 0", got)
+	}
+}
+
+func TestQuiesceDetectsMate(t *testing.T) {
+	// fool's mate: white is in check with no captures and no evasions
+	pos, _ := fen.Parse("rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3")
+
+	var nodes uint64
+	if s := quiesce(nil, newThreadData(), pos, 1, 0, -Inf, Inf, &nodes); s != -Mate+1 {
+		t.Errorf("expected mated score %d, got %d", -Mate+1, s)
+	}
+}
+
+func TestQuiesceSearchesEvasions(t *testing.T) {
+	// black's king is boxed in and only Ne7 blocks the queen's check
+	pos, _ := fen.Parse("3rkrn1/3p1p2/8/8/8/8/8/4QK2 b - - 0 1")
+	var nodes uint64
+	if s := quiesce(nil, newThreadData(), pos, 1, 0, -Inf, Inf, &nodes); isMate(s) {
+		t.Errorf("black can block the check, got mated score %d", s)
+	}
+
+	// without the knight the same check is mate
+	pos, _ = fen.Parse("3rkr2/3p1p2/8/8/8/8/8/4QK2 b - - 0 1")
+	if s := quiesce(nil, newThreadData(), pos, 1, 0, -Inf, Inf, &nodes); s != -Mate+1 {
+		t.Errorf("expected mated score %d, got %d", -Mate+1, s)
+	}
+}
+
+func TestQuiesceRecordsMoves(t *testing.T) {
+	// Ne7 is the only evasion, and the replies to it must see it as the
+	// previous move for their continuation history
+	pos, _ := fen.Parse("3rkrn1/3p1p2/8/8/8/8/8/4QK2 b - - 0 1")
+	td := newThreadData()
+	td.stack[1] = stackEntry{move: core.NewMove(core.NewSquare(0, 0), core.NewSquare(0, 1))}
+	var nodes uint64
+	quiesce(nil, td, pos, 1, 0, -Inf, Inf, &nodes)
+	if m := td.stack[1].move; m.String() != "g8e7" || td.stack[1].piece != core.NewPiece(core.Knight, core.Black) {
+		t.Errorf("stack holds %s %s, want the knight's g8e7", m, td.stack[1].piece)
+	}
+}
+
+func TestQuiesceQuietChecks(t *testing.T) {
+	// Rd8# is quiet, so only the check extension of quiescence finds it
+	pos, _ := fen.Parse("6k1/5ppp/8/8/8/8/8/3R2K1 w - - 0 1")
+
+	var nodes uint64
+	td := newThreadData()
+	if s := quiesce(nil, td, pos, 1, 0, -Inf, Inf, &nodes); s != Mate-2 {
+		t.Errorf("with quiet checks: expected mate, got %d", s)
+	}
+
+	td.opts.QuiescenceChecks = false
+	if s := quiesce(nil, td, pos, 1, 0, -Inf, Inf, &nodes); isMate(s) {
+		t.Errorf("without quiet checks: unexpected mate score %d", s)
+	}
+}
+
+func TestEngineStop(t *testing.T) {
+	pos, _ := fen.Parse("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	e := NewEngine(1)
+
+	done := make(chan Result)
+	go func() { done <- e.Search(pos, maxDepth, 2, 0) }()
+
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	deadline := time.After(10 * time.Second)
+	for {
+		select {
+		case res := <-done:
+			if res.Move == core.NoMove {
+				t.Error("stopped search returned no move")
+			}
+			e.Stop() // no search running: must not panic
+			return
+		case <-tick.C:
+			e.Stop()
+		case <-deadline:
+			t.Fatal("search did not stop")
+		}
+	}
+}
+
+func TestSearchNoLegalMoves(t *testing.T) {
+	for _, f := range []string{
+		"8/8/8/8/8/5k2/8/5K1q w - - 0 1", // checkmate
+		"7k/5Q2/6K1/8/8/8/8/8 b - - 0 1", // stalemate
+	} {
+		pos, _ := fen.Parse(f)
+		if res := Search(pos, 4, 2, 0); res.Move != core.NoMove {
+			t.Errorf("%s: got move %s with no legal moves", f, res.Move)
+		}
+	}
+}
+
+func TestMateIn(t *testing.T) {
+	for _, tc := range []struct {
+		score, moves int
+		mate         bool
+	}{
+		{Mate - 1, 1, true},
+		{Mate - 5, 3, true},
+		{-Mate + 4, -2, true},
+		{-Mate + MaxMatePly - 2, -(MaxMatePly - 1) / 2, true},
+		{knownWin, 0, false},
+		{0, 0, false},
+	} {
+		if moves, ok := MateIn(tc.score); moves != tc.moves || ok != tc.mate {
+			t.Errorf("MateIn(%d) = %d, %v, want %d, %v", tc.score, moves, ok, tc.moves, tc.mate)
+		}
+	}
+}
+
+// kqkTablebase builds the tables for king and queen or rook against king.
+func kqkTablebase(t *testing.T) *tablebase.Tablebase {
+	t.Helper()
+	tb := tablebase.New()
+	for _, code := range []string{"KQK", "KRK"} {
+		if _, err := tb.Generate(code); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tb
+}
+
+func TestTablebaseAtRoot(t *testing.T) {
+	tb := kqkTablebase(t)
+
+	// the longest KRK mate: 16 moves
+	pos, _ := fen.Parse("8/8/8/8/8/4k3/5R2/K7 w - - 0 1")
+	e := NewEngine(1)
+	e.SetTablebase(tb)
+	res := e.Search(pos, 20, 1, 0)
+	if moves, ok := MateIn(res.Score); !ok || moves != 16 {
+		t.Errorf("score %d, want mate in 16", res.Score)
+	}
+	if res.TBHits == 0 || !isLegal(pos, res.Move) {
+		t.Errorf("move %s, %d tbhits", res.Move, res.TBHits)
+	}
+
+	// turned off, the search runs as usual
+	if err := e.SetParam("Tablebases", 0); err != nil {
+		t.Fatal(err)
+	}
+	if res := e.Search(pos, 3, 1, 0); res.TBHits != 0 {
+		t.Errorf("%d tbhits with tablebases off", res.TBHits)
+	}
+}
+
+func TestTablebaseInTree(t *testing.T) {
+	// taking the rook leaves KQK, mate in at most ten
+	pos, _ := fen.Parse("8/8/8/4k3/8/8/3r4/3QK3 w - - 0 1")
+	e := NewEngine(1)
+	e.SetTablebase(kqkTablebase(t))
+	res := e.Search(pos, 3, 1, 0)
+	if res.TBHits == 0 {
+		t.Error("no tablebase hits")
+	}
+	if moves, ok := MateIn(res.Score); !ok || moves <= 0 || moves > 11 {
+		t.Errorf("%s scored %d, want a mate", res.Move, res.Score)
+	}
+	if res.Move.To() != core.NewSquare(1, 3) {
+		t.Errorf("expected the rook taken, got %s", res.Move)
+	}
+}
+
+func TestBookMove(t *testing.T) {
+	// a one-entry book: 1. d4 from the starting position
+	pos, _ := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, book.Key(pos))
+	binary.Write(&buf, binary.BigEndian, []uint16{uint16(core.NewSquare(3, 3)) | uint16(core.NewSquare(1, 3))<<6, 1})
+	binary.Write(&buf, binary.BigEndian, uint32(0))
+	b, err := book.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEngine(1)
+	e.SetBook(b)
+	res := e.Search(pos, 5, 1, 0)
+	if !res.Book || res.Move.String() != "d2d4" || res.Nodes != 0 {
+		t.Errorf("got %s (book %v, %d nodes), want the book move d2d4", res.Move, res.Book, res.Nodes)
+	}
+
+	// out of the book, or with the book off, the engine searches
+	if res := e.Search(position.MakeMove(pos, res.Move), 2, 1, 0); res.Book || res.Nodes == 0 {
+		t.Error("played a book move out of the book")
+	}
+	if err := e.SetParam("OwnBook", 0); err != nil {
+		t.Fatal(err)
+	}
+	if res := e.Search(pos, 2, 1, 0); res.Book {
+		t.Error("played a book move with OwnBook off")
+	}
+}
+
+func TestPV(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	res := Search(pos, 6, 1, 0)
+	if len(res.PV) < 2 || res.PV[0] != res.Move {
+		t.Fatalf("PV %v for %s", res.PV, res.Move)
+	}
+	for _, m := range res.PV {
+		if !isLegal(pos, m) {
+			t.Fatalf("PV %v: %s is illegal", res.PV, m)
+		}
+		pos = position.MakeMove(pos, m)
+	}
+
+	// nothing follows mate
+	mate, _ := fen.Parse("6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1")
+	if res := Search(mate, 4, 1, 0); len(res.PV) != 1 || res.PV[0].String() != "a1a8" {
+		t.Errorf("back rank mate PV %v", res.PV)
+	}
+}
+
+func TestNodeLimit(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	e := NewEngine(1)
+	e.NodeLimit = 5000
+	res := e.Search(pos, 30, 1, 0)
+	if res.Depth >= 30 || res.Nodes > 2*e.NodeLimit || !isLegal(pos, res.Move) {
+		t.Errorf("depth %d, %d nodes, move %s with a %d node limit", res.Depth, res.Nodes, res.Move, e.NodeLimit)
+	}
+
+	// depth 1 always finishes
+	e.NodeLimit = 1
+	if res := e.Search(pos, 30, 1, 0); res.Depth != 1 || !isLegal(pos, res.Move) {
+		t.Errorf("depth %d, move %s with a 1 node limit", res.Depth, res.Move)
+	}
+}
+
+func TestPVInProgress(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4")
+	Search(pos, 5, 1, 0, func(r Result) {
+		if len(r.PV) == 0 || r.PV[0] != r.Move {
+			t.Errorf("depth %d: PV %v for %s", r.Depth, r.PV, r.Move)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"math/rand/v2"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Skill levels weaken the engine for people to play against, from level 1,
+// the weakest, up to MaxSkillLevel, full strength. Level 0 leaves the
+// engine at full strength too, so that it is what Options start out as.
+// Below MaxSkillLevel a search runs on one
+// thread with limited depth and nodes, blurs its evaluation with noise,
+// and picks its move from the best few rather than always the best, with
+// the odd outright blunder. The lower the level the more of each.
+const MaxSkillLevel = 20
+
+// The Elo range LimitStrength spreads the levels over. The ratings are
+// rough guides, not measured against rated players.
+const (
+	MinElo = 1000
+	MaxElo = 2900
+)
+
+// skillLines is how many of the best moves a weakened search looks at.
+const skillLines = 4
+
+// skillLevel returns the level the options ask for: from Elo when
+// LimitStrength is set, otherwise SkillLevel.
+func (o Options) skillLevel() int {
+	level := o.SkillLevel
+	if o.LimitStrength {
+		level = 1 + (min(max(o.Elo, MinElo), MaxElo)-MinElo)*(MaxSkillLevel-1)/(MaxElo-MinElo)
+	}
+	if level <= 0 {
+		return MaxSkillLevel
+	}
+	return min(level, MaxSkillLevel)
+}
+
+// SkillElo returns the approximate rating of a level.
+func SkillElo(level int) int {
+	return MinElo + (level-1)*(MaxElo-MinElo)/(MaxSkillLevel-1)
+}
+
+// skillDepth is the deepest a search at level goes: 1 ply at level 1, 12
+// at level 19.
+func skillDepth(level int) int {
+	return 1 + level*3/5
+}
+
+// skillNodes is the node budget of a search at level, once depth 1 is done.
+func skillNodes(level int) uint64 {
+	return 500 << (level / 2)
+}
+
+// skillNoise is how far, in centipawns, the evaluation may be off.
+func skillNoise(level int) int {
+	return (MaxSkillLevel - level) * 10
+}
+
+// skillBlunder is the chance of playing a random move.
+func skillBlunder(level int) float64 {
+	w := float64(MaxSkillLevel-level) / MaxSkillLevel
+	return 0.15 * w * w
+}
+
+// evalNoise returns the noise added to the evaluation of the position with
+// the given key. The same position always gets the same noise within a
+// search, so transpositions and re-searches agree.
+func evalNoise(key, seed uint64, amplitude int) int {
+	// splitmix64 finalizer
+	z := key ^ seed
+	z = (z ^ z>>30) * 0xBF58476D1CE4E5B9
+	z = (z ^ z>>27) * 0x94D049BB133111EB
+	z ^= z >> 31
+	return int(z%uint64(2*amplitude+1)) - amplitude
+}
+
+// weaken picks the move a search at level plays from its best lines. Each
+// line's score is pushed up by a random amount that grows with how much
+// worse it is than the best and with the weakness of the level, and the
+// highest pushed score wins, so weak levels often take a lesser move but
+// rarely a much worse one. Now and then it blunders outright instead.
+func weaken(res Result, pos *position.Position, level int) Result {
+	if rand.Float64() < skillBlunder(level) {
+		moves := movegen.LegalMoves(pos)
+		if moves.Count() > 1 {
+			m := moves.Get(rand.IntN(moves.Count()))
+			res.Move = m
+			for _, l := range res.Lines {
+				if l.Move == m {
+					res.Score = l.Score
+				}
+			}
+			return res
+		}
+	}
+	if len(res.Lines) < 2 {
+		return res
+	}
+
+	weakness := 120 - 2*level
+	top := res.Lines[0].Score
+	delta := min(top-res.Lines[len(res.Lines)-1].Score, 100)
+	best, bestScore := res.Lines[0], -Inf
+	for _, l := range res.Lines {
+		push := (weakness*(top-l.Score) + delta*rand.IntN(weakness)) / 128
+		if l.Score+push > bestScore {
+			best, bestScore = l, l.Score+push
+		}
+	}
+	res.Move = best.Move
+	res.Score = best.Score
+	return res
+}
+
+// Line is one of the best moves at the root with its score.
+type Line struct {
+	Move  core.Move
+	Score int
+}
+
+// topLines returns the first k moves of a root move list sorted by score.
+func topLines(moves []core.Move, scores []int, k int) []Line {
+	lines := make([]Line, min(k, len(moves)))
+	for i := range lines {
+		lines[i] = Line{moves[i], scores[i]}
+	}
+	return lines
+}
+
+// kthBest returns the k-th highest score, or -Inf if there are fewer than k.
+func kthBest(scores []int, k int) int {
+	if len(scores) < k {
+		return -Inf
+	}
+	top := make([]int, 0, k+1)
+	for _, s := range scores {
+		i := len(top)
+		for i > 0 && top[i-1] < s {
+			i--
+		}
+		if i < k {
+			top = append(top, 0)
+			copy(top[i+1:], top[i:])
+			top[i] = s
+			top = top[:min(len(top), k)]
+		}
+	}
+	return top[k-1]
+}
@@ -0,0 +1,122 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+func TestSkillLevelFromElo(t *testing.T) {
+	opts := DefaultOptions()
+	if got := opts.skillLevel(); got != MaxSkillLevel {
+		t.Errorf("default level %d, want %d", got, MaxSkillLevel)
+	}
+	opts.SkillLevel = 7
+	if got := opts.skillLevel(); got != 7 {
+		t.Errorf("level %d, want 7", got)
+	}
+
+	opts.LimitStrength = true
+	for _, tc := range []struct{ elo, level int }{
+		{MinElo, 1}, {MinElo - 500, 1}, {1900, 10}, {MaxElo, MaxSkillLevel}, {MaxElo + 100, MaxSkillLevel},
+	} {
+		opts.Elo = tc.elo
+		if got := opts.skillLevel(); got != tc.level {
+			t.Errorf("Elo %d: level %d, want %d", tc.elo, got, tc.level)
+		}
+	}
+	if SkillElo(10) != 1900 {
+		t.Errorf("SkillElo(10) = %d", SkillElo(10))
+	}
+	if got := (Options{}).skillLevel(); got != MaxSkillLevel {
+		t.Errorf("zero options play at level %d", got)
+	}
+}
+
+func TestKthBest(t *testing.T) {
+	scores := []int{5, -3, 40, 12, 12, 7}
+	for k, want := range map[int]int{1: 40, 2: 12, 3: 12, 4: 7, 6: -3, 7: -Inf} {
+		if got := kthBest(scores, k); got != want {
+			t.Errorf("kthBest(%d) = %d, want %d", k, got, want)
+		}
+	}
+}
+
+func TestEvalNoise(t *testing.T) {
+	for key := uint64(0); key < 1000; key++ {
+		n := evalNoise(key*0x9E3779B97F4A7C15, 42, 50)
+		if n < -50 || n > 50 {
+			t.Fatalf("noise %d out of range", n)
+		}
+		if n != evalNoise(key*0x9E3779B97F4A7C15, 42, 50) {
+			t.Fatal("noise changed for the same position")
+		}
+	}
+}
+
+func TestMultiPV(t *testing.T) {
+	// the knight can take the queen, the rook or the bishop
+	pos, _ := fen.Parse("6k1/8/1q3r2/8/2N3b1/8/8/6K1 w - - 0 1")
+	e := NewEngine(1)
+	if err := e.SetParam("MultiPV", 3); err != nil {
+		t.Fatal(err)
+	}
+	res := e.Search(pos, 4, 1, 0)
+	if len(res.Lines) != 3 {
+		t.Fatalf("%d lines, want 3", len(res.Lines))
+	}
+	if res.Lines[0].Move != res.Move || res.Lines[0].Score != res.Score {
+		t.Errorf("first line %v doesn't match the result %s %d", res.Lines[0], res.Move, res.Score)
+	}
+	for i := 1; i < len(res.Lines); i++ {
+		if res.Lines[i].Score > res.Lines[i-1].Score {
+			t.Errorf("lines out of order: %v", res.Lines)
+		}
+	}
+	if res.Lines[0].Move.String() != "c4b6" || res.Lines[0].Score-res.Lines[2].Score < 300 {
+		t.Errorf("lines %v, want the queen taken first and well ahead of the third", res.Lines)
+	}
+
+	// one line by default
+	e = NewEngine(1)
+	if res := e.Search(pos, 4, 1, 0); res.Lines != nil {
+		t.Errorf("lines %v without MultiPV", res.Lines)
+	}
+}
+
+func TestWeakSkill(t *testing.T) {
+	pos, _ := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	e := NewEngine(1)
+	if err := e.SetParam("SkillLevel", 1); err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		res := e.Search(pos, 10, 4, 0)
+		if res.Depth > skillDepth(1) {
+			t.Fatalf("searched depth %d at level 1", res.Depth)
+		}
+		if !isLegal(pos, res.Move) {
+			t.Fatalf("illegal move %s", res.Move)
+		}
+		seen[res.Move.String()] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("level 1 always played %v", seen)
+	}
+
+	// a weak engine still takes a free queen most of the time
+	free, _ := fen.Parse("6k1/8/8/3q4/8/8/3R4/6K1 w - - 0 1")
+	if err := e.SetParam("SkillLevel", 10); err != nil {
+		t.Fatal(err)
+	}
+	took := 0
+	for i := 0; i < 20; i++ {
+		if e.Search(free, 10, 1, 0).Move.String() == "d2d5" {
+			took++
+		}
+	}
+	if took < 12 {
+		t.Errorf("level 10 took the queen %d times in 20", took)
+	}
+}
@@ -0,0 +1,165 @@
+package search
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
+)
+
+// Lazy SMP: every thread searches the same root, sharing only the
+// transposition table. Helper threads skip some depths so that at any moment
+// they are spread ahead of and behind the main thread, filling the TT with
+// entries it will want instead of repeating its work.
+//
+// Thread i (i >= 1) skips depth d when ((d + skipPhase[j]) / skipSize[j]) is
+// odd, where j = (i-1) mod 20.
+var (
+	skipSize  = [20]int{1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 3, 3, 4, 4, 4, 4, 4, 4, 4, 4}
+	skipPhase = [20]int{0, 1, 0, 1, 2, 3, 0, 1, 2, 3, 4, 5, 0, 1, 2, 3, 4, 5, 6, 7}
+)
+
+// skipDepth reports whether a thread should skip an iteration. The main
+// thread never skips.
+func skipDepth(thread, depth int) bool {
+	if thread == 0 {
+		return false
+	}
+	i := (thread - 1) % len(skipSize)
+	return (depth+skipPhase[i])/skipSize[i]%2 != 0
+}
+
+// sharedSearch is the state every thread of one search can see.
+type sharedSearch struct {
+	tt   *TT
+	opts Options
+	eval evalParams
+	lmr  *lmrTable
+	net  *Network             // nil for the handcrafted evaluation
+	tb   *tablebase.Tablebase // nil without tablebases
+	stop atomic.Bool
+
+	// the position searched, to follow the PV of each reported iteration
+	root *position.Position
+
+	// keys of the game's positions before the root, see threadData
+	gameKeys []uint64
+
+	// node budget and skill level weakening, see threadData
+	nodeLimit uint64
+	noise     int
+	noiseSeed uint64
+
+	// nodes searched and tablebase hits per thread, published after each
+	// iteration
+	nodes  []atomic.Uint64
+	tbHits []atomic.Uint64
+
+	mu      sync.Mutex
+	best    Result // deepest iteration completed by any thread
+	onDepth func(Result)
+}
+
+func newSharedSearch(tt *TT, opts Options, eval evalParams, threads int, onDepth func(Result)) *sharedSearch {
+	return &sharedSearch{
+		tt:      tt,
+		opts:    opts,
+		eval:    eval,
+		lmr:     newLMRTable(opts),
+		nodes:   make([]atomic.Uint64, threads),
+		tbHits:  make([]atomic.Uint64, threads),
+		onDepth: onDepth,
+	}
+}
+
+func (s *sharedSearch) totalNodes() uint64 {
+	return sum(s.nodes)
+}
+
+func (s *sharedSearch) totalTBHits() uint64 {
+	return sum(s.tbHits)
+}
+
+func sum(counts []atomic.Uint64) uint64 {
+	var total uint64
+	for i := range counts {
+		total += counts[i].Load()
+	}
+	return total
+}
+
+// report publishes a completed iteration from one thread. Whichever thread
+// first completes a new depth becomes the global best and is passed to the
+// onDepth callback, with node counts summed over all threads and its PV.
+func (s *sharedSearch) report(thread int, r Result) {
+	s.nodes[thread].Store(r.Nodes)
+	s.tbHits[thread].Store(r.TBHits)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Depth <= s.best.Depth {
+		return
+	}
+	r.Nodes = s.totalNodes()
+	r.TBHits = s.totalTBHits()
+	r.Hashfull = s.tt.Hashfull()
+	s.best = r
+	if s.onDepth != nil {
+		if s.root != nil && r.Move != core.NoMove {
+			r.PV = s.tt.line(s.root, r.Move, maxPVLength)
+		}
+		s.onDepth(r)
+	}
+}
+
+// voteResult picks the final result from every thread's last completed
+// iteration. Each thread votes for its move with a weight that grows with
+// its depth and with its score above the worst thread's, so a deep thread
+// outweighs a shallow one but several agreeing threads can outvote a single
+// slightly deeper one. The result is the deepest iteration that played the
+// winning move, with that iteration's depth, score and lines. Node counts
+// and tablebase hits are summed over all threads.
+func voteResult(results []Result) Result {
+	var nodes, tbHits uint64
+	minScore := Inf
+	for _, r := range results {
+		nodes += r.Nodes
+		tbHits += r.TBHits
+		if r.Depth > 0 {
+			minScore = min(minScore, r.Score)
+		}
+	}
+
+	votes := make(map[core.Move]int)
+	for _, r := range results {
+		if r.Depth > 0 {
+			votes[r.Move] += (r.Score - minScore + 14) * r.Depth
+		}
+	}
+
+	best := 0
+	for i, r := range results {
+		if r.Depth == 0 {
+			continue
+		}
+		b := results[best]
+		if b.Depth == 0 || votes[r.Move] > votes[b.Move] ||
+			(votes[r.Move] == votes[b.Move] && r.Depth > b.Depth) {
+			best = i
+		}
+	}
+
+	// the deepest thread that played the move speaks for it
+	for i, r := range results {
+		if r.Move == results[best].Move && r.Depth > results[best].Depth {
+			best = i
+		}
+	}
+
+	res := results[best]
+	res.Nodes = nodes
+	res.TBHits = tbHits
+	return res
+}
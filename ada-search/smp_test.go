@@ -0,0 +1,118 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+func TestSkipDepth(t *testing.T) {
+	for d := 1; d <= 20; d++ {
+		if skipDepth(0, d) {
+			t.Fatalf("main thread skipped depth %d", d)
+		}
+	}
+
+	// helpers skip some depths but not all, and not all the same ones
+	patterns := make(map[string]bool)
+	for thread := 1; thread <= 8; thread++ {
+		pattern := ""
+		for d := 1; d <= 12; d++ {
+			if skipDepth(thread, d) {
+				pattern += "x"
+			} else {
+				pattern += "."
+			}
+		}
+		if pattern == "............" || pattern == "xxxxxxxxxxxx" {
+			t.Errorf("thread %d has degenerate skip pattern %s", thread, pattern)
+		}
+		patterns[pattern] = true
+	}
+	if len(patterns) < 4 {
+		t.Errorf("helpers should be staggered, got %d distinct patterns", len(patterns))
+	}
+}
+
+func TestVoteResult(t *testing.T) {
+	e4 := core.NewMove(core.NewSquare(1, 4), core.NewSquare(3, 4))
+	d4 := core.NewMove(core.NewSquare(1, 3), core.NewSquare(3, 3))
+
+	// three threads agreeing at depth 9 outvote one thread at depth 10
+	res := voteResult([]Result{
+		{Move: d4, Score: 30, Depth: 10, Nodes: 100},
+		{Move: e4, Score: 25, Depth: 9, Nodes: 100},
+		{Move: e4, Score: 25, Depth: 9, Nodes: 100},
+		{Move: e4, Score: 25, Depth: 9, Nodes: 100},
+		{Nodes: 50}, // a helper that never finished an iteration
+	})
+	if res.Move != e4 {
+		t.Errorf("expected majority move %s, got %s", e4, res.Move)
+	}
+	if res.Depth != 9 || res.Score != 25 {
+		t.Errorf("expected the majority's depth 9 and score 25, got %d and %d", res.Depth, res.Score)
+	}
+	if res.Nodes != 450 {
+		t.Errorf("expected nodes summed over threads, got %d", res.Nodes)
+	}
+
+	// a much better score from the deepest thread wins on its own
+	res = voteResult([]Result{
+		{Move: d4, Score: 400, Depth: 10},
+		{Move: e4, Score: 20, Depth: 9},
+		{Move: e4, Score: 20, Depth: 9},
+	})
+	if res.Move != d4 {
+		t.Errorf("expected %s, got %s", d4, res.Move)
+	}
+}
+
+func TestVoteResultDeepestForMove(t *testing.T) {
+	e4 := core.NewMove(core.NewSquare(1, 4), core.NewSquare(3, 4))
+	d4 := core.NewMove(core.NewSquare(1, 3), core.NewSquare(3, 3))
+	e5 := core.NewMove(core.NewSquare(6, 4), core.NewSquare(4, 4))
+	d5 := core.NewMove(core.NewSquare(6, 3), core.NewSquare(4, 3))
+
+	// a helper that skipped the last depth carries the vote; the result is
+	// the deepest iteration that played its move, depth, score and PV
+	// alike
+	res := voteResult([]Result{
+		{Move: d4, Score: 10, Depth: 8, PV: []core.Move{d4, d5}},
+		{Move: e4, Score: 60, Depth: 7, PV: []core.Move{e4, e5}},
+		{Move: e4, Score: 40, Depth: 6, PV: []core.Move{e4, d5}},
+		{Move: d4, Score: -30, Depth: 7, PV: []core.Move{d4, e5}},
+	})
+	if res.Move != e4 || res.Score != 60 || res.Depth != 7 || len(res.PV) != 2 || res.PV[1] != e5 {
+		t.Errorf("got %s score %d depth %d PV %v, want %s score 60 depth 7 PV e4 e5", res.Move, res.Score, res.Depth, res.PV, e4)
+	}
+}
+
+func TestSearchThreadsAggregateNodes(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkbnr/pppppppp/2n5/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2")
+
+	lastDepth := 0
+	res := NewEngine(4).Search(pos, 5, 4, 0, func(r Result) {
+		if r.Depth <= lastDepth {
+			t.Errorf("reported depth %d after depth %d", r.Depth, lastDepth)
+		}
+		lastDepth = r.Depth
+	})
+	if res.Move == core.NoMove {
+		t.Fatal("expected a move")
+	}
+	// the result is whichever iteration the vote chose, which a helper
+	// that skipped depths may have searched less deeply
+	if res.Depth < 1 || res.Depth > 5 || len(res.PV) == 0 || res.PV[0] != res.Move {
+		t.Errorf("depth %d, PV %v for %s", res.Depth, res.PV, res.Move)
+	}
+	if lastDepth != 5 {
+		t.Errorf("last reported depth %d, want 5", lastDepth)
+	}
+
+	single := NewEngine(4).Search(pos, 5, 1, 0)
+	t.Logf("1 thread: %d nodes, 4 threads: %d nodes", single.Nodes, res.Nodes)
+	if res.Nodes <= single.Nodes/2 {
+		t.Errorf("4-thread node count %d doesn't include helper threads", res.Nodes)
+	}
+}
@@ -1,7 +1,11 @@
 package search
 
 import (
+	"sync/atomic"
+
 	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
 )
 
 // represents bound type
@@ -21,25 +25,102 @@ type TTEntry struct {
 	Flag  SearchFlag
 }
 
+// DefaultHashMB is the transposition table size used when none is configured.
+const DefaultHashMB = 64
+
+// bucketSize is the number of entries sharing one index. Each slot is two
+// uint64 words, so a bucket is 64 bytes and fits a single cache line: a probe
+// touches one line no matter which slot holds the position.
+const bucketSize = 4
+
+// ttSlot is one entry as stored in the table. The key word holds the zobrist
+// key xor'd with the data word, so a torn write from another thread shows up
+// as a key mismatch instead of a corrupt hit.
+type ttSlot struct {
+	key  uint64
+	data uint64
+}
+
+type ttBucket [bucketSize]ttSlot
+
+// data word layout:
+//
+//	bits  0-15: move
+//	bits 16-23: depth
+//	bits 24-39: score
+//	bits 40-41: flag
+//	bit     42: slot in use
+//	bits 48-55: generation
+const (
+	usedBit  = uint64(1) << 42
+	genShift = 48
+)
+
 // the transposition table
 type TT struct {
-	entries []TTEntry
-	mask    uint64       // size - 1
+	buckets    []ttBucket
+	mask       uint64 // len(buckets) - 1
+	generation atomic.Uint32
 }
 
-func NewTT(size int) *TT {
-	for size&(size-1) != 0 {
-		size &= size - 1
+// NewTT allocates a table of roughly sizeMB megabytes. The bucket count is
+// rounded down to a power of two so indexing is a mask.
+func NewTT(sizeMB int) *TT {
+	count := max(1, sizeMB*1024*1024/64)
+	for count&(count-1) != 0 {
+		count &= count - 1
 	}
 
 	return &TT{
-		entries: make([]TTEntry, size),
-		mask:    uint64(size - 1),
+		buckets: make([]ttBucket, count),
+		mask:    uint64(count - 1),
+	}
+}
+
+func packData(e TTEntry, gen uint8) uint64 {
+	return uint64(e.Move) |
+		uint64(uint8(e.Depth))<<16 |
+		uint64(uint16(e.Score))<<24 |
+		uint64(e.Flag&3)<<40 |
+		usedBit |
+		uint64(gen)<<genShift
+}
+
+func unpackData(key, data uint64) TTEntry {
+	return TTEntry{
+		Key:   key,
+		Move:  core.Move(data),
+		Depth: int8(data >> 16),
+		Score: int16(data >> 24),
+		Flag:  SearchFlag(data>>40) & 3,
 	}
 }
 
-func packData(e TTEntry) uint64 {
-	return uint64(e.Move) | uint64(e.Depth)<<16 | uint64(e.Score) << 24 | uint64(e.Flag)<<40
+func dataGen(data uint64) uint8 {
+	return uint8(data >> genShift)
+}
+
+// NewSearch advances the generation so entries from earlier searches age out
+// ahead of fresh ones.
+func (tt *TT) NewSearch() {
+	if tt == nil {
+		return
+	}
+	tt.generation.Add(1)
+}
+
+func (tt *TT) gen() uint8 {
+	return uint8(tt.generation.Load())
+}
+
+// Clear empties every bucket and resets the generation. It must not run
+// while a search is using the table.
+func (tt *TT) Clear() {
+	if tt == nil {
+		return
+	}
+	clear(tt.buckets)
+	tt.generation.Store(0)
 }
 
 // check the transposition table
@@ -47,21 +128,117 @@ func (tt *TT) Probe(key uint64) (TTEntry, bool) {
 	if tt == nil {
 		return TTEntry{}, false
 	}
-	entry := tt.entries[key&tt.mask]
-	entry.Key ^= packData(entry)
-	if entry.Key == key {
-		return entry, true
+	bucket := &tt.buckets[key&tt.mask]
+	for i := range bucket {
+		data := atomic.LoadUint64(&bucket[i].data)
+		k := atomic.LoadUint64(&bucket[i].key)
+		if data&usedBit != 0 && k^data == key {
+			return unpackData(key, data), true
+		}
 	}
 	return TTEntry{}, false
 }
 
 // add to the transposition table
+//
+// A slot already holding the same position is updated in place unless the
+// new entry is a shallower bound from the current search. Otherwise the slot
+// with the lowest depth, discounted by how many searches old it is, is
+// replaced.
 func (tt *TT) Store(entry TTEntry) {
 	if tt == nil {
 		return
 	}
-	idx        := entry.Key & tt.mask
-	packed     := entry
-	packed.Key ^= packData(entry)
-	tt.entries[idx] = packed
+	bucket := &tt.buckets[entry.Key&tt.mask]
+	gen := tt.gen()
+
+	replace := -1
+	worst := 0
+	for i := range bucket {
+		data := atomic.LoadUint64(&bucket[i].data)
+		k := atomic.LoadUint64(&bucket[i].key)
+
+		// slots fill in order and are never freed individually, so
+		// nothing past an empty slot can match
+		if data&usedBit == 0 {
+			replace = i
+			break
+		}
+
+		if k^data == entry.Key {
+			old := unpackData(k^data, data)
+			if entry.Move == core.NoMove {
+				entry.Move = old.Move
+			}
+			if entry.Flag != Exact && dataGen(data) == gen && int(entry.Depth)+2 < int(old.Depth) {
+				return
+			}
+			replace = i
+			break
+		}
+
+		age := int(uint8(gen - dataGen(data)))
+		value := int(int8(data>>16)) - 8*age
+		if replace < 0 || value < worst {
+			replace = i
+			worst = value
+		}
+	}
+
+	data := packData(entry, gen)
+	atomic.StoreUint64(&bucket[replace].data, data)
+	atomic.StoreUint64(&bucket[replace].key, entry.Key^data)
+}
+
+// maxPVLength bounds the lines read back from the table.
+const maxPVLength = 32
+
+// line follows the best moves stored in the table from the position after
+// first, which starts the line. It stops at a position the table doesn't
+// have, a stored move that isn't legal, or a repetition.
+func (tt *TT) line(pos *position.Position, first core.Move, maxLen int) []core.Move {
+	line := []core.Move{first}
+	seen := map[uint64]bool{pos.Zobrist: true}
+	pos = position.MakeMove(pos, first)
+	for len(line) < maxLen && !seen[pos.Zobrist] {
+		seen[pos.Zobrist] = true
+		entry, ok := tt.Probe(pos.Zobrist)
+		if !ok || entry.Move == core.NoMove || !isLegalMove(pos, entry.Move) {
+			break
+		}
+		line = append(line, entry.Move)
+		pos = position.MakeMove(pos, entry.Move)
+	}
+	return line
+}
+
+// isLegalMove reports whether m is one of the legal moves in pos.
+func isLegalMove(pos *position.Position, m core.Move) bool {
+	moves := movegen.LegalMoves(pos)
+	for i := 0; i < moves.Count(); i++ {
+		if moves.Get(i) == m {
+			return true
+		}
+	}
+	return false
+}
+
+// Hashfull estimates how full the table is in permille, counting only
+// entries written during the current search.
+func (tt *TT) Hashfull() int {
+	if tt == nil {
+		return 0
+	}
+	gen := tt.gen()
+	sample := min(len(tt.buckets), 1000/bucketSize)
+	used := 0
+	for b := 0; b < sample; b++ {
+		for i := range tt.buckets[b] {
+			data := atomic.LoadUint64(&tt.buckets[b][i].data)
+			if data&usedBit != 0 && dataGen(data) == gen {
+				used++
+			}
+		}
+	}
+	return used * 1000 / (sample * bucketSize)
 }
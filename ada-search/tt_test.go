@@ -1,6 +1,7 @@
 package search
 
 import (
+	"sync"
 	"sync/atomic"
 	"testing"
 
@@ -11,7 +12,7 @@ import (
 )
 
 func TestTTStoreProbe(t *testing.T) {
-	tt := NewTT(1024)
+	tt := NewTT(1)
 	entry := TTEntry{
 		Key:   0xDEADBEEF,
 		Move:  core.NewMove(core.NewSquare(1, 4), core.NewSquare(3, 4)),
@@ -31,7 +32,7 @@ func TestTTStoreProbe(t *testing.T) {
 }
 
 func TestTTProbeMiss(t *testing.T) {
-	tt := NewTT(1024)
+	tt := NewTT(1)
 	tt.Store(TTEntry{
 		Key:   0xDEADBEEF,
 		Depth: 5,
@@ -46,7 +47,7 @@ func TestTTProbeMiss(t *testing.T) {
 }
 
 func TestTTOverwrite(t *testing.T) {
-	tt := NewTT(1024)
+	tt := NewTT(1)
 	tt.Store(TTEntry{Key: 0xDEADBEEF, Depth: 3, Score: 10, Flag: Exact})
 	tt.Store(TTEntry{Key: 0xDEADBEEF, Depth: 5, Score: 20, Flag: LowerBound})
 
@@ -91,7 +92,7 @@ func TestTTTransposition(t *testing.T) {
 	p2 = position.MakeMove(p2, core.NewMove(d7, d6))
 	p2 = position.MakeMove(p2, core.NewMove(e2, e3))
 
-	tt := NewTT(1024)
+	tt := NewTT(1)
 	entry := TTEntry{
 		Key:   p1.Zobrist,
 		Move:  core.NewMove(e3, e3), // dummy move
@@ -158,14 +159,14 @@ func benchmarkSearch(b *testing.B, pos *position.Position, depth int, tt *TT) {
 		for i := 0; i < moves.Count(); i++ {
 			child := position.MakeMove(pos, moves.Get(i))
 			nodes++
-			alphabeta(tt, &killers{}, &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodes)
+			alphabeta(tt, newThreadData(), &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodes)
 		}
 	}
 }
 
 func BenchmarkSearchWithTT(b *testing.B) {
 	pos, _ := fen.Parse("r1bqkbnr/pppppppp/2n5/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2")
-	tt := NewTT(1 << 22)
+	tt := NewTT(DefaultHashMB)
 	benchmarkSearch(b, pos, 5, tt)
 }
 
@@ -208,14 +209,14 @@ func TestTTAndNoTTSameResult(t *testing.T) {
 
 	// Search with TT
 	var nodesWithTT uint64
-	tt := NewTT(1 << 22)
+	tt := NewTT(DefaultHashMB)
 	moves := movegen.LegalMoves(pos)
 	bestWithTT := core.NoMove
 	bestScoreWithTT := -Inf
 	for i := 0; i < moves.Count(); i++ {
 		child := position.MakeMove(pos, moves.Get(i))
 		nodesWithTT++
-		score := -alphabeta(tt, &killers{}, &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodesWithTT)
+		score := -alphabeta(tt, newThreadData(), &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodesWithTT)
 		if score > bestScoreWithTT {
 			bestScoreWithTT = score
 			bestWithTT = moves.Get(i)
@@ -229,7 +230,7 @@ func TestTTAndNoTTSameResult(t *testing.T) {
 	for i := 0; i < moves.Count(); i++ {
 		child := position.MakeMove(pos, moves.Get(i))
 		nodesWithoutTT++
-		score := -alphabeta(nil, &killers{}, &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodesWithoutTT)
+		score := -alphabeta(nil, newThreadData(), &atomic.Bool{}, child, 1, depth-1, -Inf, Inf, &nodesWithoutTT)
 		if score > bestScoreWithoutTT {
 			bestScoreWithoutTT = score
 			bestWithoutTT = moves.Get(i)
@@ -266,3 +267,169 @@ func TestMateScoreAdjustment(t *testing.T) {
 		})
 	}
 }
+
+func TestTTBucketKeepsColliders(t *testing.T) {
+	tt := NewTT(1)
+	stride := tt.mask + 1
+
+	// every key lands in bucket 7
+	for i := uint64(0); i < bucketSize; i++ {
+		tt.Store(TTEntry{Key: 7 + i*stride, Depth: int8(i + 1), Score: int16(i), Flag: Exact})
+	}
+	for i := uint64(0); i < bucketSize; i++ {
+		got, ok := tt.Probe(7 + i*stride)
+		if !ok || got.Score != int16(i) {
+			t.Fatalf("collider %d lost: ok=%v entry=%+v", i, ok, got)
+		}
+	}
+}
+
+func TestTTReplacesShallowest(t *testing.T) {
+	tt := NewTT(1)
+	stride := tt.mask + 1
+
+	depths := []int8{6, 2, 9, 5}
+	for i, d := range depths {
+		tt.Store(TTEntry{Key: 3 + uint64(i)*stride, Depth: d, Flag: Exact})
+	}
+	tt.Store(TTEntry{Key: 3 + 10*stride, Depth: 4, Flag: Exact})
+
+	if _, ok := tt.Probe(3 + 1*stride); ok {
+		t.Error("depth 2 entry should have been replaced")
+	}
+	for _, i := range []uint64{0, 2, 3, 10} {
+		if _, ok := tt.Probe(3 + i*stride); !ok {
+			t.Errorf("entry %d should still be present", i)
+		}
+	}
+}
+
+func TestTTAgingPrefersNewSearch(t *testing.T) {
+	tt := NewTT(1)
+	stride := tt.mask + 1
+
+	for i := uint64(0); i < bucketSize; i++ {
+		tt.Store(TTEntry{Key: 5 + i*stride, Depth: 10, Flag: Exact})
+	}
+
+	// a few searches later the deep entries are stale
+	tt.NewSearch()
+	tt.NewSearch()
+	tt.Store(TTEntry{Key: 5 + 20*stride, Depth: 1, Flag: Exact})
+
+	if _, ok := tt.Probe(5 + 20*stride); !ok {
+		t.Fatal("fresh shallow entry should replace stale deep entry")
+	}
+}
+
+func TestTTKeepsDeeperSameKey(t *testing.T) {
+	tt := NewTT(1)
+	move := core.NewMove(core.NewSquare(1, 4), core.NewSquare(3, 4))
+	tt.Store(TTEntry{Key: 0xABCD, Move: move, Depth: 8, Score: 30, Flag: LowerBound})
+	tt.Store(TTEntry{Key: 0xABCD, Depth: 1, Score: -5, Flag: UpperBound})
+
+	got, _ := tt.Probe(0xABCD)
+	if got.Depth != 8 || got.Score != 30 {
+		t.Fatalf("shallow bound overwrote deep entry: %+v", got)
+	}
+
+	// a move-less update keeps the stored move
+	tt.Store(TTEntry{Key: 0xABCD, Depth: 9, Score: 12, Flag: Exact})
+	got, _ = tt.Probe(0xABCD)
+	if got.Move != move || got.Depth != 9 {
+		t.Fatalf("expected depth 9 entry keeping move %s, got %+v", move, got)
+	}
+}
+
+func TestTTHashfull(t *testing.T) {
+	tt := NewTT(1)
+	if hf := tt.Hashfull(); hf != 0 {
+		t.Fatalf("empty table hashfull = %d", hf)
+	}
+
+	for key := uint64(0); key <= tt.mask*bucketSize; key++ {
+		tt.Store(TTEntry{Key: key, Depth: 1, Flag: Exact})
+	}
+	if hf := tt.Hashfull(); hf != 1000 {
+		t.Errorf("full table hashfull = %d, want 1000", hf)
+	}
+
+	// old entries don't count towards the new search
+	tt.NewSearch()
+	if hf := tt.Hashfull(); hf != 0 {
+		t.Errorf("hashfull after new search = %d, want 0", hf)
+	}
+
+	tt.Clear()
+	if _, ok := tt.Probe(1); ok {
+		t.Error("expected miss after clear")
+	}
+}
+
+func TestTTSizeInMB(t *testing.T) {
+	tt := NewTT(2)
+	if got := len(tt.buckets) * 64; got != 2*1024*1024 {
+		t.Errorf("2MB table uses %d bytes", got)
+	}
+	// non power of two sizes round down
+	tt = NewTT(3)
+	if got := len(tt.buckets) * 64; got != 2*1024*1024 {
+		t.Errorf("3MB table uses %d bytes, want 2MB", got)
+	}
+}
+
+func TestTTConcurrentAccess(t *testing.T) {
+	tt := NewTT(1)
+	const (
+		workers = 8
+		keys    = 1 << 14
+	)
+
+	// each key's payload is derived from the key, so any torn entry
+	// returned as a hit shows up as a mismatch
+	payload := func(key uint64) TTEntry {
+		return TTEntry{
+			Key:   key,
+			Move:  core.Move(key * 31),
+			Depth: int8(key % 32),
+			Score: int16(key * 7),
+			Flag:  SearchFlag(key % 3),
+		}
+	}
+
+	var wg sync.WaitGroup
+	var bad atomic.Int64
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed uint64) {
+			defer wg.Done()
+			for i := uint64(0); i < keys; i++ {
+				key := (i*0x9E3779B97F4A7C15 + seed) | 1
+				tt.Store(payload(key))
+				if got, ok := tt.Probe(key ^ 0x5555); ok && got != payload(key^0x5555) {
+					bad.Add(1)
+				}
+				if got, ok := tt.Probe(key); ok && got.Move != payload(key).Move {
+					bad.Add(1)
+				}
+			}
+		}(uint64(w) * 0x1234567)
+	}
+	wg.Wait()
+
+	if n := bad.Load(); n != 0 {
+		t.Fatalf("%d probes returned corrupt entries", n)
+	}
+}
+
+func TestSearchSharedTTAcrossThreads(t *testing.T) {
+	pos, _ := fen.Parse("r1bqkbnr/pppppppp/2n5/8/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 2 2")
+	e := NewEngine(1)
+	res := e.Search(pos, 4, 4, 0)
+	if res.Move == core.NoMove {
+		t.Fatal("expected a move")
+	}
+	if res.Hashfull <= 0 {
+		t.Errorf("expected a populated table, hashfull = %d", res.Hashfull)
+	}
+}
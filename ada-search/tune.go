@@ -0,0 +1,348 @@
+package search
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Texel tuning: the evaluation, squashed through a sigmoid, is read as the
+// expected result of the game from a position. The tuner fits the sigmoid's
+// scale to a set of labelled positions, then nudges the evaluation weights to
+// minimize the mean squared difference between prediction and label.
+
+// TuneSample is a position labelled with how things went for white: 1 for a
+// win, 0.5 for a draw and 0 for a loss, or an engine score squashed into the
+// same range.
+type TuneSample struct {
+	Pos    *position.Position
+	Result float64
+}
+
+// ReadTuneSamples reads one labelled position per line: a FEN (the move
+// counters may be left out) followed by a label, optionally after a ';', '|'
+// or ',' and an EPD c9 opcode. Labels are a game result (1-0, 0-1, 1/2-1/2,
+// "1-0", [1.0], 0.5, ...) or an engine score from white's point of view
+// written "cp <n>". Blank lines and lines starting with '#' are skipped.
+func ReadTuneSamples(r io.Reader) ([]TuneSample, error) {
+	var samples []TuneSample
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		s, err := parseTuneSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, s)
+	}
+	return samples, sc.Err()
+}
+
+func parseTuneSample(text string) (TuneSample, error) {
+	fields := strings.Fields(strings.NewReplacer(";", " ", "|", " ", ",", " ").Replace(text))
+	if len(fields) < 5 {
+		return TuneSample{}, fmt.Errorf("want a FEN and a label: %q", text)
+	}
+
+	var result float64
+	n := len(fields)
+	if n >= 2 && fields[n-2] == "cp" {
+		cp, err := strconv.Atoi(fields[n-1])
+		if err != nil {
+			return TuneSample{}, fmt.Errorf("bad score %q", fields[n-1])
+		}
+		result = sigmoid(1, float64(cp))
+		n -= 2
+	} else {
+		var ok bool
+		if result, ok = parseResult(fields[n-1]); !ok {
+			return TuneSample{}, fmt.Errorf("bad label %q", fields[n-1])
+		}
+		n--
+	}
+	if n > 0 && fields[n-1] == "c9" {
+		n--
+	}
+
+	fenFields := fields[:n]
+	if len(fenFields) == 4 {
+		fenFields = append(fenFields, "0", "1")
+	}
+	pos, err := fen.Parse(strings.Join(fenFields, " "))
+	if err != nil {
+		return TuneSample{}, err
+	}
+	return TuneSample{Pos: pos, Result: result}, nil
+}
+
+// parseResult reads a game result label. Bare numbers need a decimal point
+// so they can't be mistaken for the FEN's move counters.
+func parseResult(s string) (float64, bool) {
+	quoted := s != strings.Trim(s, `"[]`)
+	s = strings.Trim(s, `"[]`)
+	switch s {
+	case "1-0":
+		return 1, true
+	case "0-1":
+		return 0, true
+	case "1/2-1/2", "½-½":
+		return 0.5, true
+	}
+	if !quoted && !strings.Contains(s, ".") {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 || v > 1 {
+		return 0, false
+	}
+	return v, true
+}
+
+// sigmoid turns a centipawn score into an expected result, scaled by k.
+func sigmoid(k, cp float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*cp/400))
+}
+
+// maxLeafPly bounds how far quietLeaf follows captures.
+const maxLeafPly = 16
+
+// quietLeaf runs a plain quiescence search — captures, or every evasion in
+// check — and returns its score with the position at the end of the
+// principal variation, where the static evaluation can be trusted. The leaf
+// is nil when the line ends in mate.
+func (p *evalParams) quietLeaf(pos *position.Position, alpha, beta, ply int) (int, *position.Position) {
+	if ply >= maxLeafPly {
+		return p.evaluate(pos, nil), pos
+	}
+
+	var moves core.MoveList
+	best := -Inf
+	if movegen.InCheck(pos) {
+		moves = movegen.LegalMoves(pos)
+		if moves.Count() == 0 {
+			return -Mate, nil
+		}
+	} else {
+		best = p.evaluate(pos, nil)
+		if best >= beta {
+			return best, pos
+		}
+		moves = movegen.LegalCaptures(pos)
+	}
+	alpha = max(alpha, best)
+
+	n := moves.Count()
+	var scores [maxMoves]int
+	for i := 0; i < n; i++ {
+		scores[i] = p.mvvlva(pos, moves.Get(i))
+	}
+
+	leaf := pos
+	for i := 0; i < n; i++ {
+		pickMove(&moves, scores[:n], i)
+		score, childLeaf := p.quietLeaf(position.MakeMove(pos, moves.Get(i)), -beta, -alpha, ply+1)
+		if score = -score; score > best {
+			best, leaf = score, childLeaf
+		}
+		if best >= beta {
+			break
+		}
+		alpha = max(alpha, best)
+	}
+	return best, leaf
+}
+
+// tuneLeaf is a sample resolved to a quiet position.
+type tuneLeaf struct {
+	pos    *position.Position
+	result float64
+}
+
+// Tuner fits an engine's evaluation weights to labelled positions. It
+// changes the engine's parameters in place, so no search may run on the
+// engine while it works.
+type Tuner struct {
+	Threads int // goroutines computing the error; 0 uses every CPU
+
+	eval   *evalParams
+	refs   paramList
+	leaves []tuneLeaf
+	k      float64
+}
+
+// NewTuner resolves every sample to its quiet leaf with the engine's current
+// weights and prepares to tune the weights whose names start with one of
+// prefixes (ignoring case), or every evaluation weight if there are none.
+// Samples whose capture sequence ends in mate are dropped.
+func (e *Engine) NewTuner(samples []TuneSample, prefixes ...string) (*Tuner, error) {
+	t := &Tuner{eval: &e.eval, k: 1}
+
+	for _, r := range evalRefs(&e.eval) {
+		// piece values only steer move ordering and pruning
+		if strings.HasPrefix(r.name, "PieceValue.") {
+			continue
+		}
+		if len(prefixes) == 0 || hasPrefixFold(r.name, prefixes) {
+			t.refs = append(t.refs, r)
+		}
+	}
+	if len(t.refs) == 0 {
+		return nil, fmt.Errorf("no evaluation parameters match %s", strings.Join(prefixes, ", "))
+	}
+
+	for _, s := range samples {
+		if _, leaf := e.eval.quietLeaf(s.Pos, -Inf, Inf, 0); leaf != nil {
+			t.leaves = append(t.leaves, tuneLeaf{leaf, s.Result})
+		}
+	}
+	if len(t.leaves) == 0 {
+		return nil, fmt.Errorf("no usable positions")
+	}
+	return t, nil
+}
+
+func hasPrefixFold(name string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if len(name) >= len(p) && strings.EqualFold(name[:len(p)], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Positions returns the number of samples being fitted.
+func (t *Tuner) Positions() int {
+	return len(t.leaves)
+}
+
+// Params returns the names of the weights being tuned.
+func (t *Tuner) Params() []string {
+	names := make([]string, len(t.refs))
+	for i, r := range t.refs {
+		names[i] = r.name
+	}
+	return names
+}
+
+// K returns the sigmoid scale in use.
+func (t *Tuner) K() float64 {
+	return t.k
+}
+
+// SetK sets the sigmoid scale instead of fitting it.
+func (t *Tuner) SetK(k float64) {
+	t.k = k
+}
+
+// Error returns the mean squared error of the current weights.
+func (t *Tuner) Error() float64 {
+	return t.errorFor(t.k)
+}
+
+func (t *Tuner) errorFor(k float64) float64 {
+	threads := t.Threads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	threads = min(threads, len(t.leaves))
+
+	sums := make([]float64, threads)
+	var wg sync.WaitGroup
+	for w := 0; w < threads; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(t.leaves); i += threads {
+				l := &t.leaves[i]
+				eval := t.eval.evaluate(l.pos, nil)
+				if l.pos.ActiveColor == core.Black {
+					eval = -eval
+				}
+				d := l.result - sigmoid(k, float64(eval))
+				sums[w] += d * d
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := 0.0
+	for _, s := range sums {
+		total += s
+	}
+	return total / float64(len(t.leaves))
+}
+
+// FitK finds the sigmoid scale that best matches the current weights to the
+// labels, refining a scan of [0, 3] to a precision of 0.0001, and uses it
+// from then on.
+func (t *Tuner) FitK() float64 {
+	lo, hi, step := 0.0, 3.0, 0.1
+	best, bestErr := t.k, t.errorFor(t.k)
+	for range 4 {
+		for k := lo; k <= hi+step/2; k += step {
+			if err := t.errorFor(k); err < bestErr {
+				best, bestErr = k, err
+			}
+		}
+		lo, hi = max(0, best-step), best+step
+		step /= 10
+	}
+	t.k = best
+	return best
+}
+
+// Tune runs local search over the selected weights: each is moved up, then
+// down, by the current step and kept wherever the error drops. A pass that
+// improves nothing halves the step; tuning ends when a pass at step 1
+// improves nothing or after the given number of passes (0 for no limit).
+// progress, if not nil, is called after every pass. It returns the final
+// error.
+func (t *Tuner) Tune(step, passes int, progress func(pass, step int, err float64)) float64 {
+	step = max(step, 1)
+	bestErr := t.Error()
+
+	for pass := 1; passes <= 0 || pass <= passes; pass++ {
+		improved := false
+		for _, r := range t.refs {
+			old := r.get()
+			for _, v := range [2]int{old + step, old - step} {
+				if v < r.min || v > r.max {
+					continue
+				}
+				r.set(v)
+				if err := t.Error(); err < bestErr {
+					bestErr, improved = err, true
+					break
+				}
+				r.set(old)
+			}
+		}
+
+		if progress != nil {
+			progress(pass, step, bestErr)
+		}
+		if !improved {
+			if step == 1 {
+				break
+			}
+			step /= 2
+		}
+	}
+	return bestErr
+}
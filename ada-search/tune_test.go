@@ -0,0 +1,102 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+)
+
+func TestReadTuneSamples(t *testing.T) {
+	data := `# comment
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 1-0
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1; 0-1
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - c9 "1/2-1/2";
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 [1.0]
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 | 0.25
+
+rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 cp 0
+`
+	samples, err := ReadTuneSamples(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, 0, 0.5, 1, 0.25, 0.5}
+	if len(samples) != len(want) {
+		t.Fatalf("read %d samples, want %d", len(samples), len(want))
+	}
+	for i, s := range samples {
+		if s.Result != want[i] {
+			t.Errorf("sample %d: result %v, want %v", i, s.Result, want[i])
+		}
+	}
+
+	// a bare integer is the FEN's move counter, not a label
+	if _, err := ReadTuneSamples(strings.NewReader("8/8/8/8/8/8/8/K1k5 w - - 0 1\n")); err == nil {
+		t.Error("expected an error for a line without a label")
+	}
+}
+
+func TestQuietLeafResolvesCaptures(t *testing.T) {
+	// white's queen can take an undefended rook
+	pos, _ := fen.Parse("4k3/8/8/3r4/8/8/3Q4/4K3 w - - 0 1")
+	score, leaf := defaultParams.quietLeaf(pos, -Inf, Inf, 0)
+	if leaf == nil || leaf.Board.Check(35).Type() != core.Queen {
+		t.Fatalf("leaf should have the queen on d5")
+	}
+	if score != -defaultParams.evaluate(leaf, nil) {
+		t.Errorf("score %d should be the leaf's eval from white's view", score)
+	}
+}
+
+func TestFitKAndTune(t *testing.T) {
+	// positions labelled by the default evaluation with K = 1
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/R1BQKBNR w KQkq - 0 1",
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKB1R w KQkq - 0 1",
+		"r1bqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1",
+		"rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR b KQkq - 0 1",
+		"4k3/8/8/8/8/8/4P3/2N1K3 w - - 0 1",
+		"2n1k3/4p3/8/8/8/8/8/4K3 w - - 0 1",
+	}
+	var samples []TuneSample
+	for _, f := range fens {
+		pos, _ := fen.Parse(f)
+		_, leaf := defaultParams.quietLeaf(pos, -Inf, Inf, 0)
+		eval := defaultParams.evaluate(leaf, nil)
+		if leaf.ActiveColor == core.Black {
+			eval = -eval
+		}
+		samples = append(samples, TuneSample{Pos: pos, Result: sigmoid(1, float64(eval))})
+	}
+
+	e := NewEngine(1)
+	tuner, err := e.NewTuner(samples, "Material.Knight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tuner.FitK(); math.Abs(got-1) > 0.001 {
+		t.Errorf("FitK = %v, want 1", got)
+	}
+
+	if err := e.SetParam("Material.Knight.MG", 200); err != nil {
+		t.Fatal(err)
+	}
+	before := tuner.Error()
+	after := tuner.Tune(8, 0, nil)
+	if after >= before {
+		t.Errorf("error did not drop: %v -> %v", before, after)
+	}
+	if v, _ := e.Param("Material.Knight.MG"); v <= 200 {
+		t.Errorf("knight value stayed at %d, want it to rise toward 320", v)
+	}
+}
+
+func TestNewTunerUnknownPrefix(t *testing.T) {
+	pos, _ := fen.Parse("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+	if _, err := NewEngine(1).NewTuner([]TuneSample{{pos, 0.5}}, "NoSuchWeight"); err == nil {
+		t.Error("expected an error")
+	}
+}
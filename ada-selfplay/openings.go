@@ -0,0 +1,110 @@
+package selfplay
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+)
+
+// Opening is where the games of a pair start: a position and moves already
+// played from it, both written to the PGN of each game.
+type Opening struct {
+	Start *position.Position
+	Moves []core.Move
+}
+
+// Position returns the position the engines take over in.
+func (o Opening) Position() *position.Position {
+	pos := o.Start
+	for _, m := range o.Moves {
+		pos = position.MakeMove(pos, m)
+	}
+	return pos
+}
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// defaultOpenings are common, roughly balanced openings, a few moves deep.
+var defaultOpenings = []string{
+	"e4 e5 Nf3 Nc6 Bb5 a6",
+	"e4 e5 Nf3 Nc6 Bc4 Bc5",
+	"e4 c5 Nf3 d6 d4 cxd4 Nxd4 Nf6",
+	"e4 c5 Nc3 Nc6 g3 g6",
+	"e4 e6 d4 d5 Nc3 Nf6",
+	"e4 c6 d4 d5 e5 Bf5",
+	"e4 d5 exd5 Qxd5 Nc3 Qa5",
+	"d4 d5 c4 e6 Nc3 Nf6",
+	"d4 d5 c4 c6 Nf3 Nf6",
+	"d4 Nf6 c4 g6 Nc3 Bg7",
+	"d4 Nf6 c4 e6 Nc3 Bb4",
+	"d4 Nf6 c4 c5 d5 b5",
+	"c4 e5 Nc3 Nf6 g3 d5",
+	"Nf3 d5 g3 Nf6 Bg2 c6",
+	"e4 e5 Nf3 Nf6 Nxe5 d6",
+	"d4 f5 g3 Nf6 Bg2 e6",
+}
+
+// DefaultOpenings returns a small built-in opening suite.
+func DefaultOpenings() []Opening {
+	start, _ := fen.Parse(startFEN)
+	openings := make([]Opening, len(defaultOpenings))
+	for i, line := range defaultOpenings {
+		o := Opening{Start: start}
+		pos := start
+		for _, san := range strings.Fields(line) {
+			m, err := pgn.ParseSAN(pos, san)
+			if err != nil {
+				panic(fmt.Sprintf("selfplay: opening %q: %v", line, err))
+			}
+			o.Moves = append(o.Moves, m)
+			pos = position.MakeMove(pos, m)
+		}
+		openings[i] = o
+	}
+	return openings
+}
+
+// ReadFENOpenings reads an opening from each line of FEN or EPD text. Blank
+// lines and lines starting with # are skipped, and EPD operations are
+// ignored.
+func ReadFENOpenings(r io.Reader) ([]Opening, error) {
+	var openings []Opening
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		e, err := fen.ParseEPD(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		openings = append(openings, Opening{Start: e.Position})
+	}
+	return openings, sc.Err()
+}
+
+// ReadPGNOpenings takes an opening from the first plies moves of each game
+// in PGN text. Games that can't be read, or have no starting position, are
+// skipped.
+func ReadPGNOpenings(r io.Reader, plies int) ([]Opening, error) {
+	games, _, err := pgn.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	openings := make([]Opening, 0, len(games))
+	for _, g := range games {
+		if g.Start() == nil {
+			continue
+		}
+		moves := g.Moves()
+		openings = append(openings, Opening{Start: g.Start(), Moves: moves[:min(plies, len(moves))]})
+	}
+	return openings, nil
+}
@@ -0,0 +1,373 @@
+// Package selfplay plays matches between two configurations of the engine
+// in one process, to measure whether a change makes it stronger. Games
+// start from an opening suite, each opening played twice with colours
+// reversed, and the results give an Elo estimate with error bars and,
+// optionally, a sequential probability ratio test that ends the match as
+// soon as it is decided.
+package selfplay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+// Player is one side of a match.
+type Player struct {
+	Name string
+
+	// Setup configures a new engine for the player, for example by
+	// setting parameters. Engines start from the defaults without their
+	// opening book.
+	Setup func(e *search.Engine) error
+}
+
+// Config describes a match.
+type Config struct {
+	A, B Player
+
+	// Openings to play from, DefaultOpenings when empty. Game 2k and 2k+1
+	// both start from opening k, repeating the suite as needed, with A
+	// playing White in the first of them.
+	Openings []Opening
+
+	// Games is the most games to play. Concurrency is how many games are
+	// played at once, each engine searching on one thread.
+	Games       int
+	Concurrency int
+
+	// Each move is searched to Depth, or for MoveTime when that is set,
+	// with an HashMB megabyte table per engine.
+	Depth    int
+	MoveTime time.Duration
+	HashMB   int
+
+	// Adjudication, each off when 0. A game is won once both engines have
+	// scored it at least ResignScore for the same side for ResignMoves
+	// moves each in a row, and drawn once both have scored it within
+	// DrawScore of even for DrawMoves moves each in a row after move
+	// DrawAfter. Games reaching MaxPlies are drawn.
+	ResignScore, ResignMoves        int
+	DrawScore, DrawMoves, DrawAfter int
+	MaxPlies                        int
+
+	// SPRT, when set, stops the match once it reaches a verdict.
+	SPRT *SPRT
+
+	// PGN receives every game as it finishes. OnGame, if set, is called
+	// after each game with it and the results so far. Neither is called
+	// concurrently.
+	PGN    io.Writer
+	OnGame func(GameResult, Stats)
+}
+
+// DefaultConfig returns a quick match configuration.
+func DefaultConfig() Config {
+	return Config{
+		A:           Player{Name: "A"},
+		B:           Player{Name: "B"},
+		Games:       100,
+		Concurrency: 1,
+		Depth:       6,
+		HashMB:      16,
+		ResignScore: 1000,
+		ResignMoves: 3,
+		DrawScore:   10,
+		DrawMoves:   8,
+		DrawAfter:   40,
+		MaxPlies:    400,
+	}
+}
+
+// GameResult is how one game of a match went.
+type GameResult struct {
+	Round  int // from 1
+	AWhite bool
+	Result string // as in PGN, from White's point of view
+	Reason string
+	Plies  int
+	Game   *pgn.Game
+}
+
+// AScore returns the points A scored in the game.
+func (r GameResult) AScore() float64 {
+	switch {
+	case r.Result == "1/2-1/2":
+		return 0.5
+	case (r.Result == "1-0") == r.AWhite:
+		return 1
+	}
+	return 0
+}
+
+// Run plays a match and returns its results from A's point of view, with
+// the SPRT verdict when there is a test.
+func Run(cfg Config) (Stats, Verdict, error) {
+	if len(cfg.Openings) == 0 {
+		cfg.Openings = DefaultOpenings()
+	}
+	if cfg.Depth <= 0 && cfg.MoveTime <= 0 {
+		return Stats{}, Continue, errors.New("selfplay: a match needs a depth or a move time")
+	}
+	if cfg.A.Name == "" {
+		cfg.A.Name = "A"
+	}
+	if cfg.B.Name == "" {
+		cfg.B.Name = "B"
+	}
+	// find setup errors before any game is played
+	for _, p := range []Player{cfg.A, cfg.B} {
+		if _, err := newEngine(p, 1); err != nil {
+			return Stats{}, Continue, fmt.Errorf("%s: %w", p.Name, err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		stats   Stats
+		verdict Verdict = Continue
+		werr    error
+		next    atomic.Int64
+		stop    atomic.Bool
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < max(cfg.Concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, _ := newEngine(cfg.A, max(cfg.HashMB, 1))
+			b, _ := newEngine(cfg.B, max(cfg.HashMB, 1))
+			for !stop.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= cfg.Games {
+					return
+				}
+				res := playGame(&cfg, i, a, b)
+
+				mu.Lock()
+				switch res.AScore() {
+				case 1:
+					stats.Wins++
+				case 0.5:
+					stats.Draws++
+				default:
+					stats.Losses++
+				}
+				if cfg.PGN != nil && werr == nil {
+					_, werr = fmt.Fprintln(cfg.PGN, res.Game)
+				}
+				if cfg.OnGame != nil {
+					cfg.OnGame(res, stats)
+				}
+				if cfg.SPRT != nil {
+					if verdict = cfg.SPRT.Test(stats); verdict != Continue {
+						stop.Store(true)
+					}
+				}
+				if werr != nil {
+					stop.Store(true)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return stats, verdict, werr
+}
+
+// newEngine makes an engine for a player.
+func newEngine(p Player, hashMB int) (*search.Engine, error) {
+	e := search.NewEngine(hashMB)
+	e.Options.OwnBook = false
+	if p.Setup != nil {
+		if err := p.Setup(e); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// playGame plays game i of a match between engines a and b.
+func playGame(cfg *Config, i int, a, b *search.Engine) GameResult {
+	res := GameResult{Round: i + 1, AWhite: i%2 == 0}
+	white, black := a, b
+	whiteName, blackName := cfg.A.Name, cfg.B.Name
+	if !res.AWhite {
+		white, black = b, a
+		whiteName, blackName = cfg.B.Name, cfg.A.Name
+	}
+	a.Clear()
+	b.Clear()
+
+	opening := cfg.Openings[(i/2)%len(cfg.Openings)]
+	g := pgn.NewGame(opening.Start)
+	g.Event = "AdaEngine match"
+	g.Site = "local"
+	g.White, g.Black = whiteName, blackName
+	g.Tags["Round"] = fmt.Sprint(res.Round)
+
+	pos := opening.Start
+	var history []*position.Position
+	for _, m := range opening.Moves {
+		g.AddMove(pos, m)
+		history = append(history, pos)
+		pos = position.MakeMove(pos, m)
+	}
+
+	var adj adjudicator
+	for {
+		if result, reason, over := gameOver(pos, history); over {
+			res.Result, res.Reason = result, reason
+			break
+		}
+		if cfg.MaxPlies > 0 && len(history) >= cfg.MaxPlies {
+			res.Result, res.Reason = "1/2-1/2", "move limit"
+			break
+		}
+
+		e := white
+		if pos.ActiveColor == core.Black {
+			e = black
+		}
+		e.SetHistory(history)
+		depth := cfg.Depth
+		if depth <= 0 {
+			depth = 64
+		}
+		r := e.Search(pos, depth, 1, cfg.MoveTime)
+		if r.Move == core.NoMove {
+			// can't happen with moves left, but never loop on it
+			res.Result, res.Reason = "1/2-1/2", "no move"
+			break
+		}
+
+		score := r.Score
+		if pos.ActiveColor == core.Black {
+			score = -score
+		}
+		g.AddMove(pos, r.Move)
+		history = append(history, pos)
+		pos = position.MakeMove(pos, r.Move)
+
+		if result, reason, ok := adj.update(cfg, score, len(history)); ok {
+			res.Result, res.Reason = result, reason
+			break
+		}
+	}
+
+	g.Result = res.Result
+	g.Tags["Termination"] = res.Reason
+	res.Plies = g.MoveCount()
+	res.Game = g
+	return res
+}
+
+// gameOver reports whether the game has ended by the rules in pos, reached
+// after the positions in history, with the result and why.
+func gameOver(pos *position.Position, history []*position.Position) (result, reason string, over bool) {
+	moves := movegen.LegalMoves(pos)
+	if moves.Count() == 0 {
+		if !movegen.InCheck(pos) {
+			return "1/2-1/2", "stalemate", true
+		}
+		if pos.ActiveColor == core.White {
+			return "0-1", "checkmate", true
+		}
+		return "1-0", "checkmate", true
+	}
+	if pos.Halfmoves >= 100 {
+		return "1/2-1/2", "fifty move rule", true
+	}
+	if insufficientMaterial(pos) {
+		return "1/2-1/2", "insufficient material", true
+	}
+
+	seen := 0
+	for j := len(history) - 2; j >= 0 && j >= len(history)-pos.Halfmoves; j -= 2 {
+		if history[j].Zobrist == pos.Zobrist {
+			seen++
+		}
+	}
+	if seen >= 2 {
+		return "1/2-1/2", "threefold repetition", true
+	}
+	return "", "", false
+}
+
+// insufficientMaterial reports whether neither side can mate: bare kings,
+// or a lone knight or bishop besides them.
+func insufficientMaterial(pos *position.Position) bool {
+	minors := 0
+	for _, color := range []core.Color{core.White, core.Black} {
+		for _, pt := range []core.PieceType{core.Pawn, core.Rook, core.Queen} {
+			if !pos.Board.Pieces(core.NewPiece(pt, color)).Empty() {
+				return false
+			}
+		}
+		for _, pt := range []core.PieceType{core.Knight, core.Bishop} {
+			minors += pos.Board.Pieces(core.NewPiece(pt, color)).Count()
+		}
+	}
+	return minors <= 1
+}
+
+// adjudicator tracks the engines' scores to end clear games early.
+type adjudicator struct {
+	resignPlies int // plies in a row past ResignScore for one side
+	resignSide  int // +1 White, -1 Black
+	drawPlies   int // plies in a row near even
+}
+
+// update records the score, from White's point of view, of the search for
+// the move that made the game plies long, and reports a result when the
+// game can be adjudicated.
+func (a *adjudicator) update(cfg *Config, score, plies int) (result, reason string, over bool) {
+	if cfg.ResignScore > 0 && cfg.ResignMoves > 0 {
+		side := 0
+		if score >= cfg.ResignScore {
+			side = 1
+		} else if score <= -cfg.ResignScore {
+			side = -1
+		}
+		if side != 0 && side == a.resignSide {
+			a.resignPlies++
+		} else {
+			a.resignSide = side
+			a.resignPlies = min(side*side, 1)
+		}
+		if a.resignSide != 0 && a.resignPlies >= 2*cfg.ResignMoves {
+			if a.resignSide > 0 {
+				return "1-0", "adjudicated win", true
+			}
+			return "0-1", "adjudicated win", true
+		}
+	}
+
+	if cfg.DrawScore > 0 && cfg.DrawMoves > 0 && plies > 2*cfg.DrawAfter {
+		if abs(score) <= cfg.DrawScore {
+			a.drawPlies++
+		} else {
+			a.drawPlies = 0
+		}
+		if a.drawPlies >= 2*cfg.DrawMoves {
+			return "1/2-1/2", "adjudicated draw", true
+		}
+	}
+	return "", "", false
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,189 @@
+package selfplay
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func TestOpenings(t *testing.T) {
+	for i, o := range DefaultOpenings() {
+		if len(o.Moves) == 0 || o.Position() == nil {
+			t.Errorf("opening %d is empty", i)
+		}
+	}
+
+	suite := "# comment\n" +
+		"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - id \"e4\";\n" +
+		"rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq - 0 1\n"
+	ops, err := ReadFENOpenings(strings.NewReader(suite))
+	if err != nil || len(ops) != 2 {
+		t.Fatalf("read %d openings: %v", len(ops), err)
+	}
+	if got := fen.Format(ops[0].Position()); !strings.HasPrefix(got, "rnbqkbnr/pppppppp/8/8/4P3") {
+		t.Errorf("first opening %s", got)
+	}
+}
+
+func TestReadPGNOpenings(t *testing.T) {
+	text := "1. e4 e5 2. Nf3 Nc6 *\n\n[Event \"x\"]\n[White \"a\"]\n"
+	ops, err := ReadPGNOpenings(strings.NewReader(text), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range ops {
+		if o.Start == nil {
+			t.Fatalf("opening %d has no start", i)
+		}
+	}
+	if len(ops) == 0 || len(ops[0].Moves) != 3 {
+		t.Fatalf("openings %v", ops)
+	}
+
+	// every opening can be played from
+	cfg := DefaultConfig()
+	cfg.Openings = ops
+	cfg.Games = 2 * len(ops)
+	cfg.Depth = 1
+	cfg.HashMB = 1
+	cfg.MaxPlies = 12
+	if stats, _, err := Run(cfg); err != nil || stats.Games() != cfg.Games {
+		t.Errorf("%v, %v", stats, err)
+	}
+}
+
+func TestInsufficientMaterial(t *testing.T) {
+	for f, want := range map[string]bool{
+		"8/8/4k3/8/8/3K4/8/8 w - - 0 1":   true,
+		"8/8/4k3/8/8/3KN3/8/8 w - - 0 1":  true,
+		"8/8/4kb2/8/8/3KN3/8/8 w - - 0 1": false,
+		"8/8/4k3/8/8/3KR3/8/8 w - - 0 1":  false,
+		"8/8/4k3/8/8/3K4/4P3/8 w - - 0 1": false,
+	} {
+		pos, _ := fen.Parse(f)
+		if got := insufficientMaterial(pos); got != want {
+			t.Errorf("%s: %v, want %v", f, got, want)
+		}
+	}
+}
+
+func TestAdjudication(t *testing.T) {
+	cfg := DefaultConfig()
+	var a adjudicator
+	scores := []int{900, 1100, 1200, 1000, 1300, 1100, 1200}
+	for i, s := range scores {
+		result, _, over := a.update(&cfg, s, 20+i)
+		if over != (i == len(scores)-1) {
+			t.Fatalf("after %d scores over is %v", i+1, over)
+		}
+		if over && result != "1-0" {
+			t.Errorf("result %s", result)
+		}
+	}
+
+	a = adjudicator{}
+	for i := 1; i <= 2*cfg.DrawMoves; i++ {
+		if _, _, over := a.update(&cfg, 0, i); over {
+			t.Fatal("drawn before DrawAfter")
+		}
+	}
+	for i := 0; i < 2*cfg.DrawMoves; i++ {
+		result, _, over := a.update(&cfg, 5, 2*cfg.DrawAfter+1+i)
+		if over != (i == 2*cfg.DrawMoves-1) {
+			t.Fatalf("after %d quiet plies over is %v", i+1, over)
+		}
+		if over && result != "1/2-1/2" {
+			t.Errorf("result %s", result)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Games = 4
+	cfg.Concurrency = 2
+	cfg.Depth = 1
+	cfg.HashMB = 1
+	cfg.MaxPlies = 60
+	cfg.B = Player{Name: "Weak", Setup: func(e *search.Engine) error {
+		return e.SetParam("SkillLevel", 1)
+	}}
+	var out bytes.Buffer
+	cfg.PGN = &out
+	rounds := map[int]bool{}
+	cfg.OnGame = func(r GameResult, s Stats) {
+		rounds[r.Round] = true
+		if r.AWhite != (r.Round%2 == 1) {
+			t.Errorf("round %d: A white %v", r.Round, r.AWhite)
+		}
+	}
+
+	stats, verdict, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Games() != 4 || len(rounds) != 4 || verdict != Continue {
+		t.Errorf("%v, rounds %v, verdict %v", stats, rounds, verdict)
+	}
+
+	r := pgn.NewReader(&out)
+	read := 0
+	for {
+		g, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		read++
+		if g.Tags["Termination"] == "" || g.Result == "*" {
+			t.Errorf("game %s unfinished: %s", g.Tags["Round"], g.Result)
+		}
+		if (g.White == "A") == (g.Black == "A") {
+			t.Errorf("players %s and %s", g.White, g.Black)
+		}
+	}
+	if read != 4 {
+		t.Errorf("read %d games back", read)
+	}
+
+	cfg.A.Setup = func(e *search.Engine) error { return e.SetParam("NoSuchParam", 1) }
+	if _, _, err := Run(cfg); err == nil {
+		t.Error("a bad setup didn't fail the match")
+	}
+}
+
+func TestSPRTStopsMatch(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Games = 100
+	cfg.Depth = 1
+	cfg.HashMB = 1
+	cfg.MaxPlies = 40
+	// White always wins, so the players score evenly, far better than
+	// either hypothesis and with enough spread for the test to decide
+	cfg.Openings = []Opening{{Start: mustParse(t, "4k3/8/8/8/8/8/8/QQQ1K3 w - - 0 1")}}
+	cfg.SPRT = &SPRT{Elo0: -400, Elo1: -200, Alpha: 0.2, Beta: 0.2}
+	stats, verdict, err := Run(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verdict != AcceptH1 || stats.Games() >= cfg.Games {
+		t.Errorf("%v after %d games", verdict, stats.Games())
+	}
+}
+
+func mustParse(t *testing.T, f string) *position.Position {
+	t.Helper()
+	pos, err := fen.Parse(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pos
+}
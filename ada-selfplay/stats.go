@@ -0,0 +1,126 @@
+package selfplay
+
+import (
+	"fmt"
+	"math"
+)
+
+// Stats counts a match's results from the first player's point of view.
+type Stats struct {
+	Wins, Draws, Losses int
+}
+
+// Games returns the number of games counted.
+func (s Stats) Games() int {
+	return s.Wins + s.Draws + s.Losses
+}
+
+// Score returns the points the first player scored per game, from 0 to 1.
+func (s Stats) Score() float64 {
+	if s.Games() == 0 {
+		return 0.5
+	}
+	return (float64(s.Wins) + float64(s.Draws)/2) / float64(s.Games())
+}
+
+// variance returns the variance of a single game's result.
+func (s Stats) variance() float64 {
+	n := float64(s.Games())
+	if n == 0 {
+		return 0
+	}
+	mu := s.Score()
+	return (float64(s.Wins)*(1-mu)*(1-mu) + float64(s.Draws)*(0.5-mu)*(0.5-mu) + float64(s.Losses)*mu*mu) / n
+}
+
+// Elo returns the first player's rating advantage the results suggest, and
+// how far either side of it the true advantage lies with 95% confidence.
+// With no games, or all won or all lost, the estimate is infinite.
+func (s Stats) Elo() (elo, margin float64) {
+	n := float64(s.Games())
+	mu := s.Score()
+	elo = scoreToElo(mu)
+	if n == 0 || math.IsInf(elo, 0) {
+		return elo, math.Inf(1)
+	}
+	dev := 1.96 * math.Sqrt(s.variance()/n)
+	lo, hi := scoreToElo(mu-dev), scoreToElo(mu+dev)
+	return elo, (hi - lo) / 2
+}
+
+func (s Stats) String() string {
+	elo, margin := s.Elo()
+	return fmt.Sprintf("+%d =%d -%d  score %.1f%%  Elo %+.1f ± %.1f", s.Wins, s.Draws, s.Losses, 100*s.Score(), elo, margin)
+}
+
+// scoreToElo converts an expected score to a rating difference with the
+// logistic Elo model.
+func scoreToElo(score float64) float64 {
+	if score <= 0 {
+		return math.Inf(-1)
+	}
+	if score >= 1 {
+		return math.Inf(1)
+	}
+	return -400 * math.Log10(1/score-1)
+}
+
+// eloToScore is the expected score of a player rated elo points higher.
+func eloToScore(elo float64) float64 {
+	return 1 / (1 + math.Pow(10, -elo/400))
+}
+
+// SPRT is a sequential probability ratio test of whether the first player
+// is Elo0 or Elo1 points stronger, with Alpha the chance of accepting Elo1
+// when Elo0 holds and Beta the chance of the opposite mistake.
+type SPRT struct {
+	Elo0, Elo1  float64
+	Alpha, Beta float64
+}
+
+// Verdict is where an SPRT stands.
+type Verdict int
+
+const (
+	Continue Verdict = iota
+	AcceptH0         // the first player is no better than Elo0
+	AcceptH1         // the first player is at least Elo1 better
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case AcceptH0:
+		return "H0 accepted"
+	case AcceptH1:
+		return "H1 accepted"
+	}
+	return "continue"
+}
+
+// Bounds returns the log likelihood ratios at which the test stops.
+func (t SPRT) Bounds() (lower, upper float64) {
+	return math.Log(t.Beta / (1 - t.Alpha)), math.Log((1 - t.Beta) / t.Alpha)
+}
+
+// LLR returns the log likelihood ratio of the results for Elo1 against
+// Elo0, using the normal approximation to the distribution of the score.
+func (t SPRT) LLR(s Stats) float64 {
+	v := s.variance()
+	if s.Games() == 0 || v == 0 {
+		return 0
+	}
+	s0, s1 := eloToScore(t.Elo0), eloToScore(t.Elo1)
+	return float64(s.Games()) * (s1 - s0) * (2*s.Score() - s0 - s1) / (2 * v)
+}
+
+// Test returns the verdict on the results so far.
+func (t SPRT) Test(s Stats) Verdict {
+	lower, upper := t.Bounds()
+	switch llr := t.LLR(s); {
+	case llr >= upper:
+		return AcceptH1
+	case llr <= lower:
+		return AcceptH0
+	}
+	return Continue
+}
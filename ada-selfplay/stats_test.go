@@ -0,0 +1,48 @@
+package selfplay
+
+import (
+	"math"
+	"testing"
+)
+
+func TestElo(t *testing.T) {
+	even := Stats{Wins: 30, Draws: 40, Losses: 30}
+	if elo, margin := even.Elo(); elo != 0 || margin <= 0 || margin > 100 {
+		t.Errorf("even match: Elo %f ± %f", elo, margin)
+	}
+	// a 64% score is about 100 Elo
+	if elo, _ := (Stats{Wins: 64, Losses: 36}).Elo(); math.Abs(elo-100) > 2 {
+		t.Errorf("64%% score gives Elo %f", elo)
+	}
+	if elo, _ := (Stats{Wins: 5}).Elo(); !math.IsInf(elo, 1) {
+		t.Errorf("all wins gives Elo %f", elo)
+	}
+	more := Stats{Wins: 300, Draws: 400, Losses: 300}
+	if _, m1 := even.Elo(); func() bool { _, m2 := more.Elo(); return m2 >= m1 }() {
+		t.Error("more games didn't narrow the margin")
+	}
+	if math.Abs(scoreToElo(eloToScore(150))-150) > 1e-9 {
+		t.Error("scoreToElo doesn't invert eloToScore")
+	}
+}
+
+func TestSPRT(t *testing.T) {
+	test := SPRT{Elo0: 0, Elo1: 10, Alpha: 0.05, Beta: 0.05}
+	lower, upper := test.Bounds()
+	if math.Abs(lower+2.944) > 0.001 || math.Abs(upper-2.944) > 0.001 {
+		t.Errorf("bounds %f, %f", lower, upper)
+	}
+	for _, tc := range []struct {
+		s    Stats
+		want Verdict
+	}{
+		{Stats{}, Continue},
+		{Stats{Wins: 10, Draws: 10, Losses: 10}, Continue},
+		{Stats{Wins: 600, Draws: 800, Losses: 400}, AcceptH1},
+		{Stats{Wins: 400, Draws: 800, Losses: 600}, AcceptH0},
+	} {
+		if got := test.Test(tc.s); got != tc.want {
+			t.Errorf("%v: %v (LLR %f), want %v", tc.s, got, test.LLR(tc.s), tc.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Command ada-suite runs EPD test suites, such as WAC or STS, to track the
+// engine's tactical strength across changes.
+//
+//	ada-suite [flags] suite.epd ...
+//
+// Each position is searched within the budget given by the flags and is
+// solved when the engine's move meets its bm, am and dm operations. The
+// failures are listed after the summary of each suite.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-analysis"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func main() {
+	depth    := flag.Int("depth", 0, "search depth for each position (0 for no limit)")
+	moveTime := flag.Duration("movetime", time.Second, "time limit for each position (0 for none)")
+	nodes    := flag.Uint64("nodes", 0, "node limit for each position (0 for none)")
+	threads  := flag.Int("threads", 1, "search threads")
+	hash     := flag.Int("hash", search.DefaultHashMB, "hash table size in megabytes")
+	params   := flag.String("params", "", "parameter file to load (.json or .toml)")
+	verbose  := flag.Bool("v", false, "print every position's result")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-suite [flags] suite.epd ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	e := search.NewEngine(*hash)
+	if *params != "" {
+		if err := e.LoadParams(*params); err != nil {
+			fmt.Fprintln(os.Stderr, "ada-suite:", err)
+			os.Exit(1)
+		}
+	}
+	opts := analysis.SuiteOptions{Depth: *depth, MoveTime: *moveTime, Nodes: *nodes, Threads: *threads}
+
+	failed := false
+	for _, name := range flag.Args() {
+		ok, err := run(e, name, opts, *verbose)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ada-suite:", err)
+			os.Exit(1)
+		}
+		failed = failed || !ok
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// run runs one suite file and reports whether every position was solved.
+func run(e *search.Engine, name string, opts analysis.SuiteOptions, verbose bool) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	suite, err := fen.ReadEPD(f)
+	f.Close()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+
+	report, err := analysis.RunSuite(e, suite, opts, func(r analysis.SuiteResult) {
+		if verbose {
+			fmt.Println(r)
+		}
+	})
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", name, err)
+	}
+
+	elapsed := report.Time()
+	nps := uint64(0)
+	if ms := elapsed.Milliseconds(); ms > 0 {
+		nps = report.Nodes() * 1000 / uint64(ms)
+	}
+	fmt.Printf("%s: %d of %d solved, average time to solution %v\n",
+		name, report.Solved(), len(report.Results), report.SolvedTime().Round(time.Millisecond))
+	fmt.Printf("%d nodes in %v, %d nps\n", report.Nodes(), elapsed.Round(time.Millisecond), nps)
+	if failures := report.Failures(); len(failures) > 0 {
+		fmt.Println("failed:")
+		for _, r := range failures {
+			fmt.Printf("  %s  %s, played %s (%s)\n", r.ID, r.Expected, r.SAN, analysis.FormatEval(r.Score))
+		}
+	}
+	return len(report.Failures()) == 0, nil
+}
@@ -0,0 +1,126 @@
+// Command ada-tbgen generates distance-to-mate endgame tablebases by
+// retrograde analysis and saves them where the engine can load them, with
+// the UCI option TablebasePath or the TUI's tb command.
+//
+//	ada-tbgen [flags] KQK KRK KQKR ...
+//	ada-tbgen -pieces 4
+//
+// Tables already in the output directory are not generated again. Tables
+// of four pieces take about a minute each; five are possible but slow and
+// need several hundred megabytes apiece while they are built.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
+)
+
+func main() {
+	dir    := flag.String("dir", "tablebases", "directory to read existing tables from and save new ones to")
+	pieces := flag.Int("pieces", 0, "generate every table with up to this many pieces, kings included")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-tbgen [flags] [code ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	codes := flag.Args()
+	if *pieces > 0 {
+		codes = append(codes, allCodes(*pieces)...)
+	}
+	if len(codes) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*dir, codes); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-tbgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, codes []string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tb, err := tablebase.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		start := time.Now()
+		tables, err := tb.Generate(code)
+		if err != nil {
+			return err
+		}
+		for _, t := range tables {
+			if err := t.Save(dir); err != nil {
+				return err
+			}
+			st := t.Stats()
+			fmt.Printf("%-8s %10d positions  %d wins %d draws %d losses  longest mate %d plies\n",
+				t.Code(), t.Size(), st.Wins, st.Draws, st.Losses, st.Longest)
+		}
+		if len(tables) > 0 {
+			fmt.Printf("%s done in %s\n", code, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	return nil
+}
+
+// allCodes lists every material combination with at least one piece
+// besides the kings and at most n pieces in all, smallest first. Each
+// appears once or with the colors swapped; generating a table covers both.
+func allCodes(n int) []string {
+	var codes []string
+	for extra := 1; extra <= n-2; extra++ {
+		for white := extra; white*2 >= extra; white-- {
+			for _, w := range multisets(white) {
+				for _, b := range multisets(extra - white) {
+					codes = append(codes, code(w, b))
+				}
+			}
+		}
+	}
+	return codes
+}
+
+var letters = map[core.PieceType]byte{
+	core.Queen: 'Q', core.Rook: 'R', core.Bishop: 'B', core.Knight: 'N', core.Pawn: 'P',
+}
+
+// multisets lists every choice of n pieces, in the order codes list them.
+func multisets(n int) [][]core.PieceType {
+	order := []core.PieceType{core.Queen, core.Rook, core.Bishop, core.Knight, core.Pawn}
+	var out [][]core.PieceType
+	var pick func(from int, chosen []core.PieceType)
+	pick = func(from int, chosen []core.PieceType) {
+		if len(chosen) == n {
+			out = append(out, append([]core.PieceType{}, chosen...))
+			return
+		}
+		for i := from; i < len(order); i++ {
+			pick(i, append(chosen, order[i]))
+		}
+	}
+	pick(0, nil)
+	return out
+}
+
+func code(white, black []core.PieceType) string {
+	b := []byte{'K'}
+	for _, pt := range white {
+		b = append(b, letters[pt])
+	}
+	b = append(b, 'K')
+	for _, pt := range black {
+		b = append(b, letters[pt])
+	}
+	return string(b)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"runtime"
 	"strconv"
 	"strings"
@@ -10,11 +11,13 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 
+	"github.com/WilliamDann/AdaEngine/ada-analysis"
 	"github.com/WilliamDann/AdaEngine/ada-chess/core"
 	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
 	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
 	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
 	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-chess/tablebase"
 	"github.com/WilliamDann/AdaEngine/ada-search"
 )
 
@@ -33,6 +36,9 @@ type app struct {
 	timeLimit time.Duration
 	mode      int
 	game      *pgn.Game
+	engine    *search.Engine
+	hashMB    int
+	searching int // searches started and not yet finished
 
 	tv    *tview.Application
 	board *KittyImage
@@ -46,6 +52,8 @@ func newApp() *app {
 		depth:     defaultDepth,
 		threads:   0,
 		timeLimit: 20 * time.Second,
+		hashMB:    search.DefaultHashMB,
+		engine:    search.NewEngine(search.DefaultHashMB),
 	}
 }
 
@@ -94,8 +102,13 @@ func (a *app) refresh() {
 }
 
 // engineMove starts an engine search and plays the result. If the game isn't
-// over and auto mode is on, it schedules another move.
+// over and auto mode is on, it schedules another move. It refuses while
+// another search, which shares the engine, is running.
 func (a *app) engineMove() {
+	if a.searching > 0 {
+		a.appendLog("[red]Can't start a search during another one.[-]")
+		return
+	}
 	moves := movegen.LegalMoves(a.pos)
 	if moves.Count() == 0 {
 		return
@@ -103,28 +116,25 @@ func (a *app) engineMove() {
 	d := a.depth
 	pos := a.pos
 	a.appendLog(fmt.Sprintf("[yellow]Thinking (%s)...[-]", a.searchLabel()))
+	a.engine.SetHistory(a.game.Positions())
+	a.searching++
 	go func() {
 		start := time.Now()
-		res := search.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
+		res := a.engine.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
 			elapsed := time.Since(start)
 			a.tv.QueueUpdateDraw(func() {
-				a.appendLog(fmt.Sprintf("  depth [aqua]%d[-]: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]",
-					r.Depth, r.Move, formatScore(r.Score), r.Nodes, elapsed.Round(time.Millisecond)))
+				a.appendLog(depthLine(r, elapsed))
 			})
 		})
 		elapsed := time.Since(start)
 		a.tv.QueueUpdateDraw(func() {
+			a.searching--
 			if res.Move == core.NoMove {
 				return
 			}
 			a.game.AddMove(pos, res.Move)
 			a.pos = position.MakeMove(pos, res.Move)
-			nps := uint64(0)
-			if elapsed.Seconds() > 0 {
-				nps = uint64(float64(res.Nodes) / elapsed.Seconds())
-			}
-			a.appendLog(fmt.Sprintf("Engine: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]  nps: [yellow]%d[-]",
-				res.Move, formatScore(res.Score), res.Nodes, elapsed.Round(time.Millisecond), nps))
+			a.appendLog(resultLine("Engine", res, elapsed))
 			a.refresh()
 
 			// Continue if auto mode or game not over
@@ -158,12 +168,23 @@ func (a *app) handleInput(text string) {
 		a.appendLog("  [yellow]mode[-]         Cycle: human/auto/off")
 		a.appendLog("  [yellow]stop[-]         Stop auto-play")
 		a.appendLog("  [yellow]moves[-]        List legal moves")
+		a.appendLog("  [yellow]eval[-]         Explain the static evaluation")
 		a.appendLog("  [yellow]depth <n>[-]    Set search depth")
 		a.appendLog("  [yellow]time <dur>[-]   Set time limit (e.g. 5s, 20s, 0 to disable)")
 		a.appendLog("  [yellow]threads <n>[-]  Set search threads")
+		a.appendLog("  [yellow]hash <mb>[-]    Set hash table size")
+		a.appendLog("  [yellow]set <name> <v>[-] Set a search or eval parameter")
+		a.appendLog("  [yellow]params [f][-]   List changed parameters, or all matching f")
+		a.appendLog("  [yellow]params load|save|reset[-] Parameter files (.json or .toml)")
+		a.appendLog("  [yellow]nnue [file|off][-] Load a network, or evaluate without one")
+		a.appendLog("  [yellow]tb [dir|off][-]  Probe the position, load tablebases, or drop them")
+		a.appendLog("  [yellow]tb gen <code> [dir][-] Generate a tablebase, e.g. KQKR, and save it")
+		a.appendLog("  [yellow]book [file|off][-] Show book moves, load a Polyglot book, or drop it")
+		a.appendLog("  [yellow]level [n|elo N|off][-] Show or set how strong the engine plays (1-20)")
 		a.appendLog("  [yellow]fen <str>[-]    Load position")
 		a.appendLog("  [yellow]new[-]          New game")
 		a.appendLog("  [yellow]pgn[-]          Show PGN of current game")
+		a.appendLog("  [yellow]review [d][-]   Review the game's moves and annotate its PGN")
 		a.appendLog("  [yellow]quit[-]         Exit")
 
 	case "moves", "m":
@@ -174,6 +195,9 @@ func (a *app) handleInput(text string) {
 		}
 		a.appendLog(fmt.Sprintf("[yellow]%d moves:[-] %s", len(parts), strings.Join(parts, " ")))
 
+	case "eval", "e":
+		a.showEval()
+
 	case "depth", "d":
 		if len(args) < 2 {
 			a.appendLog(fmt.Sprintf("Depth: [aqua]%d[-]", a.depth))
@@ -212,60 +236,130 @@ func (a *app) handleInput(text string) {
 			a.appendLog(fmt.Sprintf("Threads set to [aqua]%d[-]", t))
 		}
 
+	case "hash":
+		if len(args) < 2 {
+			a.appendLog(fmt.Sprintf("Hash: [aqua]%d MB[-]  (%d‰ full)", a.hashMB, a.engine.Hashfull()))
+		} else if a.searching > 0 {
+			a.appendLog("[red]Can't change the hash during a search.[-]")
+		} else if mb, err := strconv.Atoi(args[1]); err == nil && mb > 0 {
+			a.hashMB = mb
+			a.engine.SetHash(mb)
+			a.appendLog(fmt.Sprintf("Hash set to [aqua]%d MB[-]", mb))
+		} else {
+			a.appendLog("[red]Usage: hash <mb>[-]")
+		}
+
+	case "set":
+		if len(args) < 3 {
+			a.appendLog("[red]Usage: set <name> <value>[-]")
+		} else if a.searching > 0 {
+			a.appendLog("[red]Can't change parameters during a search.[-]")
+		} else if v, ok := parseParamValue(args[2]); !ok {
+			a.appendLog(fmt.Sprintf("[red]Bad value: %s[-]", args[2]))
+		} else if err := a.engine.SetParam(args[1], v); err != nil {
+			a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+		} else {
+			a.appendLog(fmt.Sprintf("%s set to [aqua]%s[-]", args[1], args[2]))
+		}
+
+	case "params":
+		a.params(args[1:])
+
+	case "nnue":
+		switch {
+		case len(args) < 2:
+			if a.engine.UsesNetwork() {
+				a.appendLog("Evaluation: [aqua]network[-]")
+			} else {
+				a.appendLog("Evaluation: [aqua]handcrafted[-]")
+			}
+		case a.searching > 0:
+			a.appendLog("[red]Can't change the evaluation during a search.[-]")
+		case args[1] == "off":
+			a.engine.SetNetwork(nil)
+			a.appendLog("[yellow]Network removed; using the handcrafted evaluation.[-]")
+		default:
+			if err := a.engine.LoadNetwork(args[1]); err != nil {
+				a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+			} else {
+				a.engine.SetParam("NNUE", 1)
+				a.appendLog(fmt.Sprintf("[yellow]Network loaded from %s.[-]", args[1]))
+			}
+		}
+
+	case "tb":
+		a.tablebases(args)
+
+	case "book":
+		a.openingBook(args)
+
+	case "level":
+		a.skillLevel(args)
+
+	case "review":
+		a.review(args)
+
 	case "search", "s":
+		if a.searching > 0 {
+			a.appendLog("[red]Can't start a search during another one.[-]")
+			break
+		}
 		d := a.parseDepthArg(args)
 		a.appendLog(fmt.Sprintf("[yellow]Searching (%s)...[-]", a.searchLabel()))
 		pos := a.pos
+		a.engine.SetHistory(a.game.Positions())
+		a.searching++
 		go func() {
 			start := time.Now()
-			res := search.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
+			res := a.engine.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
 				elapsed := time.Since(start)
 				a.tv.QueueUpdateDraw(func() {
-					a.appendLog(fmt.Sprintf("  depth [aqua]%d[-]: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]",
-						r.Depth, r.Move, formatScore(r.Score), r.Nodes, elapsed.Round(time.Millisecond)))
+					a.appendLog(depthLine(r, elapsed))
 				})
 			})
 			elapsed := time.Since(start)
 			a.tv.QueueUpdateDraw(func() {
+				a.searching--
 				if res.Move == core.NoMove {
 					a.appendLog("[red]No moves available.[-]")
 				} else {
-					nps := uint64(0)
-					if elapsed.Seconds() > 0 {
-						nps = uint64(float64(res.Nodes) / elapsed.Seconds())
+					a.appendLog(resultLine("Best", res, elapsed))
+					if n := a.engine.Options.MultiPV; n > 1 {
+						for i, l := range res.Lines[:min(n, len(res.Lines))] {
+							a.appendLog(fmt.Sprintf("  %d. [aqua]%-7s[-] %s", i+1, pgn.SAN(pos, l.Move), formatScore(l.Score)))
+						}
 					}
-					a.appendLog(fmt.Sprintf("Best: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]  nps: [yellow]%d[-]",
-						res.Move, formatScore(res.Score), res.Nodes, elapsed.Round(time.Millisecond), nps))
 				}
 			})
 		}()
 
 	case "play", "p":
+		if a.searching > 0 {
+			a.appendLog("[red]Can't start a search during another one.[-]")
+			break
+		}
 		d := a.parseDepthArg(args)
 		a.appendLog(fmt.Sprintf("[yellow]Thinking (%s)...[-]", a.searchLabel()))
 		pos := a.pos
+		a.engine.SetHistory(a.game.Positions())
+		a.searching++
 		go func() {
 			start := time.Now()
-			res := search.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
+			res := a.engine.Search(pos, d, a.threads, a.timeLimit, func(r search.Result) {
 				elapsed := time.Since(start)
 				a.tv.QueueUpdateDraw(func() {
-					a.appendLog(fmt.Sprintf("  depth [aqua]%d[-]: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]",
-						r.Depth, r.Move, formatScore(r.Score), r.Nodes, elapsed.Round(time.Millisecond)))
+					a.appendLog(depthLine(r, elapsed))
 				})
 			})
 			elapsed := time.Since(start)
 			a.tv.QueueUpdateDraw(func() {
+				a.searching--
 				if res.Move == core.NoMove {
 					a.appendLog("[red]No moves available.[-]")
 				} else {
 					a.game.AddMove(pos, res.Move)
 					a.pos = position.MakeMove(pos, res.Move)
-					nps := uint64(0)
-					if elapsed.Seconds() > 0 {
-						nps = uint64(float64(res.Nodes) / elapsed.Seconds())
-					}
-					a.appendLog(fmt.Sprintf("Engine: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]  nps: [yellow]%d[-]",
-						res.Move, formatScore(res.Score), res.Nodes, elapsed.Round(time.Millisecond), nps))
+					a.appendLog(resultLine("Engine", res, elapsed))
 					a.refresh()
 				}
 			})
@@ -333,6 +427,346 @@ func (a *app) handleInput(text string) {
 	}
 }
 
+// params lists, loads, saves or resets the engine parameters.
+func (a *app) params(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "load", "save", "reset":
+			if a.searching > 0 {
+				a.appendLog("[red]Can't change parameters during a search.[-]")
+				return
+			}
+			if args[0] == "reset" {
+				a.engine.ResetParams()
+				a.appendLog("[yellow]Parameters reset to defaults.[-]")
+				return
+			}
+			if len(args) < 2 {
+				a.appendLog(fmt.Sprintf("[red]Usage: params %s <file>[-]", args[0]))
+				return
+			}
+			if args[0] == "load" {
+				if err := a.engine.LoadParams(args[1]); err != nil {
+					a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+					return
+				}
+				a.appendLog(fmt.Sprintf("[yellow]Parameters loaded from %s.[-]", args[1]))
+				return
+			}
+			f, err := os.Create(args[1])
+			if err == nil {
+				err = a.engine.WriteParams(f)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			a.appendLog(fmt.Sprintf("[yellow]Changed parameters saved to %s.[-]", args[1]))
+			return
+		}
+	}
+
+	// With no filter only changed values are shown; there are hundreds.
+	filter := ""
+	if len(args) > 0 {
+		filter = strings.ToLower(args[0])
+	}
+	n := 0
+	for _, p := range a.engine.Params() {
+		if filter == "" && p.Value == p.Default {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(p.Name), filter) {
+			continue
+		}
+		a.appendLog(fmt.Sprintf("  %-24s [aqua]%6d[-]  (default %d)", p.Name, p.Value, p.Default))
+		n++
+	}
+	if n == 0 {
+		if filter == "" {
+			a.appendLog("All parameters at their defaults. Use [yellow]params <filter>[-] to list them.")
+		} else {
+			a.appendLog(fmt.Sprintf("[red]No parameters match %s[-]", args[0]))
+		}
+	}
+}
+
+// parseParamValue reads an integer or a switch value.
+func parseParamValue(s string) (int, bool) {
+	switch strings.ToLower(s) {
+	case "true", "on":
+		return 1, true
+	case "false", "off":
+		return 0, true
+	}
+	v, err := strconv.Atoi(s)
+	return v, err == nil
+}
+
+// tablebases handles "tb": with no arguments it probes the current position,
+// otherwise it loads tablebases from a directory, drops them, or generates
+// one in the background.
+func (a *app) tablebases(args []string) {
+	tb := a.engine.Tablebase()
+	switch {
+	case len(args) < 2:
+		if tb == nil || len(tb.Tables()) == 0 {
+			a.appendLog("Tablebases: [aqua]none[-]")
+			return
+		}
+		a.appendLog(fmt.Sprintf("Tablebases: [aqua]%s[-]", strings.Join(tb.Tables(), " ")))
+		if m, r, ok := tb.BestMove(a.pos); ok {
+			a.appendLog(fmt.Sprintf("  %s in %d plies, best move [aqua]%s[-]", r.Outcome, r.Plies, m))
+		} else if r, ok := tb.Probe(a.pos); ok {
+			a.appendLog(fmt.Sprintf("  %s", r.Outcome))
+		} else {
+			a.appendLog("  Position not in the tablebases")
+		}
+
+	case a.searching > 0:
+		a.appendLog("[red]Can't change the tablebases during a search.[-]")
+
+	case args[1] == "off":
+		a.engine.SetTablebase(nil)
+		a.appendLog("[yellow]Tablebases removed.[-]")
+
+	case args[1] == "gen":
+		if len(args) < 3 {
+			a.appendLog("[red]Usage: tb gen <code> [dir][-]")
+			return
+		}
+		// generate into a copy so a search can keep probing the old set
+		next := tablebase.New()
+		if tb != nil {
+			for _, code := range tb.Tables() {
+				next.Add(tb.Table(code))
+			}
+		}
+		code := strings.ToUpper(args[2])
+		a.appendLog(fmt.Sprintf("[yellow]Generating %s...[-]", code))
+		go func() {
+			start := time.Now()
+			tables, err := next.Generate(code)
+			if err == nil && len(args) > 3 {
+				for _, t := range tables {
+					if err = t.Save(args[3]); err != nil {
+						break
+					}
+				}
+			}
+			a.tv.QueueUpdateDraw(func() {
+				if err != nil {
+					a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+					return
+				}
+				a.engine.SetTablebase(next)
+				for _, t := range tables {
+					st := t.Stats()
+					a.appendLog(fmt.Sprintf("  %s: %d positions, longest mate %d plies", t.Code(), t.Size(), st.Longest))
+				}
+				a.appendLog(fmt.Sprintf("[yellow]Generated %d tables in %s.[-]", len(tables), time.Since(start).Round(time.Millisecond)))
+			})
+		}()
+
+	default:
+		if err := a.engine.LoadTablebases(args[1]); err != nil {
+			a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		a.engine.SetParam("Tablebases", 1)
+		a.appendLog(fmt.Sprintf("[yellow]Loaded %d tablebases from %s.[-]", len(a.engine.Tablebase().Tables()), args[1]))
+	}
+}
+
+// openingBook handles "book": with no arguments it lists the book moves for
+// the current position, otherwise it loads a book file or drops the book.
+func (a *app) openingBook(args []string) {
+	switch {
+	case len(args) < 2:
+		b := a.engine.Book()
+		if b == nil {
+			a.appendLog("Book: [aqua]none[-]")
+			return
+		}
+		moves := b.Moves(a.pos)
+		if len(moves) == 0 {
+			a.appendLog("  Position not in the book")
+			return
+		}
+		total := 0
+		for _, m := range moves {
+			total += m.Weight
+		}
+		a.appendLog(fmt.Sprintf("[aqua]Book moves[-] (%d)", len(moves)))
+		for _, m := range moves {
+			a.appendLog(fmt.Sprintf("  %-7s %-6s weight %5d  %5.1f%%",
+				pgn.SAN(a.pos, m.Move), m.Move, m.Weight, 100*float64(m.Weight)/float64(total)))
+		}
+
+	case a.searching > 0:
+		a.appendLog("[red]Can't change the book during a search.[-]")
+
+	case args[1] == "off":
+		a.engine.SetBook(nil)
+		a.appendLog("[yellow]Book removed.[-]")
+
+	default:
+		if err := a.engine.LoadBook(args[1]); err != nil {
+			a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		a.engine.SetParam("OwnBook", 1)
+		a.appendLog(fmt.Sprintf("[yellow]Loaded %d book entries from %s.[-]", a.engine.Book().Len(), args[1]))
+	}
+}
+
+// skillLevel handles "level": with no arguments it shows the level the
+// engine plays at, otherwise it sets a level, an Elo to play at, or full
+// strength.
+func (a *app) skillLevel(args []string) {
+	if len(args) < 2 {
+		level := a.engine.SkillLevel()
+		if level == search.MaxSkillLevel {
+			a.appendLog("Level: [aqua]full strength[-]")
+		} else {
+			a.appendLog(fmt.Sprintf("Level: [aqua]%d[-] (about %d Elo)", level, search.SkillElo(level)))
+		}
+		return
+	}
+	if a.searching > 0 {
+		a.appendLog("[red]Can't change the level during a search.[-]")
+		return
+	}
+
+	var err error
+	switch {
+	case args[1] == "off":
+		a.engine.SetParam("UCI_LimitStrength", 0)
+		err = a.engine.SetParam("SkillLevel", search.MaxSkillLevel)
+	case args[1] == "elo" && len(args) > 2:
+		elo, perr := strconv.Atoi(args[2])
+		if perr != nil {
+			a.appendLog("[red]Usage: level elo <rating>[-]")
+			return
+		}
+		if err = a.engine.SetParam("UCI_Elo", elo); err == nil {
+			a.engine.SetParam("UCI_LimitStrength", 1)
+		}
+	default:
+		level, perr := strconv.Atoi(args[1])
+		if perr != nil {
+			a.appendLog("[red]Usage: level [n|elo N|off][-]")
+			return
+		}
+		if err = a.engine.SetParam("SkillLevel", level); err == nil {
+			a.engine.SetParam("UCI_LimitStrength", 0)
+		}
+	}
+	if err != nil {
+		a.appendLog(fmt.Sprintf("[red]%v[-]", err))
+		return
+	}
+	a.skillLevel(args[:1])
+}
+
+// review handles "review": it searches every position of the game in the
+// background, lists the inaccuracies, mistakes and blunders, and annotates
+// the game so pgn shows the review.
+func (a *app) review(args []string) {
+	if a.searching > 0 {
+		a.appendLog("[red]Can't review during a search.[-]")
+		return
+	}
+	if a.game.MoveCount() == 0 {
+		a.appendLog("[red]No moves to review.[-]")
+		return
+	}
+	opts := analysis.DefaultOptions()
+	opts.Depth = a.parseDepthArg(args)
+	opts.Threads = a.threads
+	g := a.game
+	a.appendLog(fmt.Sprintf("[yellow]Reviewing %d moves (depth %d)...[-]", g.MoveCount(), opts.Depth))
+	a.searching++
+	go func() {
+		start := time.Now()
+		r := analysis.Analyze(a.engine, g, opts, func(done, total int) {
+			if done%10 == 0 {
+				a.tv.QueueUpdateDraw(func() {
+					a.appendLog(fmt.Sprintf("  %d/%d positions", done, total))
+				})
+			}
+		})
+		a.tv.QueueUpdateDraw(func() {
+			a.searching--
+			found := 0
+			for _, mv := range r.Moves {
+				if mv.Class == analysis.Good {
+					continue
+				}
+				found++
+				a.appendLog(fmt.Sprintf("  %s [aqua]%s[-] [red]%s[-] (%s → %s), best [aqua]%s[-]",
+					moveNumber(mv.Number, mv.Color), mv.SAN, mv.Class,
+					analysis.FormatEval(mv.Before), analysis.FormatEval(mv.After), mv.BestSAN))
+			}
+			if found == 0 {
+				a.appendLog("  No inaccuracies found.")
+			}
+
+			rep := r.Report(3)
+			a.appendLog(fmt.Sprintf("  %-6s %8s %6s %5s %5s %5s", "", "Accuracy", "ACPL", "?!", "?", "??"))
+			for _, side := range []struct {
+				name string
+				s    analysis.SideReport
+			}{{"White", rep.White}, {"Black", rep.Black}} {
+				a.appendLog(fmt.Sprintf("  %-6s %7.1f%% %6.1f %5d %5d %5d", side.name,
+					side.s.Accuracy, side.s.ACPL, side.s.Inaccuracies, side.s.Mistakes, side.s.Blunders))
+			}
+			if len(rep.Critical) > 0 {
+				a.appendLog("[aqua]Critical moments[-]")
+				for _, m := range rep.Critical {
+					mv := r.Moves[m.Ply]
+					a.appendLog(fmt.Sprintf("  %s [aqua]%s[-] (%s → %s) [yellow]%+.1f%%[-] for White",
+						moveNumber(mv.Number, mv.Color), m.SAN, m.Before, m.After, m.Swing))
+				}
+			}
+			r.Annotate(g)
+			a.appendLog(fmt.Sprintf("[yellow]Review done in %s.[-] Type [yellow]pgn[-] for the annotated game.",
+				time.Since(start).Round(time.Millisecond)))
+		})
+	}()
+}
+
+// moveNumber writes a move number as in move text: 12. for White, 12...
+// for Black.
+func moveNumber(n int, c core.Color) string {
+	if c == core.Black {
+		return fmt.Sprintf("%d...", n)
+	}
+	return fmt.Sprintf("%d.", n)
+}
+
+// showEval prints the static evaluation of the current position term by term.
+func (a *app) showEval() {
+	b := a.engine.EvaluateDetailed(a.pos)
+	a.appendLog("[aqua]Evaluation[-] (centipawns, each side from its own view)")
+	a.appendLog(fmt.Sprintf("  %-14s %7s %7s %7s", "Term", "White", "Black", "Net"))
+	for t := search.Term(0); t < search.NumTerms; t++ {
+		a.appendLog(fmt.Sprintf("  %-14s %7d %7d %7d", t, b.Terms[t][0], b.Terms[t][1], b.Net(t)))
+	}
+	a.appendLog(fmt.Sprintf("  %-14s %23s", "Total (white)", formatScore(b.White())))
+	a.appendLog(fmt.Sprintf("  %-14s %23s", "Side to move", formatScore(b.Total)))
+	a.appendLog(fmt.Sprintf("  %-14s %23s", "Phase", fmt.Sprintf("%d/24", b.Phase)))
+	if b.Endgame != "" {
+		a.appendLog(fmt.Sprintf("  %-14s %23s", "Endgame", fmt.Sprintf("%s (%d/64)", b.Endgame, b.Scale)))
+	}
+	if a.engine.UsesNetwork() {
+		a.appendLog(fmt.Sprintf("  %-14s %23s", "Network", formatScore(a.engine.Evaluate(a.pos))))
+	}
+}
+
 func (a *app) parseDepthArg(args []string) int {
 	d := a.depth
 	if len(args) >= 2 {
@@ -376,12 +810,31 @@ func logoString() string {
 	return sb.String()
 }
 
-func formatScore(score int) string {
-	if score >= search.Mate-500 {
-		return "mate in " + strconv.Itoa(score - search.Mate)
+// depthLine describes a search result as it comes in, one per depth.
+func depthLine(r search.Result, elapsed time.Duration) string {
+	if r.Book {
+		return fmt.Sprintf("  book: [aqua]%s[-]", r.Move)
+	}
+	return fmt.Sprintf("  depth [aqua]%d[-]: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  hash: %d‰  time: [yellow]%s[-]",
+		r.Depth, r.Move, formatScore(r.Score), r.Nodes, r.Hashfull, elapsed.Round(time.Millisecond))
+}
+
+// resultLine describes the final result of a search.
+func resultLine(label string, res search.Result, elapsed time.Duration) string {
+	if res.Book {
+		return fmt.Sprintf("%s: [aqua]%s[-]  [yellow]book[-]", label, res.Move)
 	}
-	if score <= -search.Mate+500 {
-		return "mate in -" + strconv.Itoa(score + (-search.Mate))
+	nps := uint64(0)
+	if elapsed.Seconds() > 0 {
+		nps = uint64(float64(res.Nodes) / elapsed.Seconds())
+	}
+	return fmt.Sprintf("%s: [aqua]%s[-]  score: [yellow]%s[-]  nodes: %d  time: [yellow]%s[-]  nps: [yellow]%d[-]",
+		label, res.Move, formatScore(res.Score), res.Nodes, elapsed.Round(time.Millisecond), nps)
+}
+
+func formatScore(score int) string {
+	if moves, ok := search.MateIn(score); ok {
+		return "mate in " + strconv.Itoa(moves)
 	}
 	return fmt.Sprintf("%.2f", float64(score)/100.0)
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rivo/tview"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/pgn"
+)
+
+// testApp returns an app with its widgets but no terminal, in the
+// starting position.
+func testApp(t *testing.T) *app {
+	t.Helper()
+	a := newApp()
+	a.board = NewKittyImage()
+	a.log = tview.NewTextView()
+	a.info = tview.NewTextView()
+	pos, err := fen.Parse("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.pos = pos
+	a.game = pgn.NewGame(pos)
+	return a
+}
+
+func TestNoSearchDuringSearch(t *testing.T) {
+	// with a review running, nothing may start another search on the engine
+	for _, cmd := range []string{"search", "s 3", "play", "p", "auto", "e2e4"} {
+		a := testApp(t)
+		a.searching = 1
+		a.handleInput(cmd)
+		if a.searching != 1 {
+			t.Errorf("%s started a search during another one", cmd)
+		}
+		if !strings.Contains(a.log.GetText(true), "Can't start a search") {
+			t.Errorf("%s: log %q", cmd, a.log.GetText(true))
+		}
+	}
+}
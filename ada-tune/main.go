@@ -0,0 +1,113 @@
+// Command ada-tune fits the evaluation weights to a file of labelled
+// positions with Texel's method and writes the result as a parameter file
+// the engine can load.
+//
+//	ada-tune [flags] positions.txt
+//
+// Each line holds a FEN and a game result or engine score; see
+// search.ReadTuneSamples for the accepted forms.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+func main() {
+	start   := flag.String("params", "", "parameter file to start from (.json or .toml)")
+	out     := flag.String("out", "tuned.toml", "where to write the tuned parameters")
+	only    := flag.String("tune", "", "comma separated name prefixes of the weights to tune (default all)")
+	k       := flag.Float64("k", 0, "sigmoid scale; 0 fits it to the data")
+	step    := flag.Int("step", 4, "starting step size")
+	passes  := flag.Int("passes", 0, "maximum passes over the weights (0 until converged)")
+	threads := flag.Int("threads", 0, "threads computing the error (0 for every CPU)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ada-tune [flags] positions.txt\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *start, *out, *only, *k, *step, *passes, *threads); err != nil {
+		fmt.Fprintln(os.Stderr, "ada-tune:", err)
+		os.Exit(1)
+	}
+}
+
+func run(data, start, out, only string, k float64, step, passes, threads int) error {
+	engine := search.NewEngine(1)
+	if start != "" {
+		if err := engine.LoadParams(start); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(data)
+	if err != nil {
+		return err
+	}
+	samples, err := search.ReadTuneSamples(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", data, err)
+	}
+
+	var prefixes []string
+	for _, p := range strings.Split(only, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	began := time.Now()
+	tuner, err := engine.NewTuner(samples, prefixes...)
+	if err != nil {
+		return err
+	}
+	tuner.Threads = threads
+	fmt.Printf("%d positions (%d read), %d weights\n", tuner.Positions(), len(samples), len(tuner.Params()))
+
+	if k > 0 {
+		tuner.SetK(k)
+	} else {
+		tuner.FitK()
+	}
+	before := tuner.Error()
+	fmt.Printf("K = %.4f  error before: %.6f\n", tuner.K(), before)
+
+	after := tuner.Tune(step, passes, func(pass, step int, err float64) {
+		fmt.Printf("pass %d  step %d  error %.6f  (%s)\n", pass, step, err, time.Since(began).Round(time.Second))
+
+		// keep what we have so far in case the run is interrupted
+		if werr := writeParams(engine, out); werr != nil {
+			fmt.Fprintln(os.Stderr, "ada-tune:", werr)
+		}
+	})
+
+	if err := writeParams(engine, out); err != nil {
+		return err
+	}
+	fmt.Printf("error before: %.6f  after: %.6f  (%.2f%% lower)\n", before, after, 100*(before-after)/before)
+	fmt.Printf("wrote %s\n", out)
+	return nil
+}
+
+func writeParams(engine *search.Engine, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := engine.WriteParams(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
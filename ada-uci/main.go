@@ -0,0 +1,464 @@
+// Command ada-uci runs the engine over the UCI protocol on stdin/stdout so
+// it can be used from a chess GUI or match runner.
+//
+// Run as "ada-uci bench [depth]" it instead searches a fixed set of
+// positions with a new engine and prints the node count, a signature that
+// only changes when the search does, and the speed. The bench command does
+// the same within a session, with the options set so far.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WilliamDann/AdaEngine/ada-chess/core"
+	"github.com/WilliamDann/AdaEngine/ada-chess/fen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/movegen"
+	"github.com/WilliamDann/AdaEngine/ada-chess/position"
+	"github.com/WilliamDann/AdaEngine/ada-search"
+)
+
+const startFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// maxSearchDepth bounds searches that are only limited by time.
+const maxSearchDepth = 64
+
+// time kept back from every move for communication lag
+const moveOverhead = 30 * time.Millisecond
+
+type uci struct {
+	engine  *search.Engine
+	pos     *position.Position
+	hashMB  int
+	threads int
+
+	mu  sync.Mutex // guards out
+	out io.Writer
+
+	// closed when the running search has printed its bestmove
+	done chan struct{}
+}
+
+func newUCI(out io.Writer) *uci {
+	pos, _ := fen.Parse(startFEN)
+	return &uci{
+		engine:  search.NewEngine(search.DefaultHashMB),
+		pos:     pos,
+		hashMB:  search.DefaultHashMB,
+		threads: 1,
+		out:     out,
+	}
+}
+
+func (u *uci) send(format string, args ...any) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	fmt.Fprintf(u.out, format+"\n", args...)
+}
+
+// wait blocks until any running search has finished.
+func (u *uci) wait() {
+	if u.done != nil {
+		<-u.done
+		u.done = nil
+	}
+}
+
+// stop ends any running search and waits for its bestmove. The search may
+// not have started yet when stop arrives, so keep asking until it is done.
+func (u *uci) stop() {
+	if u.done == nil {
+		return
+	}
+	for {
+		u.engine.Stop()
+		select {
+		case <-u.done:
+			u.done = nil
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// handle runs one command. It returns false on quit.
+func (u *uci) handle(line string) bool {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return true
+	}
+
+	switch args[0] {
+	case "uci":
+		u.send("id name AdaEngine")
+		u.send("id author William Dann")
+		u.send("option name Hash type spin default %d min 1 max 65536", search.DefaultHashMB)
+		u.send("option name Threads type spin default 1 min 1 max %d", runtime.NumCPU())
+		u.send("option name Clear Hash type button")
+		u.send("option name ParamsFile type string default <empty>")
+		u.send("option name EvalFile type string default <empty>")
+		u.send("option name TablebasePath type string default <empty>")
+		u.send("option name BookFile type string default <empty>")
+		for _, p := range u.engine.Params() {
+			if p.Check {
+				u.send("option name %s type check default %t", p.Name, p.Default != 0)
+			} else {
+				u.send("option name %s type spin default %d min %d max %d", p.Name, p.Default, p.Min, p.Max)
+			}
+		}
+		u.send("uciok")
+
+	case "isready":
+		u.send("readyok")
+
+	case "setoption":
+		u.wait()
+		u.setOption(args[1:])
+
+	case "ucinewgame":
+		u.wait()
+		u.engine.Clear()
+
+	case "position":
+		u.wait()
+		if err := u.setPosition(args[1:]); err != nil {
+			u.send("info string %v", err)
+		}
+
+	case "go":
+		u.wait()
+		u.goSearch(args[1:])
+
+	case "bench":
+		u.wait()
+		depth := search.BenchDepth
+		if len(args) > 1 {
+			if d, err := strconv.Atoi(args[1]); err == nil && d > 0 {
+				depth = d
+			}
+		}
+		u.bench(u.engine.Bench, depth)
+
+	case "stop":
+		u.stop()
+
+	case "quit":
+		u.stop()
+		return false
+
+	default:
+		u.send("info string unknown command %s", args[0])
+	}
+	return true
+}
+
+// setOption handles "setoption name <name> [value <value>]". Names may
+// contain spaces.
+func (u *uci) setOption(args []string) {
+	var name, value []string
+	cur := &name
+	for _, a := range args {
+		switch a {
+		case "name":
+			cur = &name
+		case "value":
+			cur = &value
+		default:
+			*cur = append(*cur, a)
+		}
+	}
+	n := strings.Join(name, " ")
+	v := strings.Join(value, " ")
+
+	switch strings.ToLower(n) {
+	case "hash":
+		if mb, err := strconv.Atoi(v); err == nil && mb > 0 {
+			u.hashMB = mb
+			u.engine.SetHash(mb)
+		}
+	case "threads":
+		if t, err := strconv.Atoi(v); err == nil && t > 0 {
+			u.threads = t
+		}
+	case "clear hash":
+		u.engine.Clear()
+	case "evalfile":
+		if v == "" || v == "<empty>" {
+			u.engine.SetNetwork(nil)
+		} else if err := u.engine.LoadNetwork(v); err != nil {
+			u.send("info string %v", err)
+		}
+	case "tablebasepath":
+		if v == "" || v == "<empty>" {
+			u.engine.SetTablebase(nil)
+		} else if err := u.engine.LoadTablebases(v); err != nil {
+			u.send("info string %v", err)
+		} else {
+			u.send("info string loaded %d tablebases", len(u.engine.Tablebase().Tables()))
+		}
+	case "bookfile":
+		if v == "" || v == "<empty>" {
+			u.engine.SetBook(nil)
+		} else if err := u.engine.LoadBook(v); err != nil {
+			u.send("info string %v", err)
+		} else {
+			u.send("info string loaded %d book entries", u.engine.Book().Len())
+		}
+	case "paramsfile":
+		if v != "" && v != "<empty>" {
+			if err := u.engine.LoadParams(v); err != nil {
+				u.send("info string %v", err)
+			}
+		}
+	default:
+		var x int
+		switch strings.ToLower(v) {
+		case "true":
+			x = 1
+		case "false":
+			x = 0
+		default:
+			var err error
+			if x, err = strconv.Atoi(v); err != nil {
+				u.send("info string bad value %q for %s", v, n)
+				return
+			}
+		}
+		if err := u.engine.SetParam(n, x); err != nil {
+			u.send("info string %v", err)
+		}
+	}
+}
+
+// setPosition handles "position [startpos | fen <fen>] [moves <m1> ...]".
+func (u *uci) setPosition(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("position: missing arguments")
+	}
+
+	var pos *position.Position
+	var err error
+	rest := args[1:]
+	switch args[0] {
+	case "startpos":
+		pos, err = fen.Parse(startFEN)
+	case "fen":
+		end := len(rest)
+		for i, a := range rest {
+			if a == "moves" {
+				end = i
+				break
+			}
+		}
+		pos, err = fen.Parse(strings.Join(rest[:end], " "))
+		rest = rest[end:]
+	default:
+		return fmt.Errorf("position: want startpos or fen")
+	}
+	if err != nil {
+		return err
+	}
+
+	var history []*position.Position
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, s := range rest[1:] {
+			m, ok := parseMove(pos, s)
+			if !ok {
+				return fmt.Errorf("position: illegal move %s", s)
+			}
+			history = append(history, pos)
+			pos = position.MakeMove(pos, m)
+		}
+	}
+
+	u.pos = pos
+	u.engine.SetHistory(history)
+	return nil
+}
+
+// parseMove finds the legal move written in long algebraic notation.
+func parseMove(pos *position.Position, s string) (core.Move, bool) {
+	moves := movegen.LegalMoves(pos)
+	for i := 0; i < moves.Count(); i++ {
+		if m := moves.Get(i); m.String() == s {
+			return m, true
+		}
+	}
+	return core.NoMove, false
+}
+
+// goSearch handles "go" and starts the search in the background.
+func (u *uci) goSearch(args []string) {
+	depth := maxSearchDepth
+	var moveTime, wtime, btime, winc, binc time.Duration
+	movesToGo := 0
+	nodes := 0
+
+	for i := 0; i < len(args); i++ {
+		val := 0
+		if i+1 < len(args) {
+			val, _ = strconv.Atoi(args[i+1])
+		}
+		ms := time.Duration(val) * time.Millisecond
+
+		switch args[i] {
+		case "depth":
+			depth = max(1, val)
+			i++
+		case "movetime":
+			moveTime = ms
+			i++
+		case "wtime":
+			wtime = ms
+			i++
+		case "btime":
+			btime = ms
+			i++
+		case "winc":
+			winc = ms
+			i++
+		case "binc":
+			binc = ms
+			i++
+		case "movestogo":
+			movesToGo = val
+			i++
+		case "nodes":
+			nodes = val
+			i++
+		}
+	}
+
+	limit := moveTime
+	if limit == 0 {
+		left, inc := wtime, winc
+		if u.pos.ActiveColor == core.Black {
+			left, inc = btime, binc
+		}
+		if left > 0 {
+			limit = allocateTime(left, inc, movesToGo)
+		}
+	}
+
+	pos := u.pos
+	threads := u.threads
+	// the engine's limit is per thread
+	u.engine.NodeLimit = 0
+	if nodes > 0 {
+		u.engine.NodeLimit = uint64(max(nodes/threads, 1))
+	}
+	multiPV := u.engine.Options.MultiPV
+	done := make(chan struct{})
+	u.done = done
+
+	go func() {
+		defer close(done)
+		start := time.Now()
+		res := u.engine.Search(pos, depth, threads, limit, func(r search.Result) {
+			if r.Book {
+				u.send("info string book move %s", r.Move)
+				return
+			}
+			if multiPV > 1 && len(r.Lines) > 1 {
+				for i, l := range r.Lines[:min(multiPV, len(r.Lines))] {
+					u.send("info multipv %d %s", i+1, infoLine(r, l, time.Since(start)))
+				}
+				return
+			}
+			u.send("info %s", infoLine(r, search.Line{Move: r.Move, Score: r.Score}, time.Since(start)))
+		})
+
+		if res.Move == core.NoMove {
+			u.send("bestmove 0000")
+			return
+		}
+		u.send("bestmove %s", res.Move)
+	}()
+}
+
+// allocateTime decides how long to think from the clock: an even share of
+// the remaining time plus most of the increment, never more than half of
+// what is left.
+func allocateTime(left, inc time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	t := left/time.Duration(movesToGo) + inc*3/4
+	t = min(t, left/2) - moveOverhead
+	return max(t, 10*time.Millisecond)
+}
+
+// infoLine formats the search statistics of r for one of its lines.
+func infoLine(r search.Result, l search.Line, elapsed time.Duration) string {
+	ms := elapsed.Milliseconds()
+	nps := uint64(0)
+	if ms > 0 {
+		nps = r.Nodes * 1000 / uint64(ms)
+	}
+	score := fmt.Sprintf("cp %d", l.Score)
+	if moves, ok := search.MateIn(l.Score); ok {
+		score = fmt.Sprintf("mate %d", moves)
+	}
+	// the whole line for the best move, the move alone for the others
+	pv := l.Move.String()
+	if len(r.PV) > 0 && r.PV[0] == l.Move {
+		moves := make([]string, len(r.PV))
+		for i, m := range r.PV {
+			moves[i] = m.String()
+		}
+		pv = strings.Join(moves, " ")
+	}
+	return fmt.Sprintf("depth %d score %s nodes %d nps %d time %d hashfull %d tbhits %d pv %s",
+		r.Depth, score, r.Nodes, nps, ms, r.Hashfull, r.TBHits, pv)
+}
+
+// bench runs a bench and reports each position's result and the totals,
+// ending with the signature, the nodes searched over all the positions.
+func (u *uci) bench(run func(int, func(int, string, search.Result)) search.BenchResult, depth int) {
+	n := len(search.BenchPositions())
+	res := run(depth, func(i int, f string, r search.Result) {
+		move := "0000"
+		if r.Move != core.NoMove {
+			move = r.Move.String()
+		}
+		u.send("info string position %d/%d %s bestmove %s nodes %d", i+1, n, f, move, r.Nodes)
+	})
+	u.send("info string %d positions to depth %d", res.Positions, depth)
+	u.send("info string total time (ms) %d", res.Time.Milliseconds())
+	u.send("info string nodes/second %d", res.NPS())
+	u.send("info string signature %d", res.Nodes)
+}
+
+func main() {
+	u := newUCI(os.Stdout)
+
+	// "ada-uci bench [depth]" runs the bench with a new engine and exits
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		depth := search.BenchDepth
+		if len(os.Args) > 2 {
+			d, err := strconv.Atoi(os.Args[2])
+			if err != nil || d <= 0 {
+				fmt.Fprintln(os.Stderr, "usage: ada-uci [bench [depth]]")
+				os.Exit(2)
+			}
+			depth = d
+		}
+		u.bench(search.Bench, depth)
+		return
+	}
+
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		if !u.handle(strings.TrimSpace(sc.Text())) {
+			return
+		}
+	}
+	u.stop()
+}